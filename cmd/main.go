@@ -2,113 +2,60 @@ package main
 
 import (
 	"iasi/internal/iasiutils"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
 
-
-// callGeminiLLM calls the Gemini LLM API with the prompt and optional system prompt, and returns the response JSON
-func callGeminiLLM(prompt string, systemPrompt ...string) (string, error) {
-       apiKey := os.Getenv("GEMINI_API_KEY")
-       if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY not set")
-       }
-       url := "https://generativelanguage.googleapis.com/v1/models/gemini-1.5-flash:generateContent?key=" + apiKey
-       var parts []string
-       if len(systemPrompt) > 0 && strings.TrimSpace(systemPrompt[0]) != "" {
-	       parts = append(parts, fmt.Sprintf(`{"text":%q}`, systemPrompt[0]))
-       }
-       parts = append(parts, fmt.Sprintf(`{"text":%q}`, prompt))
-       reqBody := fmt.Sprintf(`{"contents":[{"parts":[%s]}]}`, strings.Join(parts, ","))
-       req, err := http.NewRequest("POST", url, strings.NewReader(reqBody))
-       if err != nil {
-	       return "", err
-       }
-       req.Header.Set("Content-Type", "application/json")
-       client := &http.Client{Timeout: 60 * time.Second}
-       resp, err := client.Do(req)
-       if err != nil {
-	       return "", err
-       }
-       defer resp.Body.Close()
-       body, err := ioutil.ReadAll(resp.Body)
-       if err != nil {
-	       return "", err
-       }
-       // Log the raw Gemini response for debugging
-       log.Printf("[DEBUG] Raw Gemini API response: %s", iasiutils.TruncateString(string(body), 1000))
-       // Parse Gemini response
-       var parsed struct {
-	       Candidates []struct {
-		       Content struct {
-			       Parts []struct {
-				       Text string `json:"text"`
-			       } `json:"parts"`
-		       } `json:"content"`
-	       } `json:"candidates"`
-       }
-       if err := json.Unmarshal(body, &parsed); err != nil {
-	       return "", err
-       }
-       if len(parsed.Candidates) == 0 || len(parsed.Candidates[0].Content.Parts) == 0 {
-	       return "", fmt.Errorf("No LLM response candidates. Raw response: %s", iasiutils.TruncateString(string(body), 1000))
-       }
-       return parsed.Candidates[0].Content.Parts[0].Text, nil
-}
-
-// main is the entry point for the CLI tool. It fetches, filters, groups, sorts, and writes the user's 100-point problems to CSV.
+// main is the entry point for the CLI tool. It fetches the user's submissions, aggregates them per problem, and writes the timeline to CSV.
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: iasi <username> OR iasi run <username>")
+		fmt.Println("Usage: iasi <username> OR iasi run <username> OR iasi generate [-parallel N] [-budget-tokens N] <username>")
 		os.Exit(1)
 	}
+	fetcher, err := iasiutils.NewFetcherFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid fetcher configuration: %v", err)
+	}
 	if os.Args[1] == "run" && len(os.Args) >= 3 {
 		username := os.Args[2]
-		serveTracker(username)
+		serveTracker(fetcher, username)
 		return
 	}
+	if os.Args[1] == "generate" {
+		os.Exit(generateAll(fetcher, os.Args[2:]))
+	}
 	username := os.Args[1]
 
-	records, err := fetchAllEntries(username)
+	monitor := &iasiutils.MonitorSync{Fetcher: fetcher}
+	subs, err := monitor.Sync(username)
 	if err != nil {
 		log.Fatalf("Error fetching entries: %v", err)
 	}
-	if len(records) == 0 {
+	if len(subs) == 0 {
 		fmt.Println("No entries found for user.")
 		return
 	}
 
-	// Convert []monitorRow to [][]string for filter/group/sort
-	var raw [][]string
-	for _, r := range records {
-		raw = append(raw, r.fields)
-	}
-	filtered := filter100PointEntries(raw)
-	grouped := groupByProblemEarliest(filtered)
-	final := sortByDate(grouped)
-
-	// Map back to monitorRow to get problemUrl
-	var finalRows []monitorRow
-	for _, row := range final {
-		for _, r := range records {
-			if len(row) == len(r.fields) && row[0] == r.fields[0] && row[2] == r.fields[2] && row[5] == r.fields[5] {
-				finalRows = append(finalRows, r)
-				break
-			}
-		}
-	}
+	timeline := buildTimeline(subs)
 
 	outDir := "data"
 	if _, err := os.Stat(outDir); os.IsNotExist(err) {
@@ -117,120 +64,18 @@ func main() {
 		}
 	}
 	outPath := outDir + string(os.PathSeparator) + username + "_timeline.csv"
-	if err := writeCSV(outPath, finalRows); err != nil {
+	if err := writeCSV(outPath, timeline); err != nil {
 		log.Fatalf("Failed to write CSV: %v", err)
 	}
-	fmt.Printf("Saved %d entries to %s\n", len(finalRows), outPath)
+	fmt.Printf("Saved %d entries to %s\n", len(timeline), outPath)
 }
+
 // serveTracker starts a web server to show the tracker UI and serve the problem list as JSON.
-func serveTracker(username string) {
+func serveTracker(fetcher iasiutils.Fetcher, username string) {
 	// Log to console only (no debug.log file)
 	log.SetOutput(os.Stdout)
 	log.Println("[INFO] serveTracker started for user:", username)
 
-	// From here, only Go logs go to debug.log. React dev server output goes to console.
-
-	// --- LLM Editorial/Hints API ---
-	// POST /problems/{id}/generate
-	http.HandleFunc("/problems/", func(w http.ResponseWriter, r *http.Request) {
-		path := strings.TrimPrefix(r.URL.Path, "/problems/")
-		parts := strings.Split(path, "/")
-		if len(parts) < 2 {
-			log.Printf("[ERROR] Invalid /problems/ path: %s", r.URL.Path)
-			http.NotFound(w, r)
-			return
-		}
-		id := parts[0]
-		action := parts[1]
-		editorialPath := "data/editorials/" + id + ".json"
-		if action == "generate" && r.Method == "POST" {
-			log.Printf("[INFO] /problems/%s/generate POST called", id)
-			// Check cache first
-			if _, err := os.Stat(editorialPath); err == nil {
-				log.Printf("[INFO] Editorial cache hit for %s", editorialPath)
-				data, _ := ioutil.ReadFile(editorialPath)
-				w.Header().Set("Content-Type", "application/json")
-				w.Write(data)
-				return
-			}
-		       log.Printf("[INFO] Fetching problem and solution for id %s", id)
-					   ingestor := &iasiutils.InfoarenaIngestor{}
-					   statement, solution, err := ingestor.FetchProblemAndSolution(id)
-		       if err != nil {
-			       log.Printf("[ERROR] Failed to fetch problem/solution: %v", err)
-			       http.Error(w, "Failed to fetch problem/solution: "+err.Error(), 500)
-			       return
-		       }
-		       if strings.TrimSpace(statement) == "" || strings.TrimSpace(solution) == "" {
-					   log.Printf("[ERROR] Statement or solution missing. Statement: '%s' Solution: '%s'", iasiutils.TruncateString(statement, 100), iasiutils.TruncateString(solution, 100))
-			       http.Error(w, "Problem statement or solution could not be fetched. Please check the Infoarena page structure.", 500)
-			       return
-		       }
-		       log.Printf("[INFO] Problem and solution fetched. Building prompt.")
-					   r := &iasiutils.Recipe{SystemPrompt: "You are a helpful assistant for competitive programming and you know very well the competitive programming platform, Codeforces and how editorials and hints are written there. Always answer in English."}
-					   prompt, systemPrompt := r.BuildLLMPrompt(statement, solution)
-					   log.Printf("[DEBUG] Prompt: %s", prompt)
-					   llmResp, err := callGeminiLLM(prompt, systemPrompt)
-		       if err != nil {
-			       log.Printf("[ERROR] LLM error: %v", err)
-			       http.Error(w, "LLM error: "+err.Error(), 500)
-			       return
-		       }
-		       log.Printf("[INFO] LLM response received. Raw response: %s", llmResp)
-		       log.Printf("[INFO] Attempting to parse JSON.")
-	       var result map[string]interface{}
-	       llmJson := llmResp
-	       // Try to extract JSON from code block or text if direct parse fails
-	       var parsedOk bool = true
-	       if err := json.Unmarshal([]byte(llmJson), &result); err != nil {
-		       log.Printf("[WARN] Direct JSON parse failed: %v", err)
-		       // Try to extract JSON from markdown/code block or text
-		       jsonStart := strings.Index(llmResp, "{")
-		       jsonEnd := strings.LastIndex(llmResp, "}")
-		       if jsonStart != -1 && jsonEnd > jsonStart {
-			       llmJson = llmResp[jsonStart : jsonEnd+1]
-			       if err2 := json.Unmarshal([]byte(llmJson), &result); err2 == nil {
-				       log.Printf("[INFO] JSON extracted from LLM output.")
-			       } else {
-				       log.Printf("[WARN] JSON extraction also failed: %v", err2)
-				       result = map[string]interface{}{
-					       "hints": []string{"LLM output could not be parsed as JSON."},
-					       "editorial": llmResp,
-				       }
-				       parsedOk = false
-			       }
-		       } else {
-			       result = map[string]interface{}{
-				       "hints": []string{"LLM output could not be parsed as JSON."},
-				       "editorial": llmResp,
-			       }
-			       parsedOk = false
-		       }
-	       }
-	       jsonBytes, _ := json.MarshalIndent(result, "", "  ")
-	       if parsedOk {
-		       ioutil.WriteFile(editorialPath, jsonBytes, 0644)
-	       }
-	       w.Header().Set("Content-Type", "application/json")
-	       w.Write(jsonBytes)
-	       log.Printf("[INFO] Editorial for %s generated and returned.", id)
-	       return
-		}
-		if action == "editorial" && r.Method == "GET" {
-			if _, err := os.Stat(editorialPath); err == nil {
-				log.Printf("[INFO] Editorial cache GET for %s", editorialPath)
-				data, _ := ioutil.ReadFile(editorialPath)
-				w.Header().Set("Content-Type", "application/json")
-				w.Write(data)
-				return
-			}
-			log.Printf("[WARN] Editorial not generated for %s", editorialPath)
-			http.Error(w, "Not generated", http.StatusNotFound)
-			return
-		}
-		log.Printf("[ERROR] Unknown /problems/ action: %s", action)
-		http.NotFound(w, r)
-	})
 	// Start React dev server (output to console)
 	var reactCmd *exec.Cmd
 	if os.PathSeparator == '\\' { // Windows
@@ -262,283 +107,1361 @@ func serveTracker(username string) {
 	// Open browser to React app
 	openBrowser("http://localhost:5173/")
 
-	// On exit, kill React dev server
+	// On exit, stop the API server and the generation jobs and kill React dev server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{Addr: ":8080"}
 	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, os.Interrupt)
-		<-ch
+		<-ctx.Done()
+		stop() // A second Ctrl-C kills the process.
+		log.Println("[INFO] Shutting down; unfinished generation jobs resume on the next start")
 		_ = reactCmd.Process.Kill()
-		os.Exit(0)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
 	}()
 
-	records, err := fetchAllEntries(username)
+	monitor := &iasiutils.MonitorSync{Fetcher: fetcher}
+	subs, err := monitor.Sync(username)
 	if err != nil {
 		log.Fatalf("Error fetching entries: %v", err)
 	}
-	// Convert []monitorRow to [][]string for filter/group/sort
-	var raw [][]string
-	for _, r := range records {
-		raw = append(raw, r.fields)
-	}
-	filtered := filter100PointEntries(raw)
-	grouped := groupByProblemEarliest(filtered)
-	final := sortByDate(grouped)
-
-	// Map back to monitorRow to get problemUrl
-	var finalRows []monitorRow
-	for _, row := range final {
-		for _, r := range records {
-			if len(row) == len(r.fields) && row[0] == r.fields[0] && row[2] == r.fields[2] && row[5] == r.fields[5] {
-				finalRows = append(finalRows, r)
-				break
-			}
-		}
+	llm, err := iasiutils.NewLLMProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid LLM configuration: %v", err)
+	}
+	log.Printf("[INFO] Using LLM provider %s (model %s)", llm.Name(), llm.Model())
+	t := newTracker(fetcher, llm, username, "data", buildTimeline(subs))
+	t.jobs.Context = ctx
+	if err := t.jobs.Resume(); err != nil {
+		log.Printf("[WARN] Failed to resume generation jobs: %v", err)
 	}
 
-	type Problem struct {
-		Name        string `json:"name"`
-		Url         string `json:"url"`
-		UrlSolution string `json:"url_solution"`
-		Time        string `json:"time"`
-		Id          string `json:"id"`
-	}
-	var problems []Problem
-	for _, r := range finalRows {
-		fields := r.fields
-		if len(fields) >= 6 {
-			// Try to extract job_detail id from problemUrl if possible
-			id := ""
-			if strings.Contains(r.problemUrl, "/job_detail/") {
-				parts := strings.Split(r.problemUrl, "/job_detail/")
-				if len(parts) > 1 {
-					id = parts[1]
-				}
-			} else {
-				id = fields[0]
-				if strings.HasPrefix(id, "#") {
-					id = id[1:]
+	// Scrape problem metadata in the background; /problems includes whatever is loaded so far.
+	go t.metaCache.Preload(t.slugs())
+
+	t.routes(http.DefaultServeMux)
+	log.Println("Go API server running at http://localhost:8080 (API only, UI at http://localhost:5173)")
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// generateAll pre-generates the editorials of every problem on a user's timeline and prints a summary.
+// Problems already in data/editorials are skipped, so running it again resumes where it stopped.
+// It returns the process exit code.
+func generateAll(fetcher iasiutils.Fetcher, args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	var bo batchOptions
+	flags.IntVar(&bo.Parallel, "parallel", iasiutils.EnvInt("IASI_JOB_WORKERS", 2), "number of problems generated at once")
+	flags.IntVar(&bo.BudgetTokens, "budget-tokens", 0, "stop starting problems once this run used this many LLM tokens (0 for no limit)")
+	flags.Float64Var(&bo.BudgetUSD, "budget-usd", 0, "stop starting problems once this run cost this many US dollars (0 for no limit)")
+	recipe := flags.String("recipe", "", "recipe to generate with (default \"default\")")
+	hints := flags.String("hints", "", "number of hints per problem (default: chosen by the recipe)")
+	level := flags.String("level", "", "audience level, e.g. beginner or advanced")
+	lang := flags.String("lang", iasiutils.DefaultLang, "output language (en or ro)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: iasi generate [-parallel N] [-budget-tokens N] [-budget-usd X] [-lang en|ro] [-recipe NAME] [-hints N] [-level LEVEL] <username>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	username := flags.Arg(0)
+	opts, err := parseGenerateOptions(url.Values{"lang": {*lang}, "recipe": {*recipe}, "hints": {*hints}, "level": {*level}}.Get)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	monitor := &iasiutils.MonitorSync{Fetcher: fetcher}
+	subs, err := monitor.Sync(username)
+	if err != nil {
+		log.Fatalf("Error fetching entries: %v", err)
+	}
+	llm, err := iasiutils.NewLLMProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid LLM configuration: %v", err)
+	}
+	log.Printf("[INFO] Using LLM provider %s (model %s)", llm.Name(), llm.Model())
+	t := newTracker(fetcher, llm, username, "data", buildTimeline(subs))
+	if bo.BudgetUSD > 0 && t.usage.Pricing == (iasiutils.Pricing{}) {
+		fmt.Fprintln(os.Stderr, "-budget-usd needs the model prices in IASI_LLM_INPUT_PRICE and IASI_LLM_OUTPUT_PRICE")
+		return 2
+	}
+
+	// Ctrl-C stops scheduling and cancels the generations in flight; they are left for the next run.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res := t.generateBatch(ctx, opts, bo, os.Stdout)
+
+	fmt.Printf("\n%d problems: %d generated, %d skipped (already in %s), %d failed, %d remaining\n",
+		len(t.problems), len(res.Generated), len(res.Skipped), filepath.Join(t.dataDir, "editorials"), len(res.Failed), len(res.Remaining))
+	fmt.Printf("  used %d tokens ($%.4f) in %d model calls\n", res.Spent.TotalTokens, res.Spent.CostUSD, res.Spent.Calls)
+	for _, f := range res.Failed {
+		fmt.Printf("  failed: %s\n", f)
+	}
+	if len(res.Remaining) > 0 {
+		fmt.Printf("  remaining: %s\n", strings.Join(res.Remaining, ", "))
+	}
+	if len(res.Failed) > 0 || len(res.Remaining) > 0 {
+		fmt.Printf("Run `iasi generate %s` again to retry the failed and remaining problems.\n", username)
+	}
+	if len(res.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// batchOptions configure a generateBatch run.
+type batchOptions struct {
+	// Parallel is the number of problems generated at once.
+	Parallel int
+	// BudgetTokens and BudgetUSD cap the LLM consumption of the run's own generations; other use of the
+	// model does not count. Zero does not limit.
+	BudgetTokens int
+	BudgetUSD    float64
+}
+
+// exceeded describes how spent reached the budget, or returns "" while it is within it.
+func (bo batchOptions) exceeded(spent iasiutils.UsageRecord) string {
+	if bo.BudgetTokens > 0 && spent.TotalTokens >= bo.BudgetTokens {
+		return fmt.Sprintf("budget reached: %d of %d tokens used", spent.TotalTokens, bo.BudgetTokens)
+	}
+	if bo.BudgetUSD > 0 && spent.CostUSD >= bo.BudgetUSD {
+		return fmt.Sprintf("budget reached: $%.4f of $%.2f spent", spent.CostUSD, bo.BudgetUSD)
+	}
+	return ""
+}
+
+// batchResult lists the problems of a generateBatch run by outcome.
+type batchResult struct {
+	Generated, Skipped, Remaining []string
+	// Failed holds "slug: error" lines.
+	Failed []string
+	// Spent is the LLM consumption of the run.
+	Spent iasiutils.UsageRecord
+}
+
+// generateBatch generates the editorials missing from the cache, bo.Parallel at a time, and prints each
+// result to out. No further problem is started once ctx is done, the daily usage limit is reached or the
+// run spent its budget; the problems in flight finish, so the budget can be overrun by up to
+// bo.Parallel problems. Problems not generated are listed as remaining.
+func (t *tracker) generateBatch(ctx context.Context, opts generateOptions, bo batchOptions, out io.Writer) batchResult {
+	if bo.Parallel < 1 {
+		bo.Parallel = 1
+	}
+	var (
+		mu  sync.Mutex
+		res batchResult
+		wg  sync.WaitGroup
+		// stopped says why scheduling stopped: the daily usage limit or the budget was reached.
+		stopped string
+	)
+	sem := make(chan struct{}, bo.Parallel)
+	for _, p := range t.problems {
+		if _, err := os.Stat(t.editorials.Path(p.Id, opts.Vars.Lang)); err == nil {
+			res.Skipped = append(res.Skipped, p.Id)
+			continue
+		}
+		// Wait for a free slot before checking the budget, so the problems that just finished count.
+		sem <- struct{}{}
+		mu.Lock()
+		if stopped == "" && ctx.Err() == nil {
+			if reason := bo.exceeded(res.Spent); reason != "" {
+				stopped = reason
+				fmt.Fprintf(out, "stopping: %s\n", reason)
+			}
+		}
+		if stopped != "" || ctx.Err() != nil {
+			res.Remaining = append(res.Remaining, p.Id)
+			mu.Unlock()
+			<-sem
+			continue
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func(p Problem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			log.Printf("[INFO] Generating %s", p.Id)
+			ed, _, err := t.generate(ctx, p, opts, nil, nil)
+			spent := t.generationUsage(ed, err)
+			mu.Lock()
+			defer mu.Unlock()
+			res.Spent.Calls += spent.Calls
+			res.Spent.TokenUsage = res.Spent.TokenUsage.Add(spent.TokenUsage)
+			res.Spent.CostUSD += spent.CostUSD
+			switch {
+			case err == nil:
+				res.Generated = append(res.Generated, p.Id)
+				fmt.Fprintf(out, "generated %s\n", p.Id)
+			case ctx.Err() != nil:
+				res.Remaining = append(res.Remaining, p.Id)
+			case errors.Is(err, iasiutils.ErrUsageLimit):
+				if stopped == "" {
+					stopped = err.Error()
+					fmt.Fprintf(out, "stopping: %v\n", err)
 				}
+				res.Remaining = append(res.Remaining, p.Id)
+			default:
+				res.Failed = append(res.Failed, fmt.Sprintf("%s: %v", p.Id, err))
+				fmt.Fprintf(out, "failed    %s: %v\n", p.Id, err)
 			}
-			urlSolution := "https://www.infoarena.ro/job_detail/" + id
-			problems = append(problems, Problem{
-				Name:        fields[2],
-				Url:         r.problemUrl,
-				UrlSolution: urlSolution,
-				Time:        fields[5],
-				Id:          id,
-			})
+		}(p)
+	}
+	wg.Wait()
+	return res
+}
+
+// generationUsage returns the model calls a generation made and their cost, given what generate
+// returned: the usage of the editorial, or of the rejected answers when the output was invalid.
+func (t *tracker) generationUsage(ed *iasiutils.EditorialRevision, err error) iasiutils.UsageRecord {
+	var rec iasiutils.UsageRecord
+	var invalid *iasiutils.InvalidOutputError
+	switch {
+	case ed != nil:
+		rec = iasiutils.UsageRecord{Calls: ed.Attempts, TokenUsage: ed.Usage}
+	case errors.As(err, &invalid):
+		rec = iasiutils.UsageRecord{Calls: invalid.Attempts, TokenUsage: invalid.Usage}
+	}
+	rec.CostUSD = t.usage.Pricing.Cost(rec.TokenUsage)
+	return rec
+}
+
+// Problem is one entry of the tracker timeline. Id is the canonical problem key, the
+// Infoarena slug from /problema/<slug>; job ids are attached as Submissions.
+type Problem struct {
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	UrlSolution string `json:"url_solution"`
+	Time        string `json:"time"`
+	Id          string `json:"id"`
+	// JobId is the representative submission used for the solution source.
+	JobId        string `json:"job_id"`
+	Solved       bool   `json:"solved"`
+	BestScore    int    `json:"best_score"`
+	Attempts     int    `json:"attempts"`
+	FirstAttempt string `json:"first_attempt"`
+	// TimeToFirstAC is the number of seconds between the first attempt and the first 100-point submission.
+	TimeToFirstAC *int64              `json:"time_to_first_ac_seconds,omitempty"`
+	Submissions   []ProblemSubmission `json:"submissions"`
+	// Meta is filled in once the problem page has been scraped, see ProblemMetaCache.
+	Meta *iasiutils.ProblemMeta `json:"meta,omitempty"`
+}
+
+// ProblemSubmission is one of the user's submissions on a problem.
+type ProblemSubmission struct {
+	JobId string `json:"job_id"`
+	Time  string `json:"time"`
+	State string `json:"state"`
+	Score int    `json:"score"`
+}
+
+// tracker holds the state behind the tracker API for one user.
+type tracker struct {
+	username  string
+	fetcher   iasiutils.Fetcher
+	llm       iasiutils.LLMProvider
+	problems  []Problem
+	bySlug    map[string]int
+	metaCache *iasiutils.ProblemMetaCache
+	jobs      *iasiutils.JobQueue
+	// editorials caches the generated editorials and their previous revisions.
+	editorials *iasiutils.EditorialStore
+	// usage records the LLM consumption and enforces the daily limits.
+	usage *iasiutils.UsageLedger
+	// hints keeps the hint chains revealed step by step and chats the tutor conversations.
+	hints *iasiutils.HintStore
+	chats *iasiutils.ChatStore
+	// inputs keeps the scraped statements and solutions the prompts are built from.
+	inputs *iasiutils.InputStore
+	// locks serializes the steps of each hint chain and the messages of each chat.
+	locks   sync.Map
+	dataDir string
+	// recipesDir holds the prompt templates, see iasiutils.LoadRecipe.
+	recipesDir string
+}
+
+// newTracker indexes the timeline by problem slug and moves caches still keyed by job id to their slug.
+func newTracker(fetcher iasiutils.Fetcher, llm iasiutils.LLMProvider, username, dataDir string, timeline []iasiutils.ProblemStats) *tracker {
+	t := &tracker{
+		username:   username,
+		fetcher:    fetcher,
+		llm:        llm,
+		problems:   []Problem{},
+		bySlug:     make(map[string]int),
+		metaCache:  &iasiutils.ProblemMetaCache{Ingestor: &iasiutils.InfoarenaIngestor{Fetcher: fetcher}, Dir: filepath.Join(dataDir, "problems")},
+		editorials: &iasiutils.EditorialStore{Dir: filepath.Join(dataDir, "editorials")},
+		usage:      iasiutils.NewUsageLedgerFromEnv(filepath.Join(dataDir, "usage")),
+		hints:      &iasiutils.HintStore{Dir: filepath.Join(dataDir, "hints")},
+		chats:      &iasiutils.ChatStore{Dir: filepath.Join(dataDir, "chats")},
+		inputs:     &iasiutils.InputStore{Dir: filepath.Join(dataDir, "inputs")},
+		dataDir:    dataDir,
+		recipesDir: iasiutils.EnvString("IASI_RECIPES_DIR", iasiutils.DefaultRecipesDir),
+	}
+	t.jobs = iasiutils.NewJobQueue(filepath.Join(dataDir, "jobs"), iasiutils.EnvInt("IASI_JOB_WORKERS", 2), t.runJob)
+	// Each language has its own cached editorial, so jobs for different languages run independently.
+	t.jobs.DedupeParams = []string{"lang"}
+	for _, ps := range timeline {
+		if ps.Slug == "" {
+			log.Printf("[WARN] Skipping %q: no problem link on the monitor", ps.Name)
+			continue
+		}
+		p := Problem{
+			Name:         ps.Name,
+			Url:          ps.Representative.ProblemURL(),
+			UrlSolution:  ps.Representative.SolutionURL(),
+			Time:         formatTime(ps.Time()),
+			Id:           ps.Slug,
+			JobId:        ps.Representative.JobID,
+			Solved:       ps.Solved,
+			BestScore:    ps.BestScore,
+			Attempts:     ps.Attempts,
+			FirstAttempt: formatTime(ps.FirstAttempt),
+		}
+		if ps.Solved {
+			secs := int64(ps.TimeToFirstAC / time.Second)
+			p.TimeToFirstAC = &secs
 		}
+		for _, s := range ps.Submissions {
+			p.Submissions = append(p.Submissions, ProblemSubmission{JobId: s.JobID, Time: formatTime(s.SubmittedAt), State: s.State.String(), Score: s.Score})
+		}
+		t.bySlug[p.Id] = len(t.problems)
+		t.problems = append(t.problems, p)
+		t.migrateLegacyCache(p)
 	}
+	return t
+}
 
-	http.HandleFunc("/problems", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"username":%q,"problems":`, username)
-		fmt.Fprint(w, "[")
-		for i, p := range problems {
-			if i > 0 {
-				fmt.Fprint(w, ",")
+// migrateLegacyCache renames editorial and statement caches written under a job id to the problem slug.
+func (t *tracker) migrateLegacyCache(p Problem) {
+	for _, kind := range []struct{ dir, ext string }{{"editorials", ".json"}, {"statements", ".md"}} {
+		target := filepath.Join(t.dataDir, kind.dir, p.Id+kind.ext)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		for _, s := range p.Submissions {
+			legacy := filepath.Join(t.dataDir, kind.dir, s.JobId+kind.ext)
+			if _, err := os.Stat(legacy); err == nil {
+				log.Printf("[INFO] Moving %s to %s", legacy, target)
+				if err := os.Rename(legacy, target); err != nil {
+					log.Printf("[WARN] Failed to move %s: %v", legacy, err)
+				}
+				break
 			}
-			fmt.Fprintf(w, `{"name":%q,"url":%q,"time":%q,"id":%q}`, p.Name, p.Url, p.Time, p.Id)
 		}
-		fmt.Fprint(w, "]}")
-	})
+	}
+}
 
-		// On exit, kill React dev server (disabled for debugging)
-		// go func() {
-		// 	ch := make(chan os.Signal, 1)
-		// 	signal.Notify(ch, os.Interrupt)
-		// 	<-ch
-		// 	_ = reactCmd.Process.Kill()
-		// 	os.Exit(0)
-		// }()
+func (t *tracker) slugs() []string {
+	var slugs []string
+	for _, p := range t.problems {
+		slugs = append(slugs, p.Id)
+	}
+	return slugs
+}
 
-	log.Println("Go API server running at http://localhost:8080 (API only, UI at http://localhost:5173)")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+// problem returns the timeline entry for slug.
+func (t *tracker) problem(slug string) (Problem, bool) {
+	i, ok := t.bySlug[slug]
+	if !ok {
+		return Problem{}, false
+	}
+	return t.problems[i], true
 }
 
-// openBrowser tries to open the URL in the default browser (Windows only for now).
-func openBrowser(url string) {
-	execCmd := "start " + url
-	_ = execCommand(execCmd)
+func (t *tracker) statementPath(slug string) string {
+	return filepath.Join(t.dataDir, "statements", slug+".md")
 }
 
-// execCommand runs a shell command (Windows only).
-func execCommand(cmd string) error {
-	return exec.Command("cmd", "/C", cmd).Start()
+// routes registers the tracker API on mux.
+func (t *tracker) routes(mux *http.ServeMux) {
+	mux.HandleFunc("/problems", t.handleProblems)
+	mux.HandleFunc("/problems/", t.handleProblem)
+	mux.HandleFunc("/jobs", t.handleJobs)
+	mux.HandleFunc("/jobs/", t.handleJob)
+	mux.HandleFunc("/recipes", t.handleRecipes)
+	mux.HandleFunc("/usage", t.handleUsage)
+}
+
+// handleUsage summarizes the LLM consumption per day and per problem.
+// GET /usage
+func (t *tracker) handleUsage(w http.ResponseWriter, r *http.Request) {
+	summary, err := t.usage.Summary()
+	if err != nil {
+		log.Printf("[ERROR] Failed to read LLM usage: %v", err)
+		http.Error(w, "Failed to read usage: "+err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
 }
 
-// fetchAllEntries paginates and fetches all monitor entries for a given username.
-type monitorRow struct {
-	fields     []string
-	problemUrl string
+// handleRecipes lists the recipe names that can be passed to generate.
+// GET /recipes
+func (t *tracker) handleRecipes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(iasiutils.ListRecipes(t.recipesDir))
+}
+
+// handleProblems serves the timeline.
+// GET /problems?source=OJI&min_difficulty=2&max_difficulty=4.5
+func (t *tracker) handleProblems(w http.ResponseWriter, r *http.Request) {
+	source := iasiutils.NormalizeRomanian(r.URL.Query().Get("source"))
+	minDifficulty, _ := strconv.ParseFloat(r.URL.Query().Get("min_difficulty"), 64)
+	maxDifficulty, _ := strconv.ParseFloat(r.URL.Query().Get("max_difficulty"), 64)
+	filtering := source != "" || minDifficulty > 0 || maxDifficulty > 0
+	result := []Problem{}
+	for _, p := range t.problems {
+		if meta, ok := t.metaCache.Cached(p.Id); ok {
+			p.Meta = meta
+		}
+		if filtering {
+			if p.Meta == nil {
+				continue
+			}
+			if source != "" && !strings.Contains(iasiutils.NormalizeRomanian(p.Meta.Source), source) {
+				continue
+			}
+			if minDifficulty > 0 && p.Meta.Difficulty < minDifficulty {
+				continue
+			}
+			if maxDifficulty > 0 && p.Meta.Difficulty > maxDifficulty {
+				continue
+			}
+		}
+		result = append(result, p)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Username string    `json:"username"`
+		Problems []Problem `json:"problems"`
+	}{t.username, result})
 }
 
-func fetchAllEntries(username string) ([]monitorRow, error) {
-	var records []monitorRow
-	pageSize := 250
-	for offset := 0; ; offset += pageSize {
-		url := fmt.Sprintf("https://www.infoarena.ro/monitor?user=%s&display_entries=%d&first_entry=%d", username, pageSize, offset)
-		resp, err := http.Get(url)
+// handleProblem serves the per-problem LLM Editorial/Hints API, keyed by problem slug.
+// POST /problems/{slug}/generate[?lang=ro&recipe=...&hints=...&level=...]
+// GET /problems/{slug}/generate/stream[?lang=ro&...]
+// POST /problems/{slug}/regenerate[?lang=ro&recipe=...&hints=...&level=...]
+// POST /problems/{slug}/hints/next[?lang=ro&recipe=...&hints=...&level=...]
+// GET /problems/{slug}/hints[?lang=ro]
+// POST /problems/{slug}/chat {"message": "...", "lang": "ro", "recipe": "...", "level": "..."}
+// POST /problems/{slug}/chat/stream {"message": "...", ...}
+// GET|DELETE /problems/{slug}/chat[?lang=ro]
+// GET /problems/{slug}/editorial[?lang=ro]
+// GET /problems/{slug}/editorial/revisions[?lang=ro]
+// GET /problems/{slug}/statement
+// GET /problems/{slug}/source[?download=1]
+func (t *tracker) handleProblem(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/problems/")
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		log.Printf("[ERROR] Invalid /problems/ path: %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	action := parts[1]
+	problem, ok := t.problem(id)
+	if !ok {
+		log.Printf("[WARN] Unknown problem %s", id)
+		http.Error(w, "Unknown problem: "+id, http.StatusNotFound)
+		return
+	}
+	if action == "generate" && len(parts) > 2 && parts[2] == "stream" && r.Method == "GET" {
+		t.streamGenerate(w, r, problem)
+		return
+	}
+	if (action == "generate" || action == "regenerate") && r.Method == "POST" {
+		log.Printf("[INFO] /problems/%s/%s POST called", id, action)
+		t.generateJob(w, r, problem, action == "regenerate")
+		return
+	}
+	if action == "hints" && len(parts) > 2 && parts[2] == "next" && r.Method == "POST" {
+		log.Printf("[INFO] /problems/%s/hints/next POST called", id)
+		opts, err := parseGenerateOptions(r.URL.Query().Get)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch URL: %w", err)
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
 		}
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		resp.Body.Close()
+		jsonBytes, status, err := t.nextHint(r.Context(), problem, opts)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse HTML: %w", err)
-		}
-		entriesOnPage := 0
-		doc.Find("table.monitor tbody tr").Each(func(i int, s *goquery.Selection) {
-			var row []string
-			problemUrl := ""
-			s.Find("td").Each(func(j int, td *goquery.Selection) {
-				row = append(row, strings.TrimSpace(td.Text()))
-				if j == 2 { // 3rd column: problem name and link
-					if a := td.Find("a"); a.Length() > 0 {
-						href, exists := a.Attr("href")
-						if exists && strings.HasPrefix(href, "/problema/") {
-							problemUrl = "https://www.infoarena.ro" + href
-						}
-					}
-				}
-			})
-			if len(row) > 0 {
-				records = append(records, monitorRow{fields: row, problemUrl: problemUrl})
-				entriesOnPage++
+			writeAPIError(w, status, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBytes)
+		return
+	}
+	if action == "hints" && len(parts) == 2 && r.Method == "GET" {
+		lang, err := parseLang(r.URL.Query().Get("lang"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chain, err := t.hints.Load(id, lang)
+		if os.IsNotExist(err) {
+			http.Error(w, "No hints revealed", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chain)
+		return
+	}
+	if action == "chat" && r.Method == "POST" {
+		log.Printf("[INFO] /problems/%s/chat POST called", id)
+		if len(parts) > 2 && parts[2] == "stream" {
+			t.streamChat(w, r, problem)
+			return
+		}
+		opts, message, err := parseChatRequest(r)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		jsonBytes, status, err := t.chat(r.Context(), problem, opts, message, nil)
+		if err != nil {
+			writeAPIError(w, status, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonBytes)
+		return
+	}
+	if action == "chat" && len(parts) == 2 && (r.Method == "GET" || r.Method == "DELETE") {
+		lang, err := parseLang(r.URL.Query().Get("lang"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == "DELETE" {
+			if err := t.chats.Delete(id, lang); err != nil {
+				log.Printf("[ERROR] Failed to delete the chat of %s: %v", id, err)
+				http.Error(w, err.Error(), 500)
+				return
 			}
-		})
-		if entriesOnPage < pageSize {
-			break // Last page reached
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		history, err := t.chats.Load(id, lang)
+		if os.IsNotExist(err) {
+			history, err = &iasiutils.ChatHistory{Messages: []iasiutils.ChatMessage{}, Lang: lang}, nil
+		}
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+		return
+	}
+	if action == "editorial" && r.Method == "GET" {
+		lang, err := parseLang(r.URL.Query().Get("lang"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(parts) > 2 && parts[2] == "revisions" {
+			revs, err := t.editorials.Revisions(id, lang)
+			if err != nil {
+				log.Printf("[ERROR] Failed to list revisions of %s: %v", id, err)
+				http.Error(w, "Failed to list revisions: "+err.Error(), 500)
+				return
+			}
+			if len(revs) == 0 {
+				http.Error(w, "Not generated", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(revs)
+			return
+		}
+		ed, err := t.editorials.Load(id, lang)
+		if err == nil {
+			log.Printf("[INFO] Editorial cache GET for %s (%s)", id, lang)
+			writeEditorial(w, ed)
+			return
+		}
+		if !os.IsNotExist(err) {
+			log.Printf("[ERROR] %v", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		log.Printf("[WARN] Editorial not generated for %s (%s)", id, lang)
+		http.Error(w, "Not generated", http.StatusNotFound)
+		return
+	}
+	if action == "statement" && r.Method == "GET" {
+		statementPath := t.statementPath(id)
+		if data, err := ioutil.ReadFile(statementPath); err == nil {
+			log.Printf("[INFO] Statement cache hit for %s", statementPath)
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Write(data)
+			return
 		}
+		ingestor := &iasiutils.InfoarenaIngestor{Fetcher: t.fetcher}
+		statement, err := ingestor.FetchProblemStatement(problem.Url)
+		if err != nil {
+			log.Printf("[ERROR] Failed to fetch statement for %s: %v", id, err)
+			http.Error(w, "Failed to fetch statement: "+err.Error(), 500)
+			return
+		}
+		markdown := statement.Markdown
+		if markdown == "" {
+			markdown = statement.Text
+		}
+		if err := os.MkdirAll(filepath.Dir(statementPath), 0755); err == nil {
+			ioutil.WriteFile(statementPath, []byte(markdown), 0644)
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(markdown))
+		log.Printf("[INFO] Statement for %s converted and returned.", id)
+		return
+	}
+	if action == "source" && r.Method == "GET" {
+		ingestor := &iasiutils.InfoarenaIngestor{Fetcher: t.fetcher}
+		source, err := ingestor.FetchSolution(problem.JobId)
+		if err != nil {
+			log.Printf("[ERROR] Failed to fetch source for %s: %v", id, err)
+			http.Error(w, "Failed to fetch source: "+err.Error(), 500)
+			return
+		}
+		if r.URL.Query().Get("download") != "" {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+source.Language.Ext()))
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(source.Code))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(source)
+		return
 	}
-	return records, nil
+	log.Printf("[ERROR] Unknown /problems/ action: %s", action)
+	http.NotFound(w, r)
 }
 
-// filter100PointEntries returns only entries with exactly 'Evaluare completa: 100 puncte' in the last column.
-func filter100PointEntries(records [][]string) [][]string {
-	var filtered [][]string
-	for _, record := range records {
-		if len(record) > 0 && strings.TrimSpace(record[len(record)-1]) == "Evaluare completa: 100 puncte" {
-			filtered = append(filtered, record)
-		}
+// Generation phases reported by /problems/{slug}/generate/stream.
+const (
+	phaseFetchingStatement = "fetching statement"
+	phaseFetchingSource    = "fetching source"
+	phaseGenerating        = "generating"
+	phaseRepairing         = "repairing"
+	phaseSaved             = "saved"
+)
+
+// generateOptions are the recipe choices of a generation request.
+type generateOptions struct {
+	Recipe string
+	Vars   iasiutils.PromptVars
+}
+
+// parseLang validates an output language code. Empty selects iasiutils.DefaultLang.
+func parseLang(lang string) (string, error) {
+	if lang == "" {
+		return iasiutils.DefaultLang, nil
+	}
+	if _, ok := iasiutils.OutputLanguages[lang]; !ok {
+		return "", fmt.Errorf("Unsupported lang %q", lang)
 	}
-	return filtered
+	return lang, nil
 }
 
-// groupByProblemEarliest groups by problem name (3rd column) and keeps the earliest submission (by 6th column, date).
-func groupByProblemEarliest(records [][]string) map[string][]string {
-	grouped := make(map[string][]string)
-	for _, record := range records {
-		if len(record) < 6 {
-			continue
+// parseGenerateOptions reads the lang, recipe, hints and level parameters through get.
+func parseGenerateOptions(get func(key string) string) (generateOptions, error) {
+	opts := generateOptions{Recipe: get("recipe"), Vars: iasiutils.PromptVars{Level: strings.TrimSpace(get("level"))}}
+	lang, err := parseLang(get("lang"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Vars.Lang = lang
+	if hints := get("hints"); hints != "" {
+		n, err := strconv.Atoi(hints)
+		if err != nil || n < 1 || n > 10 {
+			return opts, fmt.Errorf("hints must be a number between 1 and 10")
 		}
-		name := record[2]
-		date := record[5]
-		if prev, ok := grouped[name]; ok {
-			if compareInfoarenaDate(date, prev[5]) < 0 {
-				grouped[name] = record
+		opts.Vars.Hints = n
+	}
+	if len(opts.Vars.Level) > 40 {
+		return opts, fmt.Errorf("level must be at most 40 characters")
+	}
+	return opts, nil
+}
+
+// params returns the options as job parameters, omitting the defaults.
+func (o generateOptions) params() map[string]string {
+	params := map[string]string{}
+	if o.Recipe != "" {
+		params["recipe"] = o.Recipe
+	}
+	if o.Vars.Hints > 0 {
+		params["hints"] = strconv.Itoa(o.Vars.Hints)
+	}
+	if o.Vars.Level != "" {
+		params["level"] = o.Vars.Level
+	}
+	if o.Vars.Lang != "" && o.Vars.Lang != iasiutils.DefaultLang {
+		params["lang"] = o.Vars.Lang
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+// generate fetches the statement and the source of problem, generates its editorial with the recipe
+// chosen in opts, caches it as a new revision and returns it. phase is called as each step starts and onToken with the streamed model
+// output; both may be nil, which also disables streaming. On failure it returns the HTTP status and
+// message for the client.
+func (t *tracker) generate(ctx context.Context, problem Problem, opts generateOptions, phase func(string), onToken func(string)) (*iasiutils.EditorialRevision, int, error) {
+	if phase == nil {
+		phase = func(string) {}
+	}
+	recipe, status, err := t.loadRecipe(opts)
+	if err != nil {
+		return nil, status, err
+	}
+	if err := t.usage.Check(); err != nil {
+		log.Printf("[WARN] Refusing to generate %s: %v", problem.Id, err)
+		return nil, http.StatusTooManyRequests, err
+	}
+	statement, solution, err := t.fetchInputs(problem, phase)
+	if err != nil {
+		return nil, 500, err
+	}
+	log.Printf("[INFO] Problem and solution fetched. Building prompt with recipe %s.", recipe.Name)
+	phase(phaseGenerating)
+	var streamed func(int, string)
+	if onToken != nil {
+		current := 1
+		streamed = func(attempt int, chunk string) {
+			if attempt != current {
+				current = attempt
+				phase(phaseRepairing)
 			}
-		} else {
-			grouped[name] = record
+			onToken(chunk)
 		}
 	}
-	return grouped
+	editorial, err := iasiutils.StreamEditorial(ctx, t.llm, recipe, statement, solution, streamed)
+	if err != nil {
+		status, err := t.generationFailure(problem.Id, err)
+		return nil, status, err
+	}
+	t.recordUsage(problem.Id, editorial.Attempts, editorial.Usage)
+	if err := t.editorials.Save(problem.Id, opts.Vars.Lang, editorial); err != nil {
+		log.Printf("[ERROR] Failed to cache editorial of %s: %v", problem.Id, err)
+	} else {
+		log.Printf("[INFO] Saved revision %d of the %s editorial of %s", editorial.Revision, opts.Vars.Lang, problem.Id)
+	}
+	phase(phaseSaved)
+	return editorial, 200, nil
+}
+
+// loadRecipe loads the recipe chosen in opts and configures it from the environment. On failure it
+// returns the HTTP status and message for the client.
+func (t *tracker) loadRecipe(opts generateOptions) (*iasiutils.Recipe, int, error) {
+	recipe, err := iasiutils.LoadRecipe(t.recipesDir, opts.Recipe)
+	if errors.Is(err, iasiutils.ErrUnknownRecipe) {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load recipe: %v", err)
+		return nil, 500, fmt.Errorf("Failed to load recipe: %w", err)
+	}
+	recipe.Vars = opts.Vars
+	recipe.TokenBudget = iasiutils.EnvInt("IASI_PROMPT_TOKEN_BUDGET", 0)
+	recipe.Spoilers = &iasiutils.SpoilerPolicy{
+		FlagScore:   iasiutils.EnvFloat("IASI_LEAK_FLAG_SCORE", iasiutils.DefaultSpoilerPolicy.FlagScore),
+		RejectScore: iasiutils.EnvFloat("IASI_LEAK_REJECT_SCORE", iasiutils.DefaultSpoilerPolicy.RejectScore),
+	}
+	return recipe, 0, nil
+}
+
+// fetchInputs returns the statement and the solution of problem. They are scraped once, calling phase
+// as each fetch starts, and then read from t.inputs by every generation, hint step and chat message.
+func (t *tracker) fetchInputs(problem Problem, phase func(string)) (*iasiutils.ProblemStatement, *iasiutils.SourceFile, error) {
+	cached, err := t.inputs.Load(problem.Id, problem.JobId)
+	if err == nil {
+		log.Printf("[INFO] Inputs cache hit for %s (job %s)", problem.Id, problem.JobId)
+		return cached.Statement, cached.Source, nil
+	}
+	if !os.IsNotExist(err) {
+		log.Printf("[WARN] %v", err)
+	}
+	ingestor := &iasiutils.InfoarenaIngestor{Fetcher: t.fetcher}
+	log.Printf("[INFO] Fetching problem and solution for %s (job %s)", problem.Id, problem.JobId)
+	phase(phaseFetchingStatement)
+	jd, err := ingestor.FetchJobDetail(problem.JobId)
+	var statement *iasiutils.ProblemStatement
+	if err == nil {
+		statement, err = ingestor.FetchProblemStatement(jd.ProblemURL)
+	}
+	var solution *iasiutils.SourceFile
+	if err == nil {
+		phase(phaseFetchingSource)
+		solution, err = ingestor.FetchSource(problem.JobId, jd.Compiler)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch problem/solution: %v", err)
+		return nil, nil, fmt.Errorf("Failed to fetch problem/solution: %w", err)
+	}
+	if strings.TrimSpace(statement.String()) == "" || strings.TrimSpace(solution.Code) == "" {
+		log.Printf("[ERROR] Statement or solution missing. Statement: '%s' Solution: '%s'", iasiutils.TruncateString(statement.String(), 100), iasiutils.TruncateString(solution.Code, 100))
+		return nil, nil, fmt.Errorf("Problem statement or solution could not be fetched. Please check the Infoarena page structure.")
+	}
+	inputs := &iasiutils.ProblemInputs{JobID: problem.JobId, Statement: statement, Source: solution, FetchedAt: time.Now().UTC()}
+	if err := t.inputs.Save(problem.Id, inputs); err != nil {
+		log.Printf("[WARN] Failed to cache the inputs of %s: %v", problem.Id, err)
+	}
+	return statement, solution, nil
 }
 
-// sortByDate sorts the grouped map values by date (6th column), ascending.
-func sortByDate(grouped map[string][]string) [][]string {
-	var final [][]string
-	for _, v := range grouped {
-		final = append(final, v)
+// generationFailure records the model calls of a failed generation for slug and returns the HTTP
+// status and message for the client.
+func (t *tracker) generationFailure(slug string, err error) (int, error) {
+	var invalid *iasiutils.InvalidOutputError
+	if errors.As(err, &invalid) {
+		t.recordUsage(slug, invalid.Attempts, invalid.Usage)
+		log.Printf("[ERROR] %v", err)
+		return http.StatusBadGateway, fmt.Errorf("LLM output invalid: %w", err)
 	}
-	if len(final) > 1 {
-		type rowWithTime struct {
-			row []string
-			t   int64
+	if errors.Is(err, iasiutils.ErrPromptTooLong) {
+		log.Printf("[ERROR] %v", err)
+		return http.StatusUnprocessableEntity, err
+	}
+	log.Printf("[ERROR] LLM error: %v", err)
+	var llmErr *iasiutils.LLMError
+	if errors.As(err, &llmErr) {
+		return llmErrorStatus(llmErr.Kind), fmt.Errorf("LLM error: %w", err)
+	}
+	return 500, fmt.Errorf("LLM error: %w", err)
+}
+
+// nextHint reveals the next step of the hint chain of problem in the language of opts, starting the
+// chain if needed: a new hint, or the editorial once all the hints were given. The editorial is taken
+// from the cache when one was generated, and otherwise cached as a new revision once revealed. A finished chain is returned as it is. On failure it returns
+// the HTTP status and message for the client.
+func (t *tracker) nextHint(ctx context.Context, problem Problem, opts generateOptions) ([]byte, int, error) {
+	lang := opts.Vars.Lang
+	unlock := t.lock("hints", problem.Id, lang)
+	defer unlock()
+
+	chain, err := t.hints.Load(problem.Id, lang)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[ERROR] %v", err)
+		return nil, 500, err
+	}
+	if chain == nil {
+		chain = &iasiutils.HintChain{Hints: []string{}, Steps: opts.Vars.Hints, Lang: lang}
+		if chain.Steps == 0 {
+			chain.Steps = iasiutils.DefaultHintSteps
 		}
-		var rows []rowWithTime
-		for _, r := range final {
-			tm, err := parseInfoarenaDate(r[5])
-			var t int64
-			if err == nil {
-				t = tm.Unix()
-			}
-			rows = append(rows, rowWithTime{row: r, t: t})
+	}
+	if !chain.Done {
+		if opts.Recipe == "" {
+			opts.Recipe = chain.Recipe
 		}
-		// Sort by t
-		for i := 0; i < len(rows)-1; i++ {
-			for j := 0; j < len(rows)-i-1; j++ {
-				if rows[j].t > rows[j+1].t {
-					rows[j], rows[j+1] = rows[j+1], rows[j]
-				}
-			}
+		step, status, err := t.hintStep(ctx, problem, opts, chain)
+		if err != nil {
+			return nil, status, err
 		}
-		final = nil
-		for _, r := range rows {
-			final = append(final, r.row)
+		chain.Add(step)
+		if err := t.hints.Save(problem.Id, lang, chain); err != nil {
+			log.Printf("[ERROR] Failed to save hint chain of %s: %v", problem.Id, err)
+			return nil, 500, err
 		}
+		log.Printf("[INFO] Hint chain of %s (%s): %d of %d hints revealed, editorial revealed: %v", problem.Id, lang, len(chain.Hints), chain.Steps, chain.Done)
 	}
-	return final
+	jsonBytes, _ := json.MarshalIndent(chain, "", "  ")
+	return jsonBytes, 200, nil
 }
 
-// writeCSV writes the records to a CSV file.
-func writeCSV(filename string, records []monitorRow) error {
-	file, err := os.Create(filename)
+// lock acquires the lock of the kind of data kept for slug in lang and returns its release.
+func (t *tracker) lock(kind, slug, lang string) func() {
+	l, _ := t.locks.LoadOrStore(kind+":"+slug+"."+lang, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	return l.(*sync.Mutex).Unlock
+}
+
+// maxChatMessage is the length limit of a student's chat message, in bytes.
+const maxChatMessage = 4000
+
+// chat answers message in the conversation about problem in the language of opts and saves both
+// messages. onToken is called with the reply as it is streamed; nil disables streaming. On failure
+// it returns the HTTP status and message for the client.
+func (t *tracker) chat(ctx context.Context, problem Problem, opts generateOptions, message string, onToken func(string)) ([]byte, int, error) {
+	lang := opts.Vars.Lang
+	unlock := t.lock("chat", problem.Id, lang)
+	defer unlock()
+
+	history, err := t.chats.Load(problem.Id, lang)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[ERROR] %v", err)
+		return nil, 500, err
+	}
+	if history == nil {
+		history = &iasiutils.ChatHistory{Messages: []iasiutils.ChatMessage{}, Lang: lang}
+	}
+	if opts.Recipe == "" {
+		opts.Recipe = history.Recipe
+	}
+	recipe, status, err := t.loadRecipe(opts)
 	if err != nil {
-		return err
+		return nil, status, err
 	}
-	defer file.Close()
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := t.usage.Check(); err != nil {
+		log.Printf("[WARN] Refusing to chat about %s: %v", problem.Id, err)
+		return nil, http.StatusTooManyRequests, err
+	}
+	statement, solution, err := t.fetchInputs(problem, func(string) {})
+	if err != nil {
+		return nil, 500, err
+	}
+	question := iasiutils.ChatMessage{Role: iasiutils.ChatRoleUser, Content: message, CreatedAt: time.Now().UTC()}
+	reply, err := iasiutils.Chat(ctx, t.llm, recipe, statement, solution, history.Messages, message, onToken)
+	if err != nil {
+		status, err := t.generationFailure(problem.Id, err)
+		return nil, status, err
+	}
+	t.recordUsage(problem.Id, 1, reply.Usage)
+	history.Messages = append(history.Messages, question, reply.Message)
+	history.Recipe = recipe.Name
+	history.Calls++
+	history.Usage = history.Usage.Add(reply.Usage)
+	history.UpdatedAt = reply.Message.CreatedAt
+	if err := t.chats.Save(problem.Id, lang, history); err != nil {
+		log.Printf("[ERROR] Failed to save the chat of %s: %v", problem.Id, err)
+		return nil, 500, err
+	}
+	jsonBytes, _ := json.MarshalIndent(reply.Message, "", "  ")
+	return jsonBytes, 200, nil
+}
 
-	// Write header
-	writer.Write([]string{"name", "url", "url_solution", "time"})
-
-	for _, r := range records {
-		fields := r.fields
-		if len(fields) >= 6 {
-			id := fields[0]
-			if strings.HasPrefix(id, "#") {
-				id = id[1:]
-			}
-			urlSolution := "https://www.infoarena.ro/job_detail/" + id
-			pruned := []string{fields[2], r.problemUrl, urlSolution, fields[5]}
-			writer.Write(pruned)
+// parseChatRequest reads the JSON body of a chat message.
+func parseChatRequest(r *http.Request) (generateOptions, string, error) {
+	var req struct {
+		Message string `json:"message"`
+		Lang    string `json:"lang"`
+		Recipe  string `json:"recipe"`
+		Level   string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Message) == "" {
+		return generateOptions{}, "", fmt.Errorf(`Expected a JSON body like {"message": "<question>"}`)
+	}
+	if len(req.Message) > maxChatMessage {
+		return generateOptions{}, "", fmt.Errorf("message must be at most %d characters", maxChatMessage)
+	}
+	opts, err := parseGenerateOptions(url.Values{"lang": {req.Lang}, "recipe": {req.Recipe}, "level": {req.Level}}.Get)
+	return opts, strings.TrimSpace(req.Message), err
+}
+
+// hintStep produces the step of chain after its revealed hints, see nextHint.
+func (t *tracker) hintStep(ctx context.Context, problem Problem, opts generateOptions, chain *iasiutils.HintChain) (*iasiutils.HintStep, int, error) {
+	if len(chain.Hints) >= chain.Steps {
+		if ed, err := t.editorials.Load(problem.Id, chain.Lang); err == nil {
+			log.Printf("[INFO] Ending the hint chain of %s with the cached editorial", problem.Id)
+			return &iasiutils.HintStep{Text: ed.Editorial.Editorial, Final: true, Leak: ed.Leak}, 0, nil
+		}
+	}
+	recipe, status, err := t.loadRecipe(opts)
+	if err != nil {
+		return nil, status, err
+	}
+	chain.Recipe = recipe.Name
+	if err := t.usage.Check(); err != nil {
+		log.Printf("[WARN] Refusing to generate a hint for %s: %v", problem.Id, err)
+		return nil, http.StatusTooManyRequests, err
+	}
+	statement, solution, err := t.fetchInputs(problem, func(string) {})
+	if err != nil {
+		return nil, 500, err
+	}
+	step, err := iasiutils.NextHint(ctx, t.llm, recipe, statement, solution, chain)
+	if err != nil {
+		status, err := t.generationFailure(problem.Id, err)
+		return nil, status, err
+	}
+	t.recordUsage(problem.Id, step.Attempts, step.Usage)
+	if step.Final {
+		// Cache the editorial like a generated one, so it is served and not generated again.
+		if err := t.editorials.Save(problem.Id, chain.Lang, chain.EditorialRevision(step, recipe)); err != nil {
+			log.Printf("[ERROR] Failed to cache the editorial ending the hint chain of %s: %v", problem.Id, err)
+		} else {
+			log.Printf("[INFO] Saved the editorial ending the hint chain of %s (%s)", problem.Id, chain.Lang)
+		}
+	}
+	return step, 0, nil
+}
+
+// llmErrorStatus is the HTTP status a generation failing with an LLM error of kind is answered with.
+func llmErrorStatus(kind iasiutils.LLMErrorKind) int {
+	switch kind {
+	case iasiutils.LLMRateLimited:
+		return http.StatusTooManyRequests
+	case iasiutils.LLMOverloaded, iasiutils.LLMUnavailable:
+		return http.StatusServiceUnavailable
+	case iasiutils.LLMSafetyBlocked:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadGateway
+}
+
+// apiError is the JSON body of a failed generation.
+type apiError struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+	// Kind classifies the failure: an iasiutils.LLMErrorKind, "usage_limit" or empty.
+	Kind     string `json:"kind,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// RetryAfterSeconds is how long the LLM backend asked to wait before trying again.
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+}
+
+// newAPIError describes err, answered with status, for the client.
+func newAPIError(status int, err error) apiError {
+	e := apiError{Status: status, Error: err.Error()}
+	var llmErr *iasiutils.LLMError
+	switch {
+	case errors.As(err, &llmErr):
+		e.Kind = string(llmErr.Kind)
+		e.Provider = llmErr.Provider
+		e.Model = llmErr.Model
+		e.RetryAfterSeconds = int(math.Ceil(llmErr.RetryAfter.Seconds()))
+	case errors.Is(err, iasiutils.ErrUsageLimit):
+		e.Kind = "usage_limit"
+	}
+	return e
+}
+
+// writeAPIError answers with status and err as an apiError.
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	e := newAPIError(status, err)
+	if e.RetryAfterSeconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfterSeconds))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// recordUsage adds the model calls of a generation to the usage ledger.
+func (t *tracker) recordUsage(slug string, calls int, usage iasiutils.TokenUsage) {
+	if err := t.usage.Record(slug, calls, usage); err != nil {
+		log.Printf("[WARN] Failed to record LLM usage of %s: %v", slug, err)
+	}
+}
+
+// writeEditorial serves ed encoded like the editorials returned by generate.
+func writeEditorial(w http.ResponseWriter, ed *iasiutils.EditorialRevision) {
+	data, _ := json.MarshalIndent(ed, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// generateJob answers POST /problems/{slug}/generate and /regenerate with the editorial of a job of
+// t.jobs, so the generation is shared with the identical jobs queued by /jobs or other requests and
+// counts against the workers limit. The job id is sent in the X-Job-Id header; a client giving up does
+// not stop the job. generate answers from the cache when it can; regenerate always makes a new revision.
+func (t *tracker) generateJob(w http.ResponseWriter, r *http.Request, problem Problem, regenerate bool) {
+	opts, err := parseGenerateOptions(r.URL.Query().Get)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if ed, err := t.editorials.Load(problem.Id, opts.Vars.Lang); err == nil && !regenerate {
+		log.Printf("[INFO] Editorial cache hit for %s (%s)", problem.Id, opts.Vars.Lang)
+		writeEditorial(w, ed)
+		return
+	}
+	if _, status, err := t.loadRecipe(opts); err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	job, _, err := t.jobs.Submit(problem.Id, jobParams(opts, regenerate))
+	if errors.Is(err, iasiutils.ErrJobConflict) {
+		writeAPIError(w, http.StatusConflict, fmt.Errorf("Job %s for %s is still %s with other options; wait for it to finish", job.ID, job.Problem, job.State))
+		return
+	}
+	w.Header().Set("X-Job-Id", job.ID)
+	if _, err := t.jobs.Wait(r.Context(), job.ID); err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("[INFO] Client stopped waiting for job %s of %s, it keeps running", job.ID, problem.Id)
+			return
+		}
+		status := 500
+		var failed *jobError
+		if errors.As(err, &failed) {
+			status = failed.status
+		}
+		writeAPIError(w, status, err)
+		return
+	}
+	ed, err := t.editorials.Load(problem.Id, opts.Vars.Lang)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		writeAPIError(w, 500, err)
+		return
+	}
+	writeEditorial(w, ed)
+	log.Printf("[INFO] Editorial for %s generated by job %s and returned.", problem.Id, job.ID)
+}
+
+// jobParams returns the job parameters of a generation with opts. Regenerating sets "regenerate", so
+// runJob ignores the cached editorial.
+func jobParams(opts generateOptions, regenerate bool) map[string]string {
+	params := opts.params()
+	if regenerate {
+		if params == nil {
+			params = map[string]string{}
 		}
+		params["regenerate"] = "1"
+	}
+	return params
+}
+
+// jobError is the error of a failed job with the HTTP status its generation failed with.
+type jobError struct {
+	status int
+	err    error
+}
+
+func (e *jobError) Error() string { return e.err.Error() }
+func (e *jobError) Unwrap() error { return e.err }
+
+// runJob generates the editorial of slug for the job queue, unless it is already cached and the job
+// does not regenerate it.
+func (t *tracker) runJob(ctx context.Context, slug string, params map[string]string, phase func(string)) error {
+	problem, ok := t.problem(slug)
+	if !ok {
+		return fmt.Errorf("Unknown problem: %s", slug)
+	}
+	opts, err := parseGenerateOptions(func(key string) string { return params[key] })
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(t.editorials.Path(slug, opts.Vars.Lang)); err == nil && params["regenerate"] == "" {
+		log.Printf("[INFO] Editorial cache hit for %s (%s)", slug, opts.Vars.Lang)
+		return nil
+	}
+	if _, status, err := t.generate(ctx, problem, opts, phase, nil); err != nil {
+		return &jobError{status: status, err: err}
 	}
 	return nil
 }
 
-// parseInfoarenaDate parses dates like "1 apr 25 13:06:35" to time.Time.
-func parseInfoarenaDate(s string) (time.Time, error) {
-	months := map[string]string{"ian": "Jan", "feb": "Feb", "mar": "Mar", "apr": "Apr", "mai": "May", "iun": "Jun", "iul": "Jul", "aug": "Aug", "sep": "Sep", "oct": "Oct", "nov": "Nov", "dec": "Dec"}
-	parts := strings.Split(s, " ")
-	if len(parts) != 4 {
-		return time.Time{}, fmt.Errorf("invalid date format")
+// handleJobs queues the generation of a problem's editorial; "regenerate" makes a new revision even when
+// one is cached.
+// POST /jobs {"problem": "<slug>", "lang": "ro", "recipe": "default", "hints": 3, "level": "beginner", "regenerate": false}
+func (t *tracker) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Problem string `json:"problem"`
+		Lang    string `json:"lang"`
+		Recipe  string `json:"recipe"`
+		Hints   int    `json:"hints"`
+		Level   string `json:"level"`
+		// Regenerate replaces a cached editorial with a new revision.
+		Regenerate bool `json:"regenerate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Problem == "" {
+		http.Error(w, `Expected a JSON body like {"problem": "<slug>"}`, http.StatusBadRequest)
+		return
+	}
+	if _, ok := t.problem(req.Problem); !ok {
+		log.Printf("[WARN] Unknown problem %s", req.Problem)
+		http.Error(w, "Unknown problem: "+req.Problem, http.StatusNotFound)
+		return
+	}
+	hints := ""
+	if req.Hints != 0 {
+		hints = strconv.Itoa(req.Hints)
+	}
+	opts, err := parseGenerateOptions(url.Values{"lang": {req.Lang}, "recipe": {req.Recipe}, "hints": {hints}, "level": {req.Level}}.Get)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := iasiutils.LoadRecipe(t.recipesDir, opts.Recipe); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job, created, err := t.jobs.Submit(req.Problem, jobParams(opts, req.Regenerate))
+	if errors.Is(err, iasiutils.ErrJobConflict) {
+		http.Error(w, fmt.Sprintf("Job %s for %s is still %s with other options; wait for it to finish", job.ID, job.Problem, job.State), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleJob reports the state of a generation job.
+// GET /jobs/{id}
+func (t *tracker) handleJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	day := parts[0]
-	mon, ok := months[parts[1]]
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	job, ok := t.jobs.Get(id)
+	if !ok {
+		http.Error(w, "Unknown job: "+id, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// sseWriter sends Server-Sent Events, flushing each one to the client as it is written.
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter starts an event stream on w. When w cannot stream it answers 500 and returns false.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", 500)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	return &sseWriter{w: w, flusher: flusher}, true
+}
+
+// send writes an event with data encoded as JSON.
+func (s *sseWriter) send(event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.flusher.Flush()
+}
+
+// token sends a "token" event with a chunk of the model output.
+func (s *sseWriter) token(chunk string) {
+	s.send("token", map[string]string{"text": chunk})
+}
+
+// fail sends a "failure" event with err as the apiError answered with status.
+func (s *sseWriter) fail(status int, err error) {
+	s.send("failure", newAPIError(status, err))
+}
+
+// streamGenerate serves GET /problems/{slug}/generate/stream as Server-Sent Events: "phase" events as
+// generation progresses, "token" events with the model output, then one "result" event with the editorial
+// JSON or one "failure" event with the status and error. A cached editorial is sent as the result at once.
+func (t *tracker) streamGenerate(w http.ResponseWriter, r *http.Request, problem Problem) {
+	sse, ok := newSSEWriter(w)
 	if !ok {
-		return time.Time{}, fmt.Errorf("invalid month")
+		return
 	}
-	year := parts[2]
-	timepart := parts[3]
-	dateStr := fmt.Sprintf("%s %s %s %s", day, mon, year, timepart)
-	return time.Parse("2 Jan 06 15:04:05", dateStr)
+	log.Printf("[INFO] /problems/%s/generate/stream called", problem.Id)
+	opts, err := parseGenerateOptions(r.URL.Query().Get)
+	if err != nil {
+		sse.fail(http.StatusBadRequest, err)
+		return
+	}
+	if ed, err := t.editorials.Load(problem.Id, opts.Vars.Lang); err == nil {
+		log.Printf("[INFO] Editorial cache hit for %s (%s)", problem.Id, opts.Vars.Lang)
+		sse.send("result", ed)
+		return
+	}
+	ed, status, err := t.generate(r.Context(), problem, opts,
+		func(phase string) { sse.send("phase", map[string]string{"phase": phase}) }, sse.token)
+	if err != nil {
+		sse.fail(status, err)
+		return
+	}
+	sse.send("result", ed)
+	log.Printf("[INFO] Editorial for %s streamed and returned.", problem.Id)
 }
 
-// compareInfoarenaDate compares two infoarena date strings. Returns -1 if a < b, 1 if a > b, 0 if equal or error.
-func compareInfoarenaDate(a, b string) int {
-	ta, ea := parseInfoarenaDate(a)
-	tb, eb := parseInfoarenaDate(b)
-	if ea != nil || eb != nil {
-		return 0
+// streamChat answers a chat message as server-sent events: "token" events with the reply as it is
+// generated, then a "result" event with the saved reply or a "failure" event.
+// POST /problems/{slug}/chat/stream {"message": "...", "lang": "ro"}
+func (t *tracker) streamChat(w http.ResponseWriter, r *http.Request, problem Problem) {
+	opts, message, err := parseChatRequest(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
 	}
-	if ta.Before(tb) {
-		return -1
+	sse, ok := newSSEWriter(w)
+	if !ok {
+		return
 	}
-	if ta.After(tb) {
-		return 1
+	jsonBytes, status, err := t.chat(r.Context(), problem, opts, message, sse.token)
+	if err != nil {
+		sse.fail(status, err)
+		return
 	}
-	return 0
+	sse.send("result", json.RawMessage(jsonBytes))
+}
+
+// openBrowser tries to open the URL in the default browser (Windows only for now).
+func openBrowser(url string) {
+	execCmd := "start " + url
+	_ = execCommand(execCmd)
+}
+
+// execCommand runs a shell command (Windows only).
+func execCommand(cmd string) error {
+	return exec.Command("cmd", "/C", cmd).Start()
+}
+
+// buildTimeline aggregates the user's submissions per problem, ordered by first AC (or first attempt when unsolved).
+func buildTimeline(subs []iasiutils.Submission) []iasiutils.ProblemStats {
+	return iasiutils.AggregateByProblem(subs)
+}
+
+// writeCSV writes the per-problem timeline to a CSV file.
+func writeCSV(filename string, timeline []iasiutils.ProblemStats) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// Write header
+	writer.Write([]string{"name", "url", "url_solution", "time", "solved", "best_score", "attempts", "first_attempt", "time_to_first_ac"})
+
+	for _, ps := range timeline {
+		timeToAC := ""
+		if ps.Solved {
+			timeToAC = ps.TimeToFirstAC.String()
+		}
+		writer.Write([]string{
+			ps.Name,
+			ps.Representative.ProblemURL(),
+			ps.Representative.SolutionURL(),
+			formatTime(ps.Time()),
+			strconv.FormatBool(ps.Solved),
+			strconv.Itoa(ps.BestScore),
+			strconv.Itoa(ps.Attempts),
+			formatTime(ps.FirstAttempt),
+			timeToAC,
+		})
+	}
+	return nil
+}
+
+// formatTime formats submission times for the CSV and the tracker API.
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
 }
@@ -0,0 +1,1136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"iasi/internal/iasiutils"
+)
+
+// stubFetcher serves canned Infoarena pages keyed by URL.
+type stubFetcher map[string]string
+
+func (sf stubFetcher) Get(url string) ([]byte, error) {
+	if body, ok := sf[url]; ok {
+		return []byte(body), nil
+	}
+	return nil, fmt.Errorf("stub: no page for %s", url)
+}
+
+func (sf stubFetcher) PostForm(url, form string) ([]byte, error) {
+	return sf.Get(url)
+}
+
+// countingFetcher is a stubFetcher counting the pages it served.
+type countingFetcher struct {
+	stubFetcher
+	mu   sync.Mutex
+	gets int
+}
+
+func (cf *countingFetcher) Get(url string) ([]byte, error) {
+	cf.mu.Lock()
+	cf.gets++
+	cf.mu.Unlock()
+	return cf.stubFetcher.Get(url)
+}
+
+func (cf *countingFetcher) PostForm(url, form string) ([]byte, error) {
+	return cf.Get(url)
+}
+
+func (cf *countingFetcher) Gets() int {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.gets
+}
+
+var infoarenaPages = stubFetcher{
+	"https://www.infoarena.ro/job_detail/101": `<html><body>
+<a href="/problema/adunare">adunare</a>
+<table><tr><td>Compilator</td><td>cpp-64</td></tr></table>
+</body></html>`,
+	"https://www.infoarena.ro/problema/adunare": `<html><body><div class="wiki_text_block">
+<h1>Adunare</h1>
+<h2>Cerinţă</h2>
+<p>Se dau doua numere a si b. Afisati a+b.</p>
+</div></body></html>`,
+	"https://www.infoarena.ro/job_detail/101?action=view-source": `<html><body>
+<pre>#include &lt;iostream&gt;
+int main() { long long a, b; std::cin &gt;&gt; a &gt;&gt; b; std::cout &lt;&lt; a + b; }</pre>
+</body></html>`,
+}
+
+const validEditorial = `{"hints":["Read both numbers.","Mind the overflow."],"editorial":"Print a+b using 64-bit integers."}`
+
+// newTestServer starts the tracker API for one solved problem, caching under a temp directory.
+func newTestServer(t *testing.T, llm iasiutils.LLMProvider) *httptest.Server {
+	t.Helper()
+	return newTestServerIn(t, llm, t.TempDir())
+}
+
+// newTestServerIn is newTestServer with the data directory given, resuming the jobs persisted in it.
+func newTestServerIn(t *testing.T, llm iasiutils.LLMProvider, dataDir string) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, infoarenaPages, llm, dataDir)
+}
+
+// newTestServerWith is newTestServerIn serving pages instead of infoarenaPages.
+func newTestServerWith(t *testing.T, pages iasiutils.Fetcher, llm iasiutils.LLMProvider, dataDir string) *httptest.Server {
+	t.Helper()
+	log.SetOutput(ioutil.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	timeline := iasiutils.AggregateByProblem([]iasiutils.Submission{{
+		JobID: "101", User: "mentor", ProblemSlug: "adunare", ProblemName: "Adunare",
+		SubmittedAt: at, Status: "Evaluare completa: 100 puncte", State: iasiutils.EvalComplete, Score: 100,
+	}})
+	tr := newTracker(pages, llm, "mentor", dataDir, timeline)
+	ctx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	tr.jobs.Context = ctx
+	if err := tr.jobs.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	tr.routes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doRequest(t *testing.T, method, url string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func decodeEditorial(t *testing.T, body string) (hints []string, editorial string) {
+	t.Helper()
+	var out struct {
+		Hints     []string `json:"hints"`
+		Editorial string   `json:"editorial"`
+	}
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, body)
+	}
+	return out.Hints, out.Editorial
+}
+
+// decodeAPIError decodes the JSON error body of a failed generation.
+func decodeAPIError(t *testing.T, body string) apiError {
+	t.Helper()
+	var e apiError
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatalf("error body is not JSON: %v: %s", err, body)
+	}
+	return e
+}
+
+func TestGenerateCacheMissThenHit(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	srv := newTestServer(t, llm)
+
+	status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial")
+	if status != http.StatusNotFound {
+		t.Fatalf("editorial before generate: got %d, want 404", status)
+	}
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	hints, editorial := decodeEditorial(t, body)
+	if len(hints) != 2 || editorial != "Print a+b using 64-bit integers." {
+		t.Fatalf("generate: unexpected editorial %q, hints %q", editorial, hints)
+	}
+	calls := llm.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("generate: got %d LLM calls, want 1", len(calls))
+	}
+	if !strings.Contains(calls[0].Prompt, "Afisati a+b") || !strings.Contains(calls[0].Prompt, "std::cin") {
+		t.Errorf("prompt is missing the statement or the source:\n%s", calls[0].Prompt)
+	}
+
+	status, cached := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK || cached != body {
+		t.Fatalf("second generate: got %d %q, want the cached %q", status, cached, body)
+	}
+	if n := len(llm.Calls()); n != 1 {
+		t.Fatalf("second generate called the LLM again (%d calls)", n)
+	}
+
+	status, stored := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial")
+	if status != http.StatusOK || stored != body {
+		t.Fatalf("editorial: got %d %q, want %q", status, stored, body)
+	}
+}
+
+func TestGenerateExtractsJSONFromProse(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: "Sure! Here it is:\n```json\n" + validEditorial + "\n```\nGood luck."})
+	srv := newTestServer(t, llm)
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	if hints, _ := decodeEditorial(t, body); len(hints) != 2 {
+		t.Fatalf("generate: JSON was not extracted: %s", body)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusOK {
+		t.Fatalf("extracted editorial was not cached: got %d", status)
+	}
+}
+
+func TestGenerateRepairsInvalidOutput(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: `{"hints": ["unterminated"`},
+		iasiutils.FakeStep{Text: validEditorial},
+	)
+	srv := newTestServer(t, llm)
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	if hints, _ := decodeEditorial(t, body); len(hints) != 2 {
+		t.Fatalf("generate: repaired editorial not returned: %s", body)
+	}
+	calls := llm.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d LLM calls, want 2", len(calls))
+	}
+	if !strings.Contains(calls[1].Prompt, `{"hints": ["unterminated"`) || !strings.Contains(calls[1].Prompt, "rejected because") {
+		t.Errorf("repair prompt does not show the rejected output:\n%s", calls[1].Prompt)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusOK {
+		t.Fatalf("repaired editorial was not cached: got %d", status)
+	}
+}
+
+func TestGenerateInvalidOutputIsNotCached(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: "I cannot answer in JSON."},
+		iasiutils.FakeStep{Text: `{"hints":[],"editorial":""}`},
+	)
+	srv := newTestServer(t, llm)
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if e := decodeAPIError(t, body); status != http.StatusBadGateway || !strings.Contains(e.Error, `"editorial" is missing`) {
+		t.Fatalf("generate: got %d %q, want 502 listing the schema problems", status, body)
+	}
+	if n := len(llm.Calls()); n != 2 {
+		t.Fatalf("got %d LLM calls, want 2", n)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusNotFound {
+		t.Fatalf("invalid editorial was cached: got %d", status)
+	}
+}
+
+func TestGenerateRejectsSpoilers(t *testing.T) {
+	leaky := `{"hints":["` + "```cpp\\nstd::cin >> a >> b;\\n```" + `","Mind the overflow."],"editorial":"Write long long a, b; std::cin >> a >> b; std::cout << a + b;"}`
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: leaky},
+		iasiutils.FakeStep{Text: validEditorial},
+		iasiutils.FakeStep{Text: leaky},
+	)
+	srv := newTestServer(t, llm)
+	decodeLeak := func(body string) *iasiutils.LeakReport {
+		t.Helper()
+		var rev iasiutils.EditorialRevision
+		if err := json.Unmarshal([]byte(body), &rev); err != nil {
+			t.Fatalf("response is not JSON: %v\n%s", err, body)
+		}
+		if rev.Leak == nil {
+			t.Fatalf("no leak report recorded: %s", body)
+		}
+		return rev.Leak
+	}
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	if leak := decodeLeak(body); leak.Score != 0 || leak.Flagged {
+		t.Errorf("repaired editorial has leak %+v, want none", leak)
+	}
+	calls := llm.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d LLM calls, want 2", len(calls))
+	}
+	if !strings.Contains(calls[1].Prompt, "gives away the solution") || !strings.Contains(calls[1].Prompt, "hint 1 contains code") {
+		t.Errorf("repair prompt does not explain the leak:\n%s", calls[1].Prompt)
+	}
+	if _, body := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); decodeLeak(body).Score != 0 {
+		t.Errorf("leak score was not cached: %s", body)
+	}
+
+	t.Setenv("IASI_LEAK_REJECT_SCORE", "0")
+	status, body = doRequest(t, "POST", srv.URL+"/problems/adunare/regenerate")
+	if status != http.StatusOK {
+		t.Fatalf("regenerate without rejection: got %d: %s", status, body)
+	}
+	if leak := decodeLeak(body); !leak.Flagged || leak.Score < 0.7 || leak.Overlap == 0 {
+		t.Errorf("leaky editorial has leak %+v, want it flagged", leak)
+	}
+}
+
+func TestHintChain(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: "Think about what the answer is made of."},
+		iasiutils.FakeStep{Text: "Mind the overflow."},
+		iasiutils.FakeStep{Text: "## Solution\nAdd the two numbers using 64-bit integers."},
+		iasiutils.FakeStep{Text: "Start from the statement."},
+		iasiutils.FakeStep{Text: validEditorial},
+	)
+	pages := &countingFetcher{stubFetcher: infoarenaPages}
+	srv := newTestServerWith(t, pages, llm, t.TempDir())
+	next := func(query string) iasiutils.HintChain {
+		t.Helper()
+		status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/hints/next"+query)
+		if status != http.StatusOK {
+			t.Fatalf("hints/next: got %d: %s", status, body)
+		}
+		var chain iasiutils.HintChain
+		if err := json.Unmarshal([]byte(body), &chain); err != nil {
+			t.Fatalf("response is not JSON: %v\n%s", err, body)
+		}
+		return chain
+	}
+
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/hints"); status != http.StatusNotFound {
+		t.Fatalf("hints before any was revealed: got %d, want 404", status)
+	}
+	if chain := next("?hints=2"); len(chain.Hints) != 1 || chain.Steps != 2 || chain.Done {
+		t.Fatalf("first step: got %+v", chain)
+	}
+	fetched := pages.Gets()
+	if chain := next(""); len(chain.Hints) != 2 || chain.Done {
+		t.Fatalf("second step: got %+v", chain)
+	}
+	if chain := next(""); !chain.Done || !strings.Contains(chain.Editorial, "64-bit") || chain.Calls != 3 {
+		t.Fatalf("final step: got %+v", chain)
+	}
+	if pages.Gets() != fetched {
+		t.Errorf("later steps scraped Infoarena again: %d fetches after the first step, %d now", fetched, pages.Gets())
+	}
+	if again := next(""); again.Calls != 3 || len(llm.Calls()) != 3 {
+		t.Fatalf("finished chain was extended: %+v", again)
+	}
+	// The editorial ending the chain is cached like a generated one.
+	if status, body := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusOK || !strings.Contains(body, "64-bit") {
+		t.Fatalf("editorial after the chain: got %d: %s", status, body)
+	}
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate"); status != http.StatusOK || len(llm.Calls()) != 3 {
+		t.Fatalf("generate after the chain: got %d after %d calls: %s", status, len(llm.Calls()), body)
+	}
+	if status, body := doRequest(t, "GET", srv.URL+"/problems/adunare/hints"); status != http.StatusOK || !strings.Contains(body, "64-bit") {
+		t.Fatalf("hints: got %d: %s", status, body)
+	}
+
+	// With an editorial cached, the chain ends with it instead of calling the model.
+	if chain := next("?lang=ro&hints=1"); len(chain.Hints) != 1 || chain.Lang != "ro" {
+		t.Fatalf("ro first step: got %+v", chain)
+	}
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?lang=ro"); status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	if chain := next("?lang=ro"); !chain.Done || chain.Editorial != "Print a+b using 64-bit integers." || len(llm.Calls()) != 5 {
+		t.Fatalf("ro final step: got %+v after %d calls", chain, len(llm.Calls()))
+	}
+	if pages.Gets() != fetched {
+		t.Errorf("generating scraped Infoarena again: %d fetches, want %d", pages.Gets(), fetched)
+	}
+}
+
+func TestChat(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: "Because the sum can exceed 32 bits."},
+		iasiutils.FakeStep{Text: "Think about the range of a+b."},
+	)
+	pages := &countingFetcher{stubFetcher: infoarenaPages}
+	srv := newTestServerWith(t, pages, llm, t.TempDir())
+	post := func(path, body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/problems/adunare/"+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	history := func() iasiutils.ChatHistory {
+		t.Helper()
+		status, body := doRequest(t, "GET", srv.URL+"/problems/adunare/chat")
+		var h iasiutils.ChatHistory
+		if status != http.StatusOK || json.Unmarshal([]byte(body), &h) != nil {
+			t.Fatalf("chat history: got %d: %s", status, body)
+		}
+		return h
+	}
+
+	if status, body := post("chat", `{"message": "  "}`); status != http.StatusBadRequest {
+		t.Fatalf("empty message: got %d: %s, want 400", status, body)
+	}
+	status, body := post("chat", `{"message": "Why is int not enough?"}`)
+	var reply iasiutils.ChatMessage
+	if status != http.StatusOK || json.Unmarshal([]byte(body), &reply) != nil || reply.Role != iasiutils.ChatRoleAssistant || !strings.Contains(reply.Content, "32 bits") {
+		t.Fatalf("chat: got %d: %s", status, body)
+	}
+	fetched := pages.Gets()
+
+	status, body = post("chat/stream", `{"message": "What should I look at?"}`)
+	if status != http.StatusOK || !strings.Contains(body, "event: token") || !strings.Contains(body, "event: result") {
+		t.Fatalf("chat stream: got %d: %s", status, body)
+	}
+	if pages.Gets() != fetched {
+		t.Errorf("the second message scraped Infoarena again: %d fetches after the first, %d now", fetched, pages.Gets())
+	}
+	if len(llm.Calls()[1].History) != 2 {
+		t.Errorf("second call does not continue the conversation: %+v", llm.Calls()[1].History)
+	}
+	if h := history(); len(h.Messages) != 4 || h.Calls != 2 {
+		t.Fatalf("history: got %d messages after %d calls, want 4 after 2", len(h.Messages), h.Calls)
+	}
+
+	if status, _ := doRequest(t, "DELETE", srv.URL+"/problems/adunare/chat"); status != http.StatusNoContent {
+		t.Fatalf("delete chat: got %d, want 204", status)
+	}
+	if h := history(); len(h.Messages) != 0 {
+		t.Fatalf("history after delete: got %d messages", len(h.Messages))
+	}
+}
+
+func TestGenerateUpstreamError(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Err: errors.New("LLM API returned HTTP 503: overloaded")})
+	srv := newTestServer(t, llm)
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusInternalServerError || !strings.Contains(body, "overloaded") {
+		t.Fatalf("generate: got %d %q, want 500 with the upstream error", status, body)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusNotFound {
+		t.Fatalf("failed generation was cached: got %d", status)
+	}
+}
+
+func TestGenerateClientGivesUp(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial, Delay: 300 * time.Millisecond})
+	srv := newTestServer(t, llm)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL+"/problems/adunare/generate", nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("generate answered %d before the fake LLM replied", resp.StatusCode)
+	}
+	if n := len(llm.Calls()); n != 1 {
+		t.Fatalf("got %d LLM calls, want 1", n)
+	}
+	// The generation runs as a job, which goes on without the client and caches the editorial.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial")
+		if status == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("editorial of the abandoned generation: got %d, want it cached", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status, _ := doRequest(t, "POST", srv.URL+"/problems/adunare/generate"); status != http.StatusOK || len(llm.Calls()) != 1 {
+		t.Fatalf("generate after the job: got %d after %d calls, want the cached editorial", status, len(llm.Calls()))
+	}
+}
+
+func TestGenerateSharesJobs(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: validEditorial, Delay: 100 * time.Millisecond},
+		iasiutils.FakeStep{Text: strings.Replace(validEditorial, "64-bit", "long long", 1), Delay: 100 * time.Millisecond},
+	)
+	srv := newTestServer(t, llm)
+
+	// A generation queued through /jobs and requests for the same editorial share one job.
+	if status, _ := submitJob(t, srv, "adunare"); status != http.StatusAccepted {
+		t.Fatalf("POST /jobs: got %d", status)
+	}
+	_, job := submitJob(t, srv, "adunare")
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Post(srv.URL+"/problems/adunare/generate", "", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("generate: got %d: %s", resp.StatusCode, data)
+			}
+			if id := resp.Header.Get("X-Job-Id"); id != "" && id != job.ID {
+				t.Errorf("generate ran job %s, want the queued %s", id, job.ID)
+			}
+			bodies[i] = string(data)
+		}(i)
+	}
+	wg.Wait()
+	if len(llm.Calls()) != 1 || bodies[0] != bodies[1] || bodies[1] != bodies[2] {
+		t.Fatalf("%d LLM calls, bodies %q; want one generation shared by every request", len(llm.Calls()), bodies)
+	}
+
+	// Regenerating runs a job too, replacing the cached editorial with a new revision.
+	resp, err := http.Post(srv.URL+"/problems/adunare/regenerate", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Job-Id") == "" || !strings.Contains(string(data), "long long") {
+		t.Fatalf("regenerate: got %d (job %q): %s", resp.StatusCode, resp.Header.Get("X-Job-Id"), data)
+	}
+	if regen := waitForJob(t, srv, resp.Header.Get("X-Job-Id")); regen.State != iasiutils.JobDone || regen.Params["regenerate"] != "1" {
+		t.Fatalf("regenerate job: %+v", regen)
+	}
+}
+
+func TestGenerateUnknownProblem(t *testing.T) {
+	llm := iasiutils.NewFakeProvider()
+	srv := newTestServer(t, llm)
+
+	status, _ := doRequest(t, "POST", srv.URL+"/problems/scmax/generate")
+	if status != http.StatusNotFound {
+		t.Fatalf("generate: got %d, want 404", status)
+	}
+	if n := len(llm.Calls()); n != 0 {
+		t.Fatalf("unknown problem reached the LLM (%d calls)", n)
+	}
+}
+
+// sseEvent is one Server-Sent Event read from a stream.
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSE(body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if strings.HasPrefix(line, "event: ") {
+				ev.name = strings.TrimPrefix(line, "event: ")
+			} else if strings.HasPrefix(line, "data: ") {
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestGenerateStream(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: "not json"},
+		iasiutils.FakeStep{Text: validEditorial},
+	)
+	srv := newTestServer(t, llm)
+
+	status, body := doRequest(t, "GET", srv.URL+"/problems/adunare/generate/stream")
+	if status != http.StatusOK {
+		t.Fatalf("stream: got %d: %s", status, body)
+	}
+	var phases []string
+	var streamed strings.Builder
+	events := parseSSE(body)
+	for _, ev := range events {
+		var payload map[string]string
+		switch ev.name {
+		case "phase":
+			json.Unmarshal([]byte(ev.data), &payload)
+			phases = append(phases, payload["phase"])
+		case "token":
+			json.Unmarshal([]byte(ev.data), &payload)
+			streamed.WriteString(payload["text"])
+		}
+	}
+	want := []string{"fetching statement", "fetching source", "generating", "repairing", "saved"}
+	if strings.Join(phases, ",") != strings.Join(want, ",") {
+		t.Errorf("phases: got %q, want %q", phases, want)
+	}
+	if streamed.String() != "not json"+validEditorial {
+		t.Errorf("tokens: got %q", streamed.String())
+	}
+	last := events[len(events)-1]
+	if last.name != "result" {
+		t.Fatalf("last event: got %q, want result", last.name)
+	}
+	if hints, _ := decodeEditorial(t, last.data); len(hints) != 2 {
+		t.Fatalf("result: unexpected editorial %s", last.data)
+	}
+
+	status, stored := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial")
+	if hints, _ := decodeEditorial(t, stored); status != http.StatusOK || len(hints) != 2 {
+		t.Fatalf("streamed editorial was not cached: got %d %s", status, stored)
+	}
+
+	_, body = doRequest(t, "GET", srv.URL+"/problems/adunare/generate/stream")
+	if events := parseSSE(body); len(events) != 1 || events[0].name != "result" {
+		t.Fatalf("cached stream: got %q, want a single result event", body)
+	}
+	if n := len(llm.Calls()); n != 2 {
+		t.Fatalf("got %d LLM calls, want 2", n)
+	}
+}
+
+func TestGenerateStreamFailure(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Err: errors.New("LLM API returned HTTP 500: boom")})
+	srv := newTestServer(t, llm)
+
+	_, body := doRequest(t, "GET", srv.URL+"/problems/adunare/generate/stream")
+	events := parseSSE(body)
+	last := events[len(events)-1]
+	if last.name != "failure" || !strings.Contains(last.data, "boom") || !strings.Contains(last.data, `"status":500`) {
+		t.Fatalf("last event: got %s %s, want a failure with the upstream error", last.name, last.data)
+	}
+}
+
+func submitJob(t *testing.T, srv *httptest.Server, problem string) (int, iasiutils.Job) {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/jobs", "application/json", strings.NewReader(`{"problem":"`+problem+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var job iasiutils.Job
+	json.NewDecoder(resp.Body).Decode(&job)
+	return resp.StatusCode, job
+}
+
+// waitForJob polls GET /jobs/{id} until the job finishes.
+func waitForJob(t *testing.T, srv *httptest.Server, id string) iasiutils.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, body := doRequest(t, "GET", srv.URL+"/jobs/"+id)
+		if status != http.StatusOK {
+			t.Fatalf("job %s: got %d: %s", id, status, body)
+		}
+		var job iasiutils.Job
+		if err := json.Unmarshal([]byte(body), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.State.Finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still %s", id, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobsDeduplicatePerProblem(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial, Delay: 100 * time.Millisecond})
+	srv := newTestServer(t, llm)
+
+	status, first := submitJob(t, srv, "adunare")
+	if status != http.StatusAccepted || first.ID == "" {
+		t.Fatalf("submit: got %d %+v, want 202 with a job", status, first)
+	}
+	status, second := submitJob(t, srv, "adunare")
+	if status != http.StatusOK || second.ID != first.ID {
+		t.Fatalf("second submit: got %d job %s, want 200 and job %s", status, second.ID, first.ID)
+	}
+	if job := waitForJob(t, srv, first.ID); job.State != iasiutils.JobDone {
+		t.Fatalf("job: got %s (%s), want done", job.State, job.Error)
+	}
+	if n := len(llm.Calls()); n != 1 {
+		t.Fatalf("got %d LLM calls, want 1", n)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusOK {
+		t.Fatalf("job did not cache the editorial: got %d", status)
+	}
+
+	// Once finished, a new submission is a new job, which finds the editorial cached.
+	status, third := submitJob(t, srv, "adunare")
+	if status != http.StatusAccepted || third.ID == first.ID {
+		t.Fatalf("submit after done: got %d job %s", status, third.ID)
+	}
+	if job := waitForJob(t, srv, third.ID); job.State != iasiutils.JobDone || len(llm.Calls()) != 1 {
+		t.Fatalf("cached job: got %s after %d LLM calls", job.State, len(llm.Calls()))
+	}
+}
+
+func TestJobsReportFailure(t *testing.T) {
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Err: errors.New("LLM API returned HTTP 429: quota exceeded")})
+	srv := newTestServer(t, llm)
+
+	_, job := submitJob(t, srv, "adunare")
+	job = waitForJob(t, srv, job.ID)
+	if job.State != iasiutils.JobFailed || !strings.Contains(job.Error, "quota exceeded") {
+		t.Fatalf("job: got %s %q, want failed with the upstream error", job.State, job.Error)
+	}
+	if status, _ := submitJob(t, srv, "scmax"); status != http.StatusNotFound {
+		t.Fatalf("unknown problem: got %d, want 404", status)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/jobs/nope"); status != http.StatusNotFound {
+		t.Fatalf("unknown job: got %d, want 404", status)
+	}
+}
+
+func TestJobsResumeAfterRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	interrupted := `{"id":"0123abcd","problem":"adunare","state":"running","phase":"generating","created_at":"2024-03-01T12:00:00Z"}`
+	if err := os.MkdirAll(filepath.Join(dataDir, "jobs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "jobs", "0123abcd.json"), []byte(interrupted), 0644); err != nil {
+		t.Fatal(err)
+	}
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	srv := newTestServerIn(t, llm, dataDir)
+
+	if job := waitForJob(t, srv, "0123abcd"); job.State != iasiutils.JobDone {
+		t.Fatalf("resumed job: got %s (%s), want done", job.State, job.Error)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, "jobs", "0123abcd.json"))
+	if err != nil || !strings.Contains(string(data), `"state": "done"`) {
+		t.Fatalf("job state not persisted: %s %v", data, err)
+	}
+}
+
+func TestGenerateWithRecipe(t *testing.T) {
+	recipes := t.TempDir()
+	custom := `{{define "system"}}Be brief.{{end}}{{define "prompt"}}Level {{.Level}}, {{.Hints}} hints for {{.Title}} in {{.Language}}. {{.OutputFormat}}{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(recipes, "short.tmpl"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IASI_RECIPES_DIR", recipes)
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	srv := newTestServer(t, llm)
+
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?recipe=missing"); status != http.StatusBadRequest {
+		t.Fatalf("unknown recipe: got %d %s, want 400", status, body)
+	}
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?recipe=short&hints=11"); status != http.StatusBadRequest {
+		t.Fatalf("too many hints: got %d %s, want 400", status, body)
+	}
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?recipe=short&hints=2&level=advanced")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	calls := llm.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d LLM calls, want 1", len(calls))
+	}
+	want := `Level advanced, 2 hints for Adunare in C++. Return only a JSON object with the fields "hints" (an array of exactly 2 strings)`
+	if calls[0].SystemPrompt != "Be brief." || !strings.HasPrefix(calls[0].Prompt, want) {
+		t.Fatalf("recipe not applied: system %q, prompt %q", calls[0].SystemPrompt, calls[0].Prompt)
+	}
+
+	_, body = doRequest(t, "GET", srv.URL+"/recipes")
+	if body != `["default","short"]`+"\n" {
+		t.Fatalf("recipes: got %q", body)
+	}
+}
+
+func TestGeneratePerLanguage(t *testing.T) {
+	roEditorial := `{"hints":["Citește ambele numere."],"editorial":"Afișează a+b pe 64 de biți."}`
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: roEditorial},
+		iasiutils.FakeStep{Text: validEditorial},
+	)
+	srv := newTestServer(t, llm)
+
+	if status, _ := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?lang=fr"); status != http.StatusBadRequest {
+		t.Fatalf("unsupported lang: got %d, want 400", status)
+	}
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?lang=ro")
+	if _, editorial := decodeEditorial(t, body); status != http.StatusOK || editorial != "Afișează a+b pe 64 de biți." {
+		t.Fatalf("generate ro: got %d %s", status, body)
+	}
+	if calls := llm.Calls(); !strings.Contains(calls[0].Prompt, "(in Romanian,") || !strings.Contains(calls[0].SystemPrompt, "Always answer in Romanian.") {
+		t.Fatalf("prompt does not ask for Romanian: %q / %q", calls[0].SystemPrompt, calls[0].Prompt)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusNotFound {
+		t.Fatalf("English editorial: got %d before generating it, want 404", status)
+	}
+
+	status, body = doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if _, editorial := decodeEditorial(t, body); status != http.StatusOK || editorial != "Print a+b using 64-bit integers." {
+		t.Fatalf("generate en: got %d %s", status, body)
+	}
+	_, ro := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial?lang=ro")
+	_, en := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial?lang=en")
+	if _, editorial := decodeEditorial(t, ro); editorial != "Afișează a+b pe 64 de biți." {
+		t.Fatalf("cached ro editorial: %s", ro)
+	}
+	if _, editorial := decodeEditorial(t, en); editorial != "Print a+b using 64-bit integers." {
+		t.Fatalf("cached en editorial: %s", en)
+	}
+	if n := len(llm.Calls()); n != 2 {
+		t.Fatalf("got %d LLM calls, want 2", n)
+	}
+}
+
+func TestRegenerateKeepsRevisions(t *testing.T) {
+	dataDir := t.TempDir()
+	// An editorial cached before provenance was recorded.
+	legacy := `{"hints":["Old hint."],"editorial":"Old editorial."}`
+	if err := os.MkdirAll(filepath.Join(dataDir, "editorials"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataDir, "editorials", "adunare.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	srv := newTestServerIn(t, llm, dataDir)
+
+	for i := 0; i < 2; i++ {
+		if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/regenerate"); status != http.StatusOK {
+			t.Fatalf("regenerate: got %d: %s", status, body)
+		}
+	}
+	if n := len(llm.Calls()); n != 2 {
+		t.Fatalf("got %d LLM calls, want 2", n)
+	}
+
+	_, body := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial")
+	var latest iasiutils.EditorialRevision
+	if err := json.Unmarshal([]byte(body), &latest); err != nil {
+		t.Fatalf("editorial: %v: %s", err, body)
+	}
+	if latest.Revision != 3 || latest.Provider != "fake" || latest.Model != "fake" || latest.Recipe != "default" ||
+		latest.RecipeHash == "" || latest.CreatedAt.IsZero() || latest.Usage.TotalTokens == 0 {
+		t.Fatalf("latest editorial is missing its provenance: %s", body)
+	}
+
+	status, body := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial/revisions")
+	var revs []iasiutils.EditorialRevision
+	if err := json.Unmarshal([]byte(body), &revs); status != http.StatusOK || err != nil {
+		t.Fatalf("revisions: got %d %v: %s", status, err, body)
+	}
+	if len(revs) != 3 || revs[0].Revision != 3 || revs[1].Revision != 2 || revs[2].Revision != 1 {
+		t.Fatalf("revisions: got %s, want 3, 2 and 1", body)
+	}
+	if revs[2].Editorial.Editorial != "Old editorial." || revs[2].Provider != "" {
+		t.Fatalf("revision 1 is not the legacy editorial: %+v", revs[2])
+	}
+
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial/revisions?lang=ro"); status != http.StatusNotFound {
+		t.Fatalf("revisions of an ungenerated language: got %d, want 404", status)
+	}
+}
+
+func TestGenerateShrinksLongInputs(t *testing.T) {
+	pages := stubFetcher{}
+	for url, body := range infoarenaPages {
+		pages[url] = body
+	}
+	source := "#include &lt;iostream&gt;\n" + strings.Repeat("// a long comment explaining every step of the solution\n\n", 200) +
+		"int main() { long long a, b; std::cin &gt;&gt; a &gt;&gt; b; std::cout &lt;&lt; a + b; /* done */ }"
+	pages["https://www.infoarena.ro/job_detail/101?action=view-source"] = "<html><body><pre>" + source + "</pre></body></html>"
+	t.Setenv("IASI_PROMPT_TOKEN_BUDGET", "800")
+	llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	srv := newTestServerWith(t, pages, llm, t.TempDir())
+
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate"); status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	call := llm.Calls()[0]
+	if tokens := iasiutils.EstimateTokens(call.SystemPrompt) + iasiutils.EstimateTokens(call.Prompt); tokens > 800 {
+		t.Errorf("prompt has ~%d tokens, over the budget of 800", tokens)
+	}
+
+	t.Setenv("IASI_PROMPT_TOKEN_BUDGET", "50")
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/regenerate"); status != http.StatusUnprocessableEntity {
+		t.Fatalf("regenerate over budget: got %d: %s, want 422", status, body)
+	}
+	if n := len(llm.Calls()); n != 1 {
+		t.Fatalf("got %d LLM calls, want 1", n)
+	}
+}
+
+func TestUsageLimit(t *testing.T) {
+	t.Setenv("IASI_DAILY_TOKEN_LIMIT", "1")
+	t.Setenv("IASI_LLM_INPUT_PRICE", "1")
+	t.Setenv("IASI_LLM_OUTPUT_PRICE", "4")
+	llm := iasiutils.NewFakeProvider(
+		iasiutils.FakeStep{Text: `{"hints":[],"editorial":""}`},
+		iasiutils.FakeStep{Text: validEditorial},
+	)
+	srv := newTestServer(t, llm)
+
+	// The limit is checked before generating, so the first generation runs, repair included.
+	if status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate"); status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate?lang=ro")
+	if status != http.StatusTooManyRequests || !strings.Contains(body, "daily LLM usage limit reached") {
+		t.Fatalf("generate over the limit: got %d %q, want 429", status, body)
+	}
+	if n := len(llm.Calls()); n != 2 {
+		t.Fatalf("got %d LLM calls, want 2", n)
+	}
+	if status, _ := doRequest(t, "GET", srv.URL+"/problems/adunare/editorial"); status != http.StatusOK {
+		t.Fatalf("cached editorial over the limit: got %d, want 200", status)
+	}
+
+	status, body = doRequest(t, "GET", srv.URL+"/usage")
+	var summary iasiutils.UsageSummary
+	if err := json.Unmarshal([]byte(body), &summary); status != http.StatusOK || err != nil {
+		t.Fatalf("usage: got %d %v: %s", status, err, body)
+	}
+	if summary.Today.Calls != 2 || summary.Today.CostUSD == 0 || summary.Problems["adunare"] == nil {
+		t.Fatalf("usage: %s, want the 2 calls of adunare priced", body)
+	}
+}
+
+// geminiReply is one scripted answer of a stub Gemini server.
+type geminiReply struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// newGeminiStub answers every request with reply.
+func newGeminiStub(t *testing.T, reply geminiReply) *iasiutils.GeminiProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Structured output is only accepted by the v1beta surface.
+		if !strings.HasPrefix(r.URL.Path, "/v1beta/models/gemini-test:") {
+			http.NotFound(w, r)
+			return
+		}
+		for k, v := range reply.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(reply.status)
+		fmt.Fprint(w, reply.body)
+	}))
+	t.Cleanup(srv.Close)
+	return &iasiutils.GeminiProvider{APIKey: "test", ModelName: "gemini-test", BaseURL: srv.URL + "/v1beta", Client: srv.Client()}
+}
+
+func TestGenerateRetriesAndFallsBack(t *testing.T) {
+	gemini := newGeminiStub(t, geminiReply{status: 503, body: `{"error":{"code":503,"message":"The model is overloaded."}}`})
+	primary := iasiutils.NewRetryProvider(gemini, 2)
+	primary.BaseBackoff = time.Millisecond
+	backup := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+	backup.ModelName = "backup"
+	srv := newTestServer(t, iasiutils.NewFallbackProvider(primary, backup))
+
+	status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+	if status != http.StatusOK {
+		t.Fatalf("generate: got %d: %s", status, body)
+	}
+	var ed iasiutils.EditorialRevision
+	json.Unmarshal([]byte(body), &ed)
+	if ed.Provider != "fake" || ed.Model != "backup" {
+		t.Fatalf("editorial made by %s/%s, want the fallback fake/backup", ed.Provider, ed.Model)
+	}
+}
+
+func TestGenerateClassifiesLLMErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		reply  geminiReply
+		status int
+		kind   iasiutils.LLMErrorKind
+	}{
+		{"invalid key", geminiReply{status: 400, body: `{"error":{"message":"API key not valid. Please pass a valid API key."}}`}, http.StatusBadGateway, iasiutils.LLMInvalidKey},
+		{"safety", geminiReply{status: 200, body: `{"promptFeedback":{"blockReason":"SAFETY"}}`}, http.StatusUnprocessableEntity, iasiutils.LLMSafetyBlocked},
+		{"empty", geminiReply{status: 200, body: `{"candidates":[]}`}, http.StatusBadGateway, iasiutils.LLMEmptyResponse},
+		{"long retry-after", geminiReply{status: 429, header: map[string]string{"Retry-After": "120"}, body: `{}`}, http.StatusTooManyRequests, iasiutils.LLMRateLimited},
+		{"overloaded", geminiReply{status: 503, body: `{}`}, http.StatusServiceUnavailable, iasiutils.LLMOverloaded},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gemini := newGeminiStub(t, tc.reply)
+			llm := iasiutils.NewRetryProvider(gemini, 2)
+			llm.BaseBackoff = time.Millisecond
+			srv := newTestServer(t, llm)
+
+			status, body := doRequest(t, "POST", srv.URL+"/problems/adunare/generate")
+			e := decodeAPIError(t, body)
+			if status != tc.status || e.Status != tc.status || e.Kind != string(tc.kind) || e.Provider != "gemini" || e.Model != "gemini-test" {
+				t.Fatalf("got %d %s, want %d with kind %s from gemini/gemini-test", status, body, tc.status, tc.kind)
+			}
+			if tc.kind == iasiutils.LLMRateLimited && e.RetryAfterSeconds != 120 {
+				t.Fatalf("retry_after_seconds: got %d, want 120", e.RetryAfterSeconds)
+			}
+		})
+	}
+}
+
+// newBatchTracker returns a tracker over n solved problems adunare1..n served like adunare.
+func newBatchTracker(t *testing.T, llm iasiutils.LLMProvider, n int) *tracker {
+	t.Helper()
+	log.SetOutput(ioutil.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	pages := stubFetcher{}
+	var subs []iasiutils.Submission
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		slug, job := fmt.Sprintf("adunare%d", i), fmt.Sprint(100+i)
+		pages["https://www.infoarena.ro/job_detail/"+job] = strings.Replace(infoarenaPages["https://www.infoarena.ro/job_detail/101"], "/problema/adunare", "/problema/"+slug, 1)
+		pages["https://www.infoarena.ro/job_detail/"+job+"?action=view-source"] = infoarenaPages["https://www.infoarena.ro/job_detail/101?action=view-source"]
+		pages["https://www.infoarena.ro/problema/"+slug] = infoarenaPages["https://www.infoarena.ro/problema/adunare"]
+		subs = append(subs, iasiutils.Submission{
+			JobID: job, User: "mentor", ProblemSlug: slug, ProblemName: slug,
+			SubmittedAt: at.Add(time.Duration(i) * time.Hour), Status: "Evaluare completa: 100 puncte", State: iasiutils.EvalComplete, Score: 100,
+		})
+	}
+	return newTracker(pages, llm, "mentor", t.TempDir(), iasiutils.AggregateByProblem(subs))
+}
+
+// concurrencyProbe records the most generations running at once.
+type concurrencyProbe struct {
+	iasiutils.LLMProvider
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (cp *concurrencyProbe) enter() func() {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.running++
+	if cp.running > cp.peak {
+		cp.peak = cp.running
+	}
+	return func() {
+		cp.mu.Lock()
+		cp.running--
+		cp.mu.Unlock()
+	}
+}
+
+func (cp *concurrencyProbe) Generate(ctx context.Context, req iasiutils.LLMRequest) (*iasiutils.LLMResponse, error) {
+	defer cp.enter()()
+	return cp.LLMProvider.Generate(ctx, req)
+}
+
+func (cp *concurrencyProbe) GenerateStream(ctx context.Context, req iasiutils.LLMRequest, onChunk func(string)) (*iasiutils.LLMResponse, error) {
+	defer cp.enter()()
+	return cp.LLMProvider.GenerateStream(ctx, req, onChunk)
+}
+
+// defaultOptions returns the generation options of a request without parameters.
+func defaultOptions(t *testing.T) generateOptions {
+	t.Helper()
+	opts, err := parseGenerateOptions(url.Values{}.Get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestGenerateBatch(t *testing.T) {
+	t.Run("parallel", func(t *testing.T) {
+		llm := &concurrencyProbe{LLMProvider: iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial, Delay: 50 * time.Millisecond})}
+		tr := newBatchTracker(t, llm, 5)
+		var out strings.Builder
+		res := tr.generateBatch(context.Background(), defaultOptions(t), batchOptions{Parallel: 2}, &out)
+		if len(res.Generated) != 5 || len(res.Failed) != 0 || len(res.Remaining) != 0 {
+			t.Fatalf("got %+v\n%s", res, out.String())
+		}
+		if llm.peak != 2 {
+			t.Fatalf("%d generations ran at once, want 2", llm.peak)
+		}
+		if res.Spent.Calls != 5 || res.Spent.TotalTokens == 0 {
+			t.Fatalf("spent %+v, want the 5 calls", res.Spent)
+		}
+
+		// A second run skips what is cached.
+		again := tr.generateBatch(context.Background(), defaultOptions(t), batchOptions{Parallel: 2}, &out)
+		if len(again.Skipped) != 5 || len(again.Generated) != 0 || again.Spent.Calls != 0 {
+			t.Fatalf("second run: got %+v", again)
+		}
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial})
+		tr := newBatchTracker(t, llm, 4)
+		// Consumption recorded before the run does not count against its budget.
+		if err := tr.usage.Record("earlier", 1, iasiutils.TokenUsage{TotalTokens: 100000}); err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		res := tr.generateBatch(context.Background(), defaultOptions(t), batchOptions{Parallel: 1, BudgetTokens: 1}, &out)
+		if len(res.Generated) != 1 || len(res.Remaining) != 3 || len(llm.Calls()) != 1 {
+			t.Fatalf("got %+v after %d calls, want one problem generated before the budget stops the run", res, len(llm.Calls()))
+		}
+		if !strings.Contains(out.String(), "stopping: budget reached: ") || res.Spent.TotalTokens >= 100000 {
+			t.Fatalf("output %q, spent %+v", out.String(), res.Spent)
+		}
+	})
+
+	t.Run("other traffic", func(t *testing.T) {
+		llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial, Delay: 50 * time.Millisecond})
+		tr := newBatchTracker(t, llm, 3)
+		// Chats and hints recorded while the run goes on are not part of its budget.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			time.Sleep(20 * time.Millisecond)
+			if err := tr.usage.Record("chat", 1, iasiutils.TokenUsage{TotalTokens: 100000}); err != nil {
+				t.Error(err)
+			}
+		}()
+		var out strings.Builder
+		res := tr.generateBatch(context.Background(), defaultOptions(t), batchOptions{Parallel: 1, BudgetTokens: 50000}, &out)
+		<-done
+		if len(res.Generated) != 3 || res.Spent.Calls != 3 || res.Spent.TotalTokens >= 50000 {
+			t.Fatalf("got %+v\n%s", res, out.String())
+		}
+	})
+
+	t.Run("interrupt", func(t *testing.T) {
+		llm := iasiutils.NewFakeProvider(iasiutils.FakeStep{Text: validEditorial, Delay: time.Minute})
+		tr := newBatchTracker(t, llm, 4)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var out strings.Builder
+		start := time.Now()
+		res := tr.generateBatch(ctx, defaultOptions(t), batchOptions{Parallel: 2}, &out)
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("interrupted run took %s", time.Since(start))
+		}
+		if len(res.Remaining) != 4 || len(res.Generated) != 0 || len(res.Failed) != 0 || len(llm.Calls()) != 2 {
+			t.Fatalf("got %+v after %d calls, want every problem remaining and only the first two started", res, len(llm.Calls()))
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Roles of the messages of a chat.
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatMessage is one turn of a conversation with the model.
+type ChatMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	// Leak is how much of the solution an assistant message gives away, see CheckSpoilers.
+	Leak *LeakReport `json:"leak,omitempty"`
+}
+
+// ChatReply is the answer of the model to a chat message.
+type ChatReply struct {
+	Message  ChatMessage
+	Provider string
+	Model    string
+	Usage    TokenUsage
+}
+
+// Chat asks llm to answer question as a tutor for a problem, continuing the conversation in history.
+// The system prompt comes from the recipe, see BuildChatPrompt. The oldest messages are left out when
+// the conversation does not fit in the recipe's token budget; ErrPromptTooLong is returned when even
+// the question alone does not. The reply is passed to onToken as it is streamed; a nil onToken
+// disables streaming. Replies reaching the FlagScore of the recipe's SpoilerPolicy are flagged: they
+// were already shown, so they are never rejected.
+func Chat(ctx context.Context, llm LLMProvider, recipe *Recipe, ps *ProblemStatement, source *SourceFile, history []ChatMessage, question string, onToken func(string)) (*ChatReply, error) {
+	systemPrompt, err := recipe.BuildChatPrompt(ps, source)
+	if err != nil {
+		return nil, err
+	}
+	budget := recipe.TokenBudget
+	if budget == 0 {
+		budget = DefaultPromptTokenBudget
+	}
+	if budget > 0 {
+		left := budget - EstimateTokens(systemPrompt) - EstimateTokens(question)
+		if left < 0 {
+			return nil, fmt.Errorf("%w: the question does not fit in the budget of %d tokens", ErrPromptTooLong, budget)
+		}
+		history = fitHistory(history, left)
+	}
+	req := LLMRequest{SystemPrompt: systemPrompt, History: history, Prompt: question}
+	var resp *LLMResponse
+	if onToken != nil {
+		resp, err = llm.GenerateStream(ctx, req, onToken)
+	} else {
+		resp, err = llm.Generate(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := &ChatReply{
+		Message:  ChatMessage{Role: ChatRoleAssistant, Content: strings.TrimSpace(resp.Text), CreatedAt: time.Now().UTC()},
+		Provider: resp.Provider,
+		Model:    resp.Model,
+		Usage:    resp.Usage,
+	}
+	if reply.Provider == "" {
+		reply.Provider, reply.Model = llm.Name(), llm.Model()
+	}
+	if source != nil {
+		policy := DefaultSpoilerPolicy
+		if recipe.Spoilers != nil {
+			policy = *recipe.Spoilers
+		}
+		report := CheckSpoilers(&Editorial{Editorial: reply.Message.Content}, ps, source)
+		if policy.FlagScore > 0 && report.Score >= policy.FlagScore {
+			report.Flagged = true
+			log.Printf("[WARN] Chat reply may give away the solution (leak score %.2f): %s", report.Score, strings.Join(report.Problems, "; "))
+		}
+		reply.Message.Leak = &report
+	}
+	return reply, nil
+}
+
+// fitHistory returns the latest messages of history estimated to fit in tokens, starting with a
+// message of the user.
+func fitHistory(history []ChatMessage, tokens int) []ChatMessage {
+	start := len(history)
+	for start > 0 {
+		cost := EstimateTokens(history[start-1].Content)
+		if cost > tokens {
+			break
+		}
+		tokens -= cost
+		start--
+	}
+	for start < len(history) && history[start].Role != ChatRoleUser {
+		start++
+	}
+	if start > 0 {
+		log.Printf("[INFO] Left the %d oldest of %d chat messages out of the prompt", start, len(history))
+	}
+	return history[start:]
+}
+
+// ChatHistory is the conversation of a student with the tutor about a problem.
+type ChatHistory struct {
+	Messages []ChatMessage `json:"messages"`
+	Recipe   string        `json:"recipe,omitempty"`
+	Lang     string        `json:"lang,omitempty"`
+	// Calls and Usage count the model calls of the conversation.
+	Calls     int        `json:"calls"`
+	Usage     TokenUsage `json:"usage"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DefaultChatsDir is where conversations are kept when ChatStore.Dir is empty.
+const DefaultChatsDir = "data/chats"
+
+// ChatStore keeps the conversation of each problem and language on disk. It is safe for concurrent use.
+type ChatStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+func (cs *ChatStore) path(slug, lang string) string {
+	dir := cs.Dir
+	if dir == "" {
+		dir = DefaultChatsDir
+	}
+	return filepath.Join(dir, langName(slug, lang)+".json")
+}
+
+// Load returns the conversation about slug in lang. The error satisfies os.IsNotExist when there was none.
+func (cs *ChatStore) Load(slug, lang string) (*ChatHistory, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	data, err := ioutil.ReadFile(cs.path(slug, lang))
+	if err != nil {
+		return nil, err
+	}
+	var history ChatHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse chat of %s: %w", slug, err)
+	}
+	return &history, nil
+}
+
+// Save stores history as the conversation about slug in lang.
+func (cs *ChatStore) Save(slug, lang string, history *ChatHistory) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return writeJSONFile(cs.path(slug, lang), history)
+}
+
+// Delete forgets the conversation about slug in lang.
+func (cs *ChatStore) Delete(slug, lang string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if err := os.Remove(cs.path(slug, lang)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChat(t *testing.T) {
+	fake := NewFakeProvider(FakeStep{Text: " Because the sum can exceed 32 bits.\n"}, FakeStep{Text: "Just write long long sumOfBoth = a + b; and print sumOfBoth."})
+	recipe := hintRecipe(t)
+	recipe.Spoilers = &SpoilerPolicy{FlagScore: 0.3}
+	history := []ChatMessage{{Role: ChatRoleUser, Content: "What is the answer made of?"}, {Role: ChatRoleAssistant, Content: "Of both numbers."}}
+	reply, err := Chat(context.Background(), fake, recipe, hintStatement, hintSource, history, "Why is int not enough?", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Message.Role != ChatRoleAssistant || reply.Message.Content != "Because the sum can exceed 32 bits." || reply.Provider != "fake" {
+		t.Errorf("reply = %+v", reply)
+	}
+	if leak := reply.Message.Leak; leak == nil || leak.Flagged {
+		t.Errorf("clean reply: leak %+v", leak)
+	}
+	call := fake.Calls()[0]
+	if !strings.Contains(call.SystemPrompt, "tutor") || !strings.Contains(call.SystemPrompt, "Se dau a si b") || !strings.Contains(call.SystemPrompt, "sumOfBoth") {
+		t.Errorf("system prompt is not grounded in the statement and the solution:\n%s", call.SystemPrompt)
+	}
+	if call.Prompt != "Why is int not enough?" || !reflect.DeepEqual(call.History, history) {
+		t.Errorf("call = %q with history %+v", call.Prompt, call.History)
+	}
+
+	var chunks []string
+	reply, err = Chat(context.Background(), fake, recipe, hintStatement, hintSource, nil, "Show me the code.", func(c string) { chunks = append(chunks, c) })
+	if err != nil || strings.Join(chunks, "") != reply.Message.Content {
+		t.Fatalf("streamed %q, replied %+v, %v", chunks, reply, err)
+	}
+	if leak := reply.Message.Leak; leak == nil || !leak.Flagged {
+		t.Errorf("reply copying the solution was not flagged: %+v", leak)
+	}
+}
+
+func TestChatTooLong(t *testing.T) {
+	fake := NewFakeProvider(FakeStep{Text: "ok"})
+	recipe := hintRecipe(t)
+	recipe.TokenBudget = 2000
+	_, err := Chat(context.Background(), fake, recipe, hintStatement, hintSource, nil, strings.Repeat("why ", 2000), nil)
+	if !errors.Is(err, ErrPromptTooLong) || len(fake.Calls()) != 0 {
+		t.Fatalf("Chat() = %v after %d calls, want ErrPromptTooLong before calling the model", err, len(fake.Calls()))
+	}
+}
+
+func TestFitHistory(t *testing.T) {
+	history := []ChatMessage{
+		{Role: ChatRoleUser, Content: strings.Repeat("a", 40)},
+		{Role: ChatRoleAssistant, Content: strings.Repeat("b", 40)},
+		{Role: ChatRoleUser, Content: strings.Repeat("c", 40)},
+		{Role: ChatRoleAssistant, Content: strings.Repeat("d", 40)},
+	}
+	tests := []struct {
+		tokens int
+		start  int
+	}{
+		{40, 0},
+		{39, 2},
+		// The third message alone fits, but a conversation starts with the student's question.
+		{30, 2},
+		{20, 2},
+		{9, 4},
+	}
+	for _, tt := range tests {
+		if got := fitHistory(history, tt.tokens); !reflect.DeepEqual(got, history[tt.start:]) {
+			t.Errorf("fitHistory(%d tokens) kept %d messages, want %d", tt.tokens, len(got), len(history)-tt.start)
+		}
+	}
+}
+
+func TestChatStore(t *testing.T) {
+	cs := &ChatStore{Dir: t.TempDir()}
+	if _, err := cs.Load("adunare", "ro"); !os.IsNotExist(err) {
+		t.Fatalf("Load() before Save = %v, want not exist", err)
+	}
+	saved := &ChatHistory{Messages: []ChatMessage{{Role: ChatRoleUser, Content: "De ce nu ajunge int?"}}, Lang: "ro", Calls: 1}
+	if err := cs.Save("adunare", "ro", saved); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cs.Load("adunare", "ro")
+	if err != nil || len(got.Messages) != 1 || got.Messages[0].Content != "De ce nu ajunge int?" || got.Calls != 1 {
+		t.Fatalf("Load() = %+v, %v", got, err)
+	}
+	if _, err := cs.Load("adunare", DefaultLang); !os.IsNotExist(err) {
+		t.Errorf("chats of other languages are shared: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cs.Delete("adunare", "ro"); err != nil {
+			t.Fatalf("Delete() #%d = %v", i+1, err)
+		}
+	}
+	if _, err := cs.Load("adunare", "ro"); !os.IsNotExist(err) {
+		t.Errorf("Load() after Delete = %v, want not exist", err)
+	}
+}
@@ -0,0 +1,232 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Editorial is the structured output generated for a problem: progressive hints and a full editorial.
+type Editorial struct {
+	Hints     []string `json:"hints"`
+	Editorial string   `json:"editorial"`
+	// Complexity is the time and memory complexity of the intended solution, when the model gives one.
+	Complexity string `json:"complexity,omitempty"`
+}
+
+// OutputSchema bounds the Editorial a Recipe asks the model for.
+type OutputSchema struct {
+	MinHints int
+	MaxHints int
+	// RequireComplexity makes the complexity field mandatory instead of optional.
+	RequireComplexity bool
+}
+
+// DefaultOutputSchema is used by recipes that do not declare their own schema.
+var DefaultOutputSchema = OutputSchema{MinHints: 1, MaxHints: 6}
+
+// JSONSchema returns the schema as a JSON Schema object, for providers that support structured output.
+func (s OutputSchema) JSONSchema() map[string]interface{} {
+	hints := map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+	if s.MinHints > 0 {
+		hints["minItems"] = s.MinHints
+	}
+	if s.MaxHints > 0 {
+		hints["maxItems"] = s.MaxHints
+	}
+	required := []string{"hints", "editorial"}
+	if s.RequireComplexity {
+		required = append(required, "complexity")
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"hints":      hints,
+			"editorial":  map[string]interface{}{"type": "string"},
+			"complexity": map[string]interface{}{"type": "string"},
+		},
+		"required": required,
+	}
+}
+
+// describe renders the schema as the instruction appended to the prompt.
+func (s OutputSchema) describe() string {
+	hints := "an array of strings"
+	switch {
+	case s.MinHints > 0 && s.MinHints == s.MaxHints:
+		hints = fmt.Sprintf("an array of exactly %d strings", s.MinHints)
+	case s.MinHints > 0 && s.MaxHints > 0:
+		hints = fmt.Sprintf("an array of %d to %d strings", s.MinHints, s.MaxHints)
+	case s.MaxHints > 0:
+		hints = fmt.Sprintf("an array of at most %d strings", s.MaxHints)
+	case s.MinHints > 0:
+		hints = fmt.Sprintf("an array of at least %d strings", s.MinHints)
+	}
+	complexity := `and optionally "complexity"`
+	if s.RequireComplexity {
+		complexity = `and "complexity"`
+	}
+	return fmt.Sprintf(`Return only a JSON object with the fields "hints" (%s), "editorial" (a string) %s (a string with the time and memory complexity of the solution).`, hints, complexity)
+}
+
+// Validate lists every way ed breaks the schema. A nil result means ed is valid.
+func (s OutputSchema) Validate(ed *Editorial) []string {
+	var problems []string
+	if s.MinHints > 0 && len(ed.Hints) < s.MinHints {
+		problems = append(problems, fmt.Sprintf(`"hints" has %d items, want at least %d`, len(ed.Hints), s.MinHints))
+	}
+	if s.MaxHints > 0 && len(ed.Hints) > s.MaxHints {
+		problems = append(problems, fmt.Sprintf(`"hints" has %d items, want at most %d`, len(ed.Hints), s.MaxHints))
+	}
+	for i, h := range ed.Hints {
+		if strings.TrimSpace(h) == "" {
+			problems = append(problems, fmt.Sprintf(`"hints"[%d] is empty`, i))
+		}
+	}
+	if strings.TrimSpace(ed.Editorial) == "" {
+		problems = append(problems, `"editorial" is missing or empty`)
+	}
+	if s.RequireComplexity && strings.TrimSpace(ed.Complexity) == "" {
+		problems = append(problems, `"complexity" is missing or empty`)
+	}
+	return problems
+}
+
+// InvalidOutputError is returned when the model output does not match the recipe schema or gives
+// away the solution, even after the repair round-trip.
+type InvalidOutputError struct {
+	// Problems lists what was wrong with the last output.
+	Problems []string
+	// Raw is the last output of the model.
+	Raw string
+	// Attempts is the number of model calls made.
+	Attempts int
+	// Usage is the token count of all the attempts.
+	Usage TokenUsage
+}
+
+func (e *InvalidOutputError) Error() string {
+	return fmt.Sprintf("LLM output does not match the schema after %d attempts: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// ParseEditorial decodes model output into an Editorial and validates it against schema. The JSON
+// object may be wrapped in prose or a code fence. The returned problems are nil when the output is valid.
+func ParseEditorial(text string, schema OutputSchema) (*Editorial, []string) {
+	var ed Editorial
+	if err := json.Unmarshal([]byte(text), &ed); err != nil {
+		// Try to extract JSON from markdown/code block or text
+		start := strings.Index(text, "{")
+		end := strings.LastIndex(text, "}")
+		if start == -1 || end <= start {
+			return nil, []string{"output is not a JSON object"}
+		}
+		ed = Editorial{}
+		if err := json.Unmarshal([]byte(text[start:end+1]), &ed); err != nil {
+			return nil, []string{fmt.Sprintf("output is not valid JSON: %v", err)}
+		}
+		log.Printf("[INFO] JSON extracted from LLM output.")
+	}
+	return &ed, schema.Validate(&ed)
+}
+
+// GenerateEditorial asks llm for the recipe's editorial of a problem and validates the answer. When
+// the answer does not match the schema, or gives away the solution as judged by CheckSpoilers and the
+// recipe's SpoilerPolicy, the model is shown its output and the problems once and asked to correct it;
+// if that also fails an *InvalidOutputError is returned. The result records the model, the recipe, the
+// tokens used by every attempt and the leak report; its Revision is set when it is saved.
+func GenerateEditorial(ctx context.Context, llm LLMProvider, recipe *Recipe, ps *ProblemStatement, source *SourceFile) (*EditorialRevision, error) {
+	return StreamEditorial(ctx, llm, recipe, ps, source, nil)
+}
+
+// StreamEditorial is GenerateEditorial with the model output passed to onToken as it is streamed.
+// attempt is 1 for the first answer and 2 for the repair. A nil onToken disables streaming.
+func StreamEditorial(ctx context.Context, llm LLMProvider, recipe *Recipe, ps *ProblemStatement, source *SourceFile, onToken func(attempt int, chunk string)) (*EditorialRevision, error) {
+	schema := recipe.OutputSchema()
+	prompt, systemPrompt, err := recipe.BuildLLMPrompt(ps, source)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] Prompt: %s", prompt)
+	req := LLMRequest{SystemPrompt: systemPrompt, Prompt: prompt, Schema: &schema}
+	name, lang := recipe.Name, recipe.Vars.Lang
+	if name == "" {
+		name = DefaultRecipeName
+	}
+	if lang == "" {
+		lang = DefaultLang
+	}
+	policy := DefaultSpoilerPolicy
+	if recipe.Spoilers != nil {
+		policy = *recipe.Spoilers
+	}
+	var usage TokenUsage
+	const maxAttempts = 2
+	for attempt := 1; ; attempt++ {
+		var resp *LLMResponse
+		var err error
+		if onToken != nil {
+			resp, err = llm.GenerateStream(ctx, req, func(chunk string) { onToken(attempt, chunk) })
+		} else {
+			resp, err = llm.Generate(ctx, req)
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[INFO] LLM response received. Raw response: %s", resp.Text)
+		usage = usage.Add(resp.Usage)
+		ed, problems := ParseEditorial(resp.Text, schema)
+		var leak *LeakReport
+		if problems == nil && source != nil {
+			report := CheckSpoilers(ed, ps, source)
+			leak = &report
+			if policy.RejectScore > 0 && report.Score >= policy.RejectScore {
+				problems = append([]string{fmt.Sprintf("the output gives away the solution (leak score %.2f)", report.Score)}, report.Problems...)
+			} else if policy.FlagScore > 0 && report.Score >= policy.FlagScore {
+				report.Flagged = true
+				log.Printf("[WARN] LLM output may give away the solution (leak score %.2f): %s", report.Score, strings.Join(report.Problems, "; "))
+			}
+		}
+		if problems == nil {
+			provider, model := resp.Provider, resp.Model
+			if provider == "" {
+				provider, model = llm.Name(), llm.Model()
+			}
+			return &EditorialRevision{Editorial: *ed, Leak: leak, Provenance: Provenance{
+				Provider:   provider,
+				Model:      model,
+				Recipe:     name,
+				RecipeHash: recipe.Hash(),
+				Lang:       lang,
+				CreatedAt:  time.Now().UTC(),
+				Attempts:   attempt,
+				Usage:      usage,
+			}}, nil
+		}
+		log.Printf("[WARN] LLM output rejected (attempt %d): %s", attempt, strings.Join(problems, "; "))
+		if attempt == maxAttempts {
+			return nil, &InvalidOutputError{Problems: problems, Raw: resp.Text, Attempts: attempt, Usage: usage}
+		}
+		req.Prompt = repairPrompt(prompt, resp.Text, problems, schema.describe())
+	}
+}
+
+// repairPrompt asks the model to fix its previous answer, ending with the format instruction.
+func repairPrompt(prompt, previous string, problems []string, format string) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nYour previous answer was:\n")
+	sb.WriteString(previous)
+	sb.WriteString("\n\nIt was rejected because:\n")
+	for _, p := range problems {
+		sb.WriteString("- " + p + "\n")
+	}
+	sb.WriteString("\nAnswer again, fixing these problems. ")
+	sb.WriteString(format)
+	return sb.String()
+}
@@ -0,0 +1,148 @@
+package iasiutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provenance records how an editorial was generated.
+type Provenance struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Recipe   string `json:"recipe,omitempty"`
+	// RecipeHash identifies the recipe text, see Recipe.Hash.
+	RecipeHash string    `json:"recipe_hash,omitempty"`
+	Lang       string    `json:"lang,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Attempts is the number of model calls made, 2 when the first answer had to be repaired.
+	Attempts int        `json:"attempts,omitempty"`
+	Usage    TokenUsage `json:"usage"`
+}
+
+// EditorialRevision is one generated version of a problem's editorial.
+type EditorialRevision struct {
+	Editorial
+	// Revision numbers the editorials of a problem and language from 1, in the order they were saved.
+	Revision int `json:"revision"`
+	Provenance
+	// Leak is how much of the solution the editorial gives away, nil when there was no source to compare.
+	Leak *LeakReport `json:"leak,omitempty"`
+}
+
+// DefaultEditorialsDir is where editorials are cached when EditorialStore.Dir is empty.
+const DefaultEditorialsDir = "data/editorials"
+
+// EditorialStore caches the editorials of each problem and language on disk. The latest revision is
+// kept at Path and the revisions it replaced under revisions/ in Dir. It is safe for concurrent use.
+type EditorialStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+func (es *EditorialStore) dir() string {
+	if es.Dir != "" {
+		return es.Dir
+	}
+	return DefaultEditorialsDir
+}
+
+// langName is the file name of the data of slug in lang, without extension. English editorials keep
+// the <slug> name they had before other languages were supported.
+func langName(slug, lang string) string {
+	if lang == "" || lang == DefaultLang {
+		return slug
+	}
+	return slug + "." + lang
+}
+
+// Path is the file holding the latest editorial of slug in lang.
+func (es *EditorialStore) Path(slug, lang string) string {
+	return filepath.Join(es.dir(), langName(slug, lang)+".json")
+}
+
+func (es *EditorialStore) revisionsDir(slug, lang string) string {
+	return filepath.Join(es.dir(), "revisions", langName(slug, lang))
+}
+
+// Load returns the latest editorial of slug in lang. The error satisfies os.IsNotExist when none was saved.
+func (es *EditorialStore) Load(slug, lang string) (*EditorialRevision, error) {
+	return readEditorialRevision(es.Path(slug, lang))
+}
+
+// readEditorialRevision decodes a cached editorial. Editorials cached before revisions were kept have
+// no provenance; they are revision 1 and dated by their file.
+func readEditorialRevision(path string) (*EditorialRevision, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rev EditorialRevision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("failed to parse cached editorial %s: %w", path, err)
+	}
+	if rev.Revision == 0 {
+		rev.Revision = 1
+		if info, err := os.Stat(path); err == nil {
+			rev.CreatedAt = info.ModTime()
+		}
+	}
+	return &rev, nil
+}
+
+// Save stores rev as the latest editorial of slug in lang, numbering it after the one it replaces,
+// which is kept as a previous revision.
+func (es *EditorialStore) Save(slug, lang string, rev *EditorialRevision) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	rev.Revision = 1
+	prev, err := es.Load(slug, lang)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Replacing unreadable editorial: %v", err)
+	}
+	if prev != nil {
+		if err := writeJSONFile(filepath.Join(es.revisionsDir(slug, lang), strconv.Itoa(prev.Revision)+".json"), prev); err != nil {
+			return fmt.Errorf("failed to keep revision %d of %s: %w", prev.Revision, slug, err)
+		}
+		rev.Revision = prev.Revision + 1
+	}
+	return writeJSONFile(es.Path(slug, lang), rev)
+}
+
+// Revisions returns every saved editorial of slug in lang, latest first.
+func (es *EditorialStore) Revisions(slug, lang string) ([]EditorialRevision, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	var revs []EditorialRevision
+	latest, err := es.Load(slug, lang)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	revs = append(revs, *latest)
+	files, _ := filepath.Glob(filepath.Join(es.revisionsDir(slug, lang), "*.json"))
+	for _, path := range files {
+		if _, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".json")); err != nil {
+			continue
+		}
+		rev, err := readEditorialRevision(path)
+		if err != nil {
+			log.Printf("[WARN] %v", err)
+			continue
+		}
+		revs = append(revs, *rev)
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Revision > revs[j].Revision })
+	return revs, nil
+}
@@ -0,0 +1,128 @@
+package iasiutils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fetcher retrieves raw Infoarena pages. Every scraper goes through a Fetcher so
+// pages can be recorded to disk and replayed without network access.
+type Fetcher interface {
+	// Get returns the body of the page at url.
+	Get(url string) ([]byte, error)
+	// PostForm submits an url-encoded form to url and returns the response body.
+	PostForm(url string, form string) ([]byte, error)
+}
+
+// Fetch modes selected through the IASI_FETCH_MODE environment variable.
+const (
+	FetchModeLive   = "live"
+	FetchModeRecord = "record"
+	FetchModeReplay = "replay"
+)
+
+// DefaultFixturesDir is where recorded pages are stored when IASI_FIXTURES_DIR is not set.
+const DefaultFixturesDir = "data/fixtures"
+
+// RecordingFetcher forwards requests to Inner and saves every response body in Dir.
+type RecordingFetcher struct {
+	Inner Fetcher
+	Dir   string
+}
+
+// Get fetches url through Inner and records the response.
+func (rf *RecordingFetcher) Get(url string) ([]byte, error) {
+	body, err := rf.Inner.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	return body, rf.save(fixturePath(rf.Dir, "GET", url, ""), body)
+}
+
+// PostForm posts form through Inner and records the response.
+func (rf *RecordingFetcher) PostForm(url string, form string) ([]byte, error) {
+	body, err := rf.Inner.PostForm(url, form)
+	if err != nil {
+		return nil, err
+	}
+	return body, rf.save(fixturePath(rf.Dir, "POST", url, form), body)
+}
+
+func (rf *RecordingFetcher) save(path string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create fixtures directory: %w", err)
+	}
+	log.Printf("[DEBUG] Recording fixture: %s", path)
+	return ioutil.WriteFile(path, body, 0644)
+}
+
+// ReplayFetcher serves responses previously saved by a RecordingFetcher from Dir.
+type ReplayFetcher struct {
+	Dir string
+}
+
+// Get returns the recorded body for url.
+func (pf *ReplayFetcher) Get(url string) ([]byte, error) {
+	return pf.load(fixturePath(pf.Dir, "GET", url, ""), url)
+}
+
+// PostForm returns the recorded body for the form post to url.
+func (pf *ReplayFetcher) PostForm(url string, form string) ([]byte, error) {
+	return pf.load(fixturePath(pf.Dir, "POST", url, form), url)
+}
+
+func (pf *ReplayFetcher) load(path, url string) ([]byte, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recorded fixture for %s (expected %s)", url, path)
+		}
+		return nil, err
+	}
+	log.Printf("[DEBUG] Replaying fixture: %s", path)
+	return body, nil
+}
+
+// fixturePath maps a request to a stable file name: a readable prefix from the URL plus a hash of the full request.
+func fixturePath(dir, method, url, form string) string {
+	sum := sha1.Sum([]byte(method + " " + url + "\n" + form))
+	name := strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return filepath.Join(dir, strings.ToLower(method)+"_"+name+"_"+hex.EncodeToString(sum[:])[:12]+".html")
+}
+
+// NewFetcherFromEnv builds the Fetcher selected by IASI_FETCH_MODE (live, record or replay).
+// Fixtures are read from and written to IASI_FIXTURES_DIR, defaulting to DefaultFixturesDir.
+func NewFetcherFromEnv() (Fetcher, error) {
+	dir := os.Getenv("IASI_FIXTURES_DIR")
+	if dir == "" {
+		dir = DefaultFixturesDir
+	}
+	live := DefaultScrapeClient()
+	switch mode := strings.ToLower(os.Getenv("IASI_FETCH_MODE")); mode {
+	case "", FetchModeLive:
+		return live, nil
+	case FetchModeRecord:
+		log.Printf("[INFO] Recording Infoarena responses to %s", dir)
+		return &RecordingFetcher{Inner: live, Dir: dir}, nil
+	case FetchModeReplay:
+		log.Printf("[INFO] Replaying Infoarena responses from %s", dir)
+		return &ReplayFetcher{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown IASI_FETCH_MODE %q (want live, record or replay)", mode)
+	}
+}
@@ -0,0 +1,182 @@
+package iasiutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// fixturesDir holds a recorded session of one user: the monitor page, job 3012 with its
+// job_detail, problem and view-source pages, including the "Vezi sursa" form post.
+const fixturesDir = "testdata/fixtures"
+
+// stubFetcher serves pages from memory, keyed by "GET url" or "POST url form", and counts the calls.
+type stubFetcher struct {
+	pages map[string]string
+	calls int
+}
+
+func (sf *stubFetcher) Get(url string) ([]byte, error) {
+	return sf.page("GET " + url)
+}
+
+func (sf *stubFetcher) PostForm(url string, form string) ([]byte, error) {
+	return sf.page("POST " + url + " " + form)
+}
+
+func (sf *stubFetcher) page(key string) ([]byte, error) {
+	sf.calls++
+	body, ok := sf.pages[key]
+	if !ok {
+		return nil, fmt.Errorf("unexpected request %s", key)
+	}
+	return []byte(body), nil
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	live := &stubFetcher{pages: map[string]string{
+		"GET https://www.infoarena.ro/problema/adunare":                                              "<h1>Adunare</h1>",
+		"GET https://www.infoarena.ro/job_detail/1?action=view-source":                               "<form id=\"force_view_source\"></form>",
+		"POST https://www.infoarena.ro/job_detail/1?action=view-source force_view_source=Vezi+sursa": "<pre>int main() {}</pre>",
+	}}
+	recorder := &RecordingFetcher{Inner: live, Dir: dir}
+	requests := []struct {
+		method, url, form string
+	}{
+		{"GET", "https://www.infoarena.ro/problema/adunare", ""},
+		{"GET", "https://www.infoarena.ro/job_detail/1?action=view-source", ""},
+		{"POST", "https://www.infoarena.ro/job_detail/1?action=view-source", "force_view_source=Vezi+sursa"},
+	}
+	fetch := func(f Fetcher, method, url, form string) ([]byte, error) {
+		if method == "POST" {
+			return f.PostForm(url, form)
+		}
+		return f.Get(url)
+	}
+	recorded := make([]string, len(requests))
+	for i, r := range requests {
+		body, err := fetch(recorder, r.method, r.url, r.form)
+		if err != nil {
+			t.Fatalf("record %s %s: %v", r.method, r.url, err)
+		}
+		recorded[i] = string(body)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(requests) {
+		t.Fatalf("recorded %d files, want %d", len(files), len(requests))
+	}
+
+	calls := live.calls
+	replay := &ReplayFetcher{Dir: dir}
+	for i, r := range requests {
+		body, err := fetch(replay, r.method, r.url, r.form)
+		if err != nil {
+			t.Fatalf("replay %s %s: %v", r.method, r.url, err)
+		}
+		if string(body) != recorded[i] {
+			t.Errorf("replay %s %s = %q, want %q", r.method, r.url, body, recorded[i])
+		}
+	}
+	if live.calls != calls {
+		t.Errorf("replay made %d live requests", live.calls-calls)
+	}
+}
+
+func TestRecordingFetcherSkipsErrors(t *testing.T) {
+	dir := t.TempDir()
+	recorder := &RecordingFetcher{Inner: &stubFetcher{}, Dir: dir}
+	if _, err := recorder.Get("https://www.infoarena.ro/problema/missing"); err == nil {
+		t.Fatal("Get succeeded, want the error of the inner fetcher")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("recorded %d files for a failed request", len(files))
+	}
+}
+
+func TestReplayFetcherMiss(t *testing.T) {
+	replay := &ReplayFetcher{Dir: t.TempDir()}
+	_, err := replay.Get("https://www.infoarena.ro/problema/adunare")
+	if err == nil || !strings.Contains(err.Error(), "no recorded fixture") {
+		t.Fatalf("Get error = %v, want a missing fixture", err)
+	}
+	// A form post is a different request than a GET of the same URL.
+	replay = &ReplayFetcher{Dir: fixturesDir}
+	if _, err := replay.PostForm("https://www.infoarena.ro/problema/adunare", "a=b"); err == nil {
+		t.Error("PostForm of a page only recorded as GET succeeded")
+	}
+}
+
+func TestReplayFixtures(t *testing.T) {
+	replay := &ReplayFetcher{Dir: fixturesDir}
+	subs, err := FetchMonitorPage(replay, "mentor", 0, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 3 || subs[0].JobID != "3012" || subs[0].ProblemSlug != "adunare" {
+		t.Fatalf("monitor page = %+v, want 3 submissions starting with job 3012 on adunare", subs)
+	}
+
+	ii := &InfoarenaIngestor{Fetcher: replay}
+	jd, err := ii.FetchJobDetail("3012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jd.ProblemURL != "https://www.infoarena.ro/problema/adunare" || jd.Compiler != "cpp-64" {
+		t.Errorf("job detail = %+v", jd)
+	}
+	ps, err := ii.FetchProblemStatement(jd.ProblemURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.Title != "Adunare" || !ps.Structured() {
+		t.Errorf("statement title %q, structured %v", ps.Title, ps.Structured())
+	}
+	source, err := ii.FetchSource("3012", jd.Compiler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Language != LangCpp || !strings.Contains(source.Code, "fout << x + y") {
+		t.Errorf("source = %+v", source)
+	}
+}
+
+func TestNewFetcherFromEnv(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"", "*iasiutils.ScrapeClient", false},
+		{"live", "*iasiutils.ScrapeClient", false},
+		{"record", "*iasiutils.RecordingFetcher", false},
+		{"REPLAY", "*iasiutils.ReplayFetcher", false},
+		{"offline", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("IASI_FETCH_MODE", tt.mode)
+			t.Setenv("IASI_FIXTURES_DIR", fixturesDir)
+			f, err := NewFetcherFromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewFetcherFromEnv() = %T, want an error", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%T", f); got != tt.want {
+				t.Errorf("NewFetcherFromEnv() = %s, want %s", got, tt.want)
+			}
+			if rf, ok := f.(*ReplayFetcher); ok && rf.Dir != fixturesDir {
+				t.Errorf("replay dir = %q, want %q", rf.Dir, fixturesDir)
+			}
+		})
+	}
+}
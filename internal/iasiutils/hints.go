@@ -0,0 +1,182 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHintSteps is the number of hints of a chain before the editorial, unless the request sets one.
+const DefaultHintSteps = 3
+
+// HintChain is the hints of a problem revealed one at a time, ending with the editorial.
+type HintChain struct {
+	Hints []string `json:"hints"`
+	// Steps is the number of hints given before the editorial.
+	Steps int `json:"steps"`
+	// Editorial is written by the final step; Done is set once it is.
+	Editorial string `json:"editorial,omitempty"`
+	Done      bool   `json:"done"`
+	Recipe    string `json:"recipe,omitempty"`
+	Lang      string `json:"lang,omitempty"`
+	// Calls and Usage count the model calls of all the steps.
+	Calls int        `json:"calls"`
+	Usage TokenUsage `json:"usage"`
+	// Leak is the leak report of the chain so far, see CheckSpoilers.
+	Leak      *LeakReport `json:"leak,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// HintStep is one model answer extending a HintChain.
+type HintStep struct {
+	// Text is the next hint, or the editorial when Final is set.
+	Text     string
+	Final    bool
+	Provider string
+	Model    string
+	Attempts int
+	Usage    TokenUsage
+	Leak     *LeakReport
+}
+
+// Add extends the chain with step.
+func (c *HintChain) Add(step *HintStep) {
+	if step.Final {
+		c.Editorial = step.Text
+		c.Done = true
+	} else {
+		c.Hints = append(c.Hints, step.Text)
+	}
+	c.Calls += step.Attempts
+	c.Usage = c.Usage.Add(step.Usage)
+	if step.Leak != nil {
+		c.Leak = step.Leak
+	}
+	c.UpdatedAt = time.Now().UTC()
+}
+
+// EditorialRevision returns the editorial ending the chain with its final step, made with recipe, so
+// it can be cached like a generated one. Its calls and usage are those of the whole chain. c is not
+// modified.
+func (c *HintChain) EditorialRevision(final *HintStep, recipe *Recipe) *EditorialRevision {
+	done := *c
+	done.Hints = append([]string{}, c.Hints...)
+	done.Add(final)
+	return &EditorialRevision{Editorial: *done.editorial(), Leak: final.Leak, Provenance: Provenance{
+		Provider:   final.Provider,
+		Model:      final.Model,
+		Recipe:     recipe.Name,
+		RecipeHash: recipe.Hash(),
+		Lang:       c.Lang,
+		CreatedAt:  done.UpdatedAt,
+		Attempts:   done.Calls,
+		Usage:      done.Usage,
+	}}
+}
+
+// editorial returns the chain as an Editorial, for CheckSpoilers.
+func (c *HintChain) editorial() *Editorial {
+	return &Editorial{Hints: c.Hints, Editorial: c.Editorial}
+}
+
+// NextHint asks llm for the step of chain after its revealed hints: the next hint, or the editorial
+// once chain.Steps hints were given. An empty answer, or one giving away the solution as judged by
+// CheckSpoilers and the recipe's SpoilerPolicy, is sent back to the model once to be corrected, like
+// in GenerateEditorial; if that also fails an *InvalidOutputError is returned. chain is not modified.
+func NextHint(ctx context.Context, llm LLMProvider, recipe *Recipe, ps *ProblemStatement, source *SourceFile, chain *HintChain) (*HintStep, error) {
+	prompt, systemPrompt, err := recipe.BuildHintPrompt(ps, source, chain.Hints, chain.Steps)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] Hint prompt: %s", prompt)
+	policy := DefaultSpoilerPolicy
+	if recipe.Spoilers != nil {
+		policy = *recipe.Spoilers
+	}
+	step := &HintStep{Final: len(chain.Hints) >= chain.Steps}
+	req := LLMRequest{SystemPrompt: systemPrompt, Prompt: prompt}
+	const maxAttempts = 2
+	for step.Attempts = 1; ; step.Attempts++ {
+		resp, err := llm.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[INFO] LLM response received. Raw response: %s", resp.Text)
+		step.Usage = step.Usage.Add(resp.Usage)
+		step.Provider, step.Model = resp.Provider, resp.Model
+		if step.Provider == "" {
+			step.Provider, step.Model = llm.Name(), llm.Model()
+		}
+		step.Text = strings.TrimSpace(resp.Text)
+		var problems []string
+		if step.Text == "" {
+			problems = []string{"the answer is empty"}
+		} else if source != nil {
+			next := *chain
+			next.Hints = append([]string(nil), chain.Hints...)
+			next.Add(step)
+			report := CheckSpoilers(next.editorial(), ps, source)
+			step.Leak = &report
+			if policy.RejectScore > 0 && report.Score >= policy.RejectScore {
+				problems = append([]string{fmt.Sprintf("the answer gives away the solution (leak score %.2f)", report.Score)}, report.Problems...)
+			} else if policy.FlagScore > 0 && report.Score >= policy.FlagScore {
+				report.Flagged = true
+				log.Printf("[WARN] Hint chain may give away the solution (leak score %.2f): %s", report.Score, strings.Join(report.Problems, "; "))
+			}
+		}
+		if problems == nil {
+			return step, nil
+		}
+		log.Printf("[WARN] Hint rejected (attempt %d): %s", step.Attempts, strings.Join(problems, "; "))
+		if step.Attempts == maxAttempts {
+			return nil, &InvalidOutputError{Problems: problems, Raw: resp.Text, Attempts: step.Attempts, Usage: step.Usage}
+		}
+		req.Prompt = repairPrompt(prompt, resp.Text, problems, "Answer with the corrected text only.")
+	}
+}
+
+// DefaultHintsDir is where hint chains are kept when HintStore.Dir is empty.
+const DefaultHintsDir = "data/hints"
+
+// HintStore keeps the hint chain of each problem and language on disk. It is safe for concurrent use.
+type HintStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+func (hs *HintStore) path(slug, lang string) string {
+	dir := hs.Dir
+	if dir == "" {
+		dir = DefaultHintsDir
+	}
+	return filepath.Join(dir, langName(slug, lang)+".json")
+}
+
+// Load returns the hint chain of slug in lang. The error satisfies os.IsNotExist when none was started.
+func (hs *HintStore) Load(slug, lang string) (*HintChain, error) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	data, err := ioutil.ReadFile(hs.path(slug, lang))
+	if err != nil {
+		return nil, err
+	}
+	var chain HintChain
+	if err := json.Unmarshal(data, &chain); err != nil {
+		return nil, fmt.Errorf("failed to parse hint chain of %s: %w", slug, err)
+	}
+	return &chain, nil
+}
+
+// Save stores chain as the hint chain of slug in lang.
+func (hs *HintStore) Save(slug, lang string, chain *HintChain) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	return writeJSONFile(hs.path(slug, lang), chain)
+}
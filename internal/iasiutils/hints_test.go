@@ -0,0 +1,132 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHintChainAdd(t *testing.T) {
+	chain := &HintChain{Steps: 1}
+	leak := &LeakReport{Score: 0.2}
+	chain.Add(&HintStep{Text: "Add them.", Attempts: 2, Usage: TokenUsage{TotalTokens: 10}, Leak: leak})
+	chain.Add(&HintStep{Text: "## Solution", Final: true, Attempts: 1, Usage: TokenUsage{TotalTokens: 5}})
+	if len(chain.Hints) != 1 || chain.Editorial != "## Solution" || !chain.Done {
+		t.Errorf("chain = %+v, want one hint and the editorial", chain)
+	}
+	if chain.Calls != 3 || chain.Usage.TotalTokens != 15 || chain.Leak != leak || chain.UpdatedAt.IsZero() {
+		t.Errorf("calls %d, usage %+v, leak %+v; want the steps summed and the last leak report kept", chain.Calls, chain.Usage, chain.Leak)
+	}
+}
+
+func TestHintChainEditorialRevision(t *testing.T) {
+	chain := &HintChain{Hints: []string{"Add them."}, Steps: 1, Lang: "ro", Calls: 1, Usage: TokenUsage{TotalTokens: 10}}
+	leak := &LeakReport{Score: 0.1}
+	final := &HintStep{Text: "## Solution", Final: true, Provider: "fake", Model: "fake-1", Attempts: 2, Usage: TokenUsage{TotalTokens: 5}, Leak: leak}
+	rev := chain.EditorialRevision(final, hintRecipe(t))
+	if len(rev.Hints) != 1 || rev.Hints[0] != "Add them." || rev.Editorial.Editorial != "## Solution" || rev.Leak != leak {
+		t.Errorf("editorial = %+v, want the hints and the final step", rev)
+	}
+	if rev.Provider != "fake" || rev.Model != "fake-1" || rev.Recipe != DefaultRecipeName || rev.RecipeHash == "" || rev.Lang != "ro" {
+		t.Errorf("provenance = %+v", rev.Provenance)
+	}
+	if rev.Attempts != 3 || rev.Usage.TotalTokens != 15 {
+		t.Errorf("calls %d, usage %+v; want those of the whole chain", rev.Attempts, rev.Usage)
+	}
+	if chain.Done || chain.Calls != 1 {
+		t.Errorf("chain was modified: %+v", chain)
+	}
+}
+
+func hintRecipe(t *testing.T) *Recipe {
+	t.Helper()
+	r, err := ParseRecipe(DefaultRecipeName, defaultRecipeTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Spoilers = &SpoilerPolicy{RejectScore: 0.3}
+	return r
+}
+
+var (
+	hintStatement = &ProblemStatement{Title: "Adunare", Task: "Se dau a si b. Afisati a+b."}
+	hintSource    = &SourceFile{Language: LangCpp, Code: "int main() {\n    long long sumOfBoth = a + b;\n    printf(\"%lld\", sumOfBoth);\n}"}
+)
+
+func TestNextHint(t *testing.T) {
+	fake := NewFakeProvider(FakeStep{Text: "  Mind the overflow.\n"}, FakeStep{Text: "## Idea\nUse 64-bit integers."})
+	chain := &HintChain{Hints: []string{"Think about the range."}, Steps: 2}
+	step, err := NextHint(context.Background(), fake, hintRecipe(t), hintStatement, hintSource, chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step.Text != "Mind the overflow." || step.Final || step.Attempts != 1 || step.Provider != "fake" || step.Leak == nil {
+		t.Errorf("step = %+v", step)
+	}
+	prompt := fake.Calls()[0].Prompt
+	if !strings.Contains(prompt, "hint 2 of 2") || !strings.Contains(prompt, "- Think about the range.") {
+		t.Errorf("prompt is not conditioned on the revealed hints:\n%s", prompt)
+	}
+	if len(chain.Hints) != 1 {
+		t.Errorf("NextHint() modified the chain: %+v", chain)
+	}
+
+	chain.Add(step)
+	final, err := NextHint(context.Background(), fake, hintRecipe(t), hintStatement, hintSource, chain)
+	if err != nil || !final.Final || !strings.Contains(fake.Calls()[1].Prompt, "editorial only") {
+		t.Errorf("final step = %+v, %v; want the editorial asked for", final, err)
+	}
+}
+
+func TestNextHintRepairs(t *testing.T) {
+	leaky := "```cpp\nlong long sumOfBoth = a + b;\n```"
+	tests := []struct {
+		name     string
+		steps    []FakeStep
+		attempts int
+		problem  string
+	}{
+		{"empty answer", []FakeStep{{Text: " "}, {Text: "Mind the overflow."}}, 2, ""},
+		{"leak repaired", []FakeStep{{Text: leaky}, {Text: "Mind the overflow."}}, 2, ""},
+		{"still leaking", []FakeStep{{Text: leaky}}, 2, "gives away the solution"},
+		{"still empty", []FakeStep{{Text: ""}}, 2, "the answer is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeProvider(tt.steps...)
+			step, err := NextHint(context.Background(), fake, hintRecipe(t), hintStatement, hintSource, &HintChain{Steps: 2})
+			if tt.problem == "" {
+				if err != nil || step.Text != "Mind the overflow." || step.Attempts != tt.attempts {
+					t.Fatalf("NextHint() = %+v, %v", step, err)
+				}
+				if !strings.Contains(fake.Calls()[1].Prompt, "Answer with the corrected text only.") {
+					t.Errorf("repair prompt:\n%s", fake.Calls()[1].Prompt)
+				}
+				return
+			}
+			var invalid *InvalidOutputError
+			if !errors.As(err, &invalid) || invalid.Attempts != tt.attempts || !strings.Contains(strings.Join(invalid.Problems, "\n"), tt.problem) {
+				t.Fatalf("NextHint() error = %v, want an invalid output mentioning %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestHintStore(t *testing.T) {
+	hs := &HintStore{Dir: t.TempDir()}
+	if _, err := hs.Load("adunare", "en"); !os.IsNotExist(err) {
+		t.Fatalf("Load() before Save = %v, want not exist", err)
+	}
+	if err := hs.Save("adunare", "ro", &HintChain{Hints: []string{"Gândeşte-te la depăşire."}, Steps: 2, Lang: "ro"}); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := hs.Load("adunare", "ro")
+	if err != nil || len(chain.Hints) != 1 || chain.Hints[0] != "Gândeşte-te la depăşire." || chain.Steps != 2 {
+		t.Fatalf("Load() = %+v, %v", chain, err)
+	}
+	if _, err := hs.Load("adunare", DefaultLang); !os.IsNotExist(err) {
+		t.Errorf("chains of other languages are shared: %v", err)
+	}
+}
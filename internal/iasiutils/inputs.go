@@ -0,0 +1,66 @@
+package iasiutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ProblemInputs are the statement and the solution source the prompts of a problem are built from.
+type ProblemInputs struct {
+	// JobID is the submission the source was taken from.
+	JobID     string            `json:"job_id"`
+	Statement *ProblemStatement `json:"statement"`
+	Source    *SourceFile       `json:"source"`
+	FetchedAt time.Time         `json:"fetched_at"`
+}
+
+// DefaultInputsDir is where problem inputs are kept when InputStore.Dir is empty.
+const DefaultInputsDir = "data/inputs"
+
+// InputStore keeps the scraped inputs of each problem on disk, so generating, revealing hints and
+// chatting about a problem only scrape Infoarena once. It is safe for concurrent use.
+type InputStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+func (is *InputStore) path(slug string) string {
+	dir := is.Dir
+	if dir == "" {
+		dir = DefaultInputsDir
+	}
+	return filepath.Join(dir, slug+".json")
+}
+
+// Load returns the inputs of slug taken from the submission jobID. The error satisfies os.IsNotExist
+// when none were stored, or only for another submission.
+func (is *InputStore) Load(slug, jobID string) (*ProblemInputs, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	path := is.path(slug)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var inputs ProblemInputs
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to parse inputs of %s: %w", slug, err)
+	}
+	if inputs.JobID != jobID || inputs.Statement == nil || inputs.Source == nil {
+		return nil, &os.PathError{Op: "load", Path: path, Err: os.ErrNotExist}
+	}
+	return &inputs, nil
+}
+
+// Save stores inputs as the inputs of slug.
+func (is *InputStore) Save(slug string, inputs *ProblemInputs) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	return writeJSONFile(is.path(slug), inputs)
+}
@@ -0,0 +1,43 @@
+package iasiutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInputStore(t *testing.T) {
+	is := &InputStore{Dir: t.TempDir()}
+	if _, err := is.Load("adunare", "3012"); !os.IsNotExist(err) {
+		t.Fatalf("Load() before Save = %v, want not exist", err)
+	}
+	saved := &ProblemInputs{
+		JobID:     "3012",
+		Statement: &ProblemStatement{Title: "Adunare", Task: "Afişaţi suma.", Examples: []Example{{Input: "2 3", Output: "5"}}},
+		Source:    &SourceFile{Language: LangCpp, Code: "fout << x + y;"},
+		FetchedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if err := is.Save("adunare", saved); err != nil {
+		t.Fatal(err)
+	}
+	got, err := is.Load("adunare", "3012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Statement.String() != saved.Statement.String() || *got.Source != *saved.Source || !got.FetchedAt.Equal(saved.FetchedAt) {
+		t.Errorf("Load() = %+v, want %+v", got, saved)
+	}
+
+	// Inputs taken from another submission, or incomplete ones, are fetched again.
+	if _, err := is.Load("adunare", "3013"); !os.IsNotExist(err) {
+		t.Errorf("Load() for another job = %v, want not exist", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(is.Dir, "ciur.json"), []byte(`{"job_id":"7","statement":{"title":"Ciur"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Load("ciur", "7"); !os.IsNotExist(err) {
+		t.Errorf("Load() without a source = %v, want not exist", err)
+	}
+}
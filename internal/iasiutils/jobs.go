@@ -0,0 +1,347 @@
+package iasiutils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JobState is the lifecycle state of a generation job.
+type JobState string
+
+const (
+	JobQueued  JobState = "queued"
+	JobRunning JobState = "running"
+	JobDone    JobState = "done"
+	JobFailed  JobState = "failed"
+)
+
+// Finished reports whether the job will not change state anymore.
+func (s JobState) Finished() bool {
+	return s == JobDone || s == JobFailed
+}
+
+// Job is a background generation of one problem's editorial.
+type Job struct {
+	ID      string   `json:"id"`
+	Problem string   `json:"problem"`
+	State   JobState `json:"state"`
+	// Params are the generation options the job was submitted with, e.g. the recipe.
+	Params map[string]string `json:"params,omitempty"`
+	// Phase is the step a running job is at, as reported by its JobFunc.
+	Phase      string     `json:"phase,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobFunc does the work of a job for problem with the submitted params, calling phase as it progresses.
+type JobFunc func(ctx context.Context, problem string, params map[string]string, phase func(string)) error
+
+// DefaultJobsDir is where job state is persisted when JobQueue.Dir is empty.
+const DefaultJobsDir = "data/jobs"
+
+// finishedJobTTL is how long finished jobs are kept across restarts.
+const finishedJobTTL = 24 * time.Hour
+
+// ErrJobConflict is returned by Submit when the unfinished job it would deduplicate with was submitted
+// with other params.
+var ErrJobConflict = errors.New("a job for this problem is already queued with other parameters")
+
+// JobQueue runs jobs in the background with at most Workers running at once. There is at most one
+// unfinished job per problem and DedupeParams values: submitting one that is already queued or running
+// returns that job. Every state change is written to Dir so jobs survive a restart, see Resume.
+type JobQueue struct {
+	Dir     string
+	Workers int
+	Run     JobFunc
+	// DedupeParams names the params that tell jobs for the same problem apart.
+	DedupeParams []string
+	// Context is the parent of the contexts jobs run with; nil means context.Background(). Once it is
+	// done, running jobs are cancelled and no queued job starts; they are left unfinished on disk for
+	// Resume.
+	Context context.Context
+
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	byKey map[string]*Job
+	// results holds the outcome of each job queued by this process, see Wait.
+	results map[string]*jobResult
+	sem     chan struct{}
+	once    sync.Once
+	// saveMu keeps the writes of a job's file in order.
+	saveMu sync.Mutex
+}
+
+// jobResult is the outcome of a job: err is set before done is closed.
+type jobResult struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJobQueue returns a queue persisting to dir and running run on up to workers jobs at once.
+func NewJobQueue(dir string, workers int, run JobFunc) *JobQueue {
+	return &JobQueue{Dir: dir, Workers: workers, Run: run}
+}
+
+func (q *JobQueue) init() {
+	q.once.Do(func() {
+		q.jobs = make(map[string]*Job)
+		q.byKey = make(map[string]*Job)
+		q.results = make(map[string]*jobResult)
+		workers := q.Workers
+		if workers <= 0 {
+			workers = 1
+		}
+		q.sem = make(chan struct{}, workers)
+	})
+}
+
+func (q *JobQueue) dir() string {
+	if q.Dir != "" {
+		return q.Dir
+	}
+	return DefaultJobsDir
+}
+
+func (q *JobQueue) context() context.Context {
+	if q.Context != nil {
+		return q.Context
+	}
+	return context.Background()
+}
+
+// Resume loads the persisted jobs. Jobs that were queued or running when the process stopped are
+// queued again; finished jobs older than a day are deleted.
+func (q *JobQueue) Resume() error {
+	q.init()
+	files, err := filepath.Glob(filepath.Join(q.dir(), "*.json"))
+	if err != nil {
+		return err
+	}
+	var requeue []*Job
+	q.mu.Lock()
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Printf("[WARN] Failed to read job %s: %v", path, err)
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Printf("[WARN] Failed to decode job %s: %v", path, err)
+			continue
+		}
+		if job.State.Finished() {
+			if job.FinishedAt != nil && time.Since(*job.FinishedAt) > finishedJobTTL {
+				os.Remove(path)
+				continue
+			}
+			q.jobs[job.ID] = &job
+			continue
+		}
+		if q.byKey[q.key(job.Problem, job.Params)] != nil {
+			os.Remove(path) // Keep a single unfinished job per key.
+			continue
+		}
+		job.State = JobQueued
+		job.Phase = ""
+		job.StartedAt = nil
+		q.jobs[job.ID] = &job
+		q.byKey[q.key(job.Problem, job.Params)] = &job
+		q.results[job.ID] = &jobResult{done: make(chan struct{})}
+		requeue = append(requeue, &job)
+	}
+	q.mu.Unlock()
+	for _, job := range requeue {
+		log.Printf("[INFO] Resuming job %s for %s", job.ID, job.Problem)
+		q.save(job)
+		go q.work(job)
+	}
+	return nil
+}
+
+// Submit queues a job for problem, or returns the matching unfinished one already queued. created
+// reports whether a new job was queued. When the unfinished job has the same DedupeParams but differs
+// in other params, it is returned with ErrJobConflict.
+func (q *JobQueue) Submit(problem string, params map[string]string) (job Job, created bool, err error) {
+	q.init()
+	key := q.key(problem, params)
+	q.mu.Lock()
+	if existing := q.byKey[key]; existing != nil {
+		job = *existing
+		q.mu.Unlock()
+		if !sameParams(job.Params, params) {
+			return job, false, ErrJobConflict
+		}
+		return job, false, nil
+	}
+	j := &Job{ID: newJobID(), Problem: problem, State: JobQueued, Params: params, CreatedAt: time.Now()}
+	q.jobs[j.ID] = j
+	q.byKey[key] = j
+	q.results[j.ID] = &jobResult{done: make(chan struct{})}
+	q.mu.Unlock()
+	log.Printf("[INFO] Queued job %s for %s", j.ID, problem)
+	q.save(j)
+	go q.work(j)
+	return q.snapshot(j), true, nil
+}
+
+func sameParams(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// key identifies the jobs that are deduplicated together.
+func (q *JobQueue) key(problem string, params map[string]string) string {
+	key := problem
+	for _, name := range q.DedupeParams {
+		key += "\x00" + params[name]
+	}
+	return key
+}
+
+// Get returns the job with id.
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.init()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Wait waits until the job with id is finished and returns it with the error its JobFunc returned, or
+// with ctx.Err() once ctx is done. A job interrupted by the end of Context returns that context's
+// error; one that failed before this process started returns its Error.
+func (q *JobQueue) Wait(ctx context.Context, id string) (Job, error) {
+	q.init()
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	res := q.results[id]
+	q.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("unknown job %s", id)
+	}
+	if res == nil {
+		job := q.snapshot(j)
+		if job.State == JobFailed {
+			return job, errors.New(job.Error)
+		}
+		return job, nil
+	}
+	select {
+	case <-res.done:
+		return q.snapshot(j), res.err
+	case <-ctx.Done():
+		return q.snapshot(j), ctx.Err()
+	}
+}
+
+func (q *JobQueue) work(j *Job) {
+	q.mu.Lock()
+	res := q.results[j.ID]
+	q.mu.Unlock()
+	ctx := q.context()
+	select {
+	case q.sem <- struct{}{}:
+	case <-ctx.Done():
+	}
+	if ctx.Err() != nil {
+		log.Printf("[INFO] Job %s for %s not started, it runs on the next start", j.ID, j.Problem)
+		res.err = ctx.Err()
+		close(res.done)
+		return
+	}
+	defer func() { <-q.sem }()
+
+	q.update(j, func(j *Job) {
+		now := time.Now()
+		j.State = JobRunning
+		j.StartedAt = &now
+	})
+	log.Printf("[INFO] Running job %s for %s", j.ID, j.Problem)
+	err := q.Run(ctx, j.Problem, j.Params, func(phase string) {
+		q.update(j, func(j *Job) { j.Phase = phase })
+	})
+	if ctx.Err() != nil {
+		log.Printf("[INFO] Job %s for %s interrupted, it runs again on the next start", j.ID, j.Problem)
+		res.err = ctx.Err()
+		close(res.done)
+		return
+	}
+	q.update(j, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Phase = ""
+		if err != nil {
+			j.State = JobFailed
+			j.Error = err.Error()
+		} else {
+			j.State = JobDone
+		}
+		if key := q.key(j.Problem, j.Params); q.byKey[key] == j {
+			delete(q.byKey, key)
+		}
+	})
+	if err != nil {
+		log.Printf("[ERROR] Job %s for %s failed: %v", j.ID, j.Problem, err)
+	} else {
+		log.Printf("[INFO] Job %s for %s done", j.ID, j.Problem)
+	}
+	res.err = err
+	close(res.done)
+}
+
+// update applies fn to j under the lock and persists the result.
+func (q *JobQueue) update(j *Job, fn func(*Job)) {
+	q.mu.Lock()
+	fn(j)
+	q.mu.Unlock()
+	q.save(j)
+}
+
+func (q *JobQueue) snapshot(j *Job) Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *j
+}
+
+// save writes the job state to Dir, replacing the file atomically so a crash never leaves a
+// truncated job behind for Resume.
+func (q *JobQueue) save(j *Job) {
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	job := q.snapshot(j)
+	if err := writeJSONFile(filepath.Join(q.dir(), job.ID+".json"), job); err != nil {
+		log.Printf("[WARN] Failed to save job %s: %v", job.ID, err)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,193 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockedQueue returns a queue whose jobs run until release is closed.
+func blockedQueue(t *testing.T) (q *JobQueue, release chan struct{}) {
+	release = make(chan struct{})
+	q = NewJobQueue(t.TempDir(), 1, func(ctx context.Context, problem string, params map[string]string, phase func(string)) error {
+		<-release
+		return nil
+	})
+	q.DedupeParams = []string{"lang"}
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	return q, release
+}
+
+func waitJob(t *testing.T, q *JobQueue, id string) Job {
+	t.Helper()
+	// Wait returns once the final state is saved, so the test does not end while it is written.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, _ := q.Wait(ctx, id)
+	if ctx.Err() != nil {
+		t.Fatalf("job %s did not finish", id)
+	}
+	return job
+}
+
+func TestJobQueueDedupe(t *testing.T) {
+	q, release := blockedQueue(t)
+	first, created, err := q.Submit("adunare", map[string]string{"recipe": "default"})
+	if err != nil || !created {
+		t.Fatalf("first Submit() = %v, %v", created, err)
+	}
+	again, created, err := q.Submit("adunare", map[string]string{"recipe": "default"})
+	if err != nil || created || again.ID != first.ID {
+		t.Errorf("same params: job %s, created %v, %v; want job %s", again.ID, created, err, first.ID)
+	}
+	other, created, err := q.Submit("adunare", map[string]string{"recipe": "beginner"})
+	if !errors.Is(err, ErrJobConflict) || created || other.ID != first.ID || other.Params["recipe"] != "default" {
+		t.Errorf("other recipe: job %+v, created %v, %v; want the running job with ErrJobConflict", other, created, err)
+	}
+	lang, created, err := q.Submit("adunare", map[string]string{"recipe": "beginner", "lang": "ro"})
+	if err != nil || !created || lang.ID == first.ID {
+		t.Errorf("other language: created %v, %v; want a new job", created, err)
+	}
+
+	close(release)
+	waitJob(t, q, first.ID)
+	waitJob(t, q, lang.ID)
+	next, created, err := q.Submit("adunare", map[string]string{"recipe": "beginner"})
+	if err != nil || !created || next.ID == first.ID {
+		t.Errorf("after the job finished: created %v, %v; want a new job", created, err)
+	}
+	waitJob(t, q, next.ID)
+}
+
+func TestJobQueueResume(t *testing.T) {
+	q, release := blockedQueue(t)
+	dir := q.Dir
+	job, _, err := q.Submit("adunare", map[string]string{"recipe": "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved file is complete JSON and no temporary file is left next to it.
+	data, err := ioutil.ReadFile(filepath.Join(dir, job.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Job
+	if err := json.Unmarshal(data, &saved); err != nil || saved.ID != job.ID || saved.Params["recipe"] != "default" {
+		t.Fatalf("saved job %s: %+v, %v", data, saved, err)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+
+	// A new process finds the job unfinished and runs it again.
+	ran := make(chan string, 2)
+	restarted := NewJobQueue(dir, 1, func(ctx context.Context, problem string, params map[string]string, phase func(string)) error {
+		ran <- problem + " " + params["recipe"]
+		return nil
+	})
+	if err := restarted.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	resumed := waitJob(t, restarted, job.ID)
+	if resumed.State != JobDone || len(ran) != 1 || <-ran != "adunare default" {
+		t.Errorf("resumed job %+v, want the interrupted job run once with its params", resumed)
+	}
+	close(release)
+	waitJob(t, q, job.ID)
+}
+
+func TestJobQueueWait(t *testing.T) {
+	failure := errors.New("model unavailable")
+	q := NewJobQueue(t.TempDir(), 1, func(ctx context.Context, problem string, params map[string]string, phase func(string)) error {
+		if problem == "ciur" {
+			return failure
+		}
+		return nil
+	})
+	done, _, _ := q.Submit("adunare", nil)
+	if job, err := q.Wait(context.Background(), done.ID); err != nil || job.State != JobDone {
+		t.Errorf("Wait() = %+v, %v; want the done job", job, err)
+	}
+	failed, _, _ := q.Submit("ciur", nil)
+	if job, err := q.Wait(context.Background(), failed.ID); err != failure || job.State != JobFailed {
+		t.Errorf("Wait() = %+v, %v; want the failed job with the error of its run", job, err)
+	}
+
+	// A job failed before a restart returns the error it recorded.
+	restarted := NewJobQueue(q.Dir, 1, nil)
+	if err := restarted.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := restarted.Wait(context.Background(), failed.ID); err == nil || err.Error() != failure.Error() || job.State != JobFailed {
+		t.Errorf("Wait() after a restart = %+v, %v; want the recorded failure", job, err)
+	}
+	if _, err := restarted.Wait(context.Background(), "missing"); err == nil {
+		t.Error("Wait() of an unknown job succeeded")
+	}
+
+	// A waiter giving up does not stop the job.
+	blocked, release := blockedQueue(t)
+	job, _, _ := blocked.Submit("adunare", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := blocked.Wait(ctx, job.ID); err != context.DeadlineExceeded {
+		t.Errorf("Wait() of a blocked job = %v, want the deadline", err)
+	}
+	close(release)
+	if job := waitJob(t, blocked, job.ID); job.State != JobDone {
+		t.Errorf("job %+v, want it done after its waiter gave up", job)
+	}
+}
+
+func TestJobQueueContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, 2)
+	q := NewJobQueue(t.TempDir(), 1, func(ctx context.Context, problem string, params map[string]string, phase func(string)) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Context = ctx
+	running, _, _ := q.Submit("adunare", nil)
+	<-started
+	queued, _, _ := q.Submit("ciur", nil)
+
+	// Stopping the queue cancels the running job and keeps the queued one from starting; both are
+	// left unfinished for the next start.
+	cancel()
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, err := q.Wait(context.Background(), id); err != context.Canceled || job.State.Finished() {
+			t.Errorf("Wait() = %+v, %v; want the job interrupted", job, err)
+		}
+	}
+	if len(started) != 0 {
+		t.Error("the queued job started after the queue was stopped")
+	}
+	ran := make(chan string, 2)
+	restarted := NewJobQueue(q.Dir, 1, func(ctx context.Context, problem string, params map[string]string, phase func(string)) error {
+		ran <- problem
+		return nil
+	})
+	if err := restarted.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		if job := waitJob(t, restarted, id); job.State != JobDone {
+			t.Errorf("resumed job %+v, want it done", job)
+		}
+	}
+	if len(ran) != 2 {
+		t.Errorf("%d jobs ran after the restart, want 2", len(ran))
+	}
+}
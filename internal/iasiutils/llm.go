@@ -0,0 +1,234 @@
+package iasiutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LLMRequest is a prompt sent to a language model, optionally continuing a conversation.
+type LLMRequest struct {
+	SystemPrompt string
+	// History holds the earlier turns of a conversation, oldest first; Prompt is the new user turn.
+	History []ChatMessage
+	Prompt  string
+	// Schema asks for a JSON answer shaped like an Editorial. Providers that support structured
+	// output enforce it; the answer is validated by the caller either way.
+	Schema *OutputSchema
+}
+
+// LLMResponse is the text produced by a language model for an LLMRequest.
+type LLMResponse struct {
+	Text string
+	// Provider and Model name the backend that answered, see LLMProvider.
+	Provider string
+	Model    string
+	// Usage is the token count reported by the backend; it is zero when the backend reports none.
+	Usage TokenUsage
+}
+
+// TokenUsage counts the tokens of one or more model calls.
+type TokenUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// Add returns the sum of u and other.
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens: u.PromptTokens + other.PromptTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		TotalTokens:  u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// LLMProvider generates text with a language model backend.
+type LLMProvider interface {
+	// Name identifies the backend, e.g. "gemini", "openai" or "ollama".
+	Name() string
+	// Model is the model the provider sends requests to.
+	Model() string
+	Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+	// GenerateStream is Generate with the answer passed to onChunk piece by piece as it arrives.
+	// The returned response holds the whole text.
+	GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error)
+}
+
+// LLM providers selectable through IASI_LLM_PROVIDER.
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
+
+// NewLLMProviderFromEnv builds the provider selected by IASI_LLM_PROVIDER (gemini, openai, ollama or fake,
+// default gemini). IASI_LLM_MODEL, IASI_LLM_BASE_URL and IASI_LLM_TIMEOUT override the provider defaults;
+// API keys are read from GEMINI_API_KEY or OPENAI_API_KEY. Transient failures are retried IASI_LLM_RETRIES
+// times (default 2). When IASI_LLM_FALLBACK_PROVIDER is set, calls that still fail in a way that
+// FallsBack are sent to it, with IASI_LLM_FALLBACK_MODEL and IASI_LLM_FALLBACK_BASE_URL.
+func NewLLMProviderFromEnv() (LLMProvider, error) {
+	retries := EnvInt("IASI_LLM_RETRIES", 2)
+	name := strings.ToLower(EnvString("IASI_LLM_PROVIDER", ProviderGemini))
+	primary, err := NewLLMProvider(name, EnvString("IASI_LLM_MODEL", ""), EnvString("IASI_LLM_BASE_URL", ""))
+	if err != nil {
+		return nil, err
+	}
+	llm := LLMProvider(NewRetryProvider(primary, retries))
+	if name := strings.ToLower(EnvString("IASI_LLM_FALLBACK_PROVIDER", "")); name != "" {
+		fallback, err := NewLLMProvider(name, EnvString("IASI_LLM_FALLBACK_MODEL", ""), EnvString("IASI_LLM_FALLBACK_BASE_URL", ""))
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+		log.Printf("[INFO] LLM fallback provider %s (model %s)", fallback.Name(), fallback.Model())
+		llm = NewFallbackProvider(llm, NewRetryProvider(fallback, retries))
+	}
+	return llm, nil
+}
+
+// NewLLMProvider builds the named provider. Empty model and baseURL select the provider defaults.
+// IASI_LLM_TIMEOUT bounds a whole call, and for streams the wait for the response headers and
+// between chunks, see postStream.
+func NewLLMProvider(name, model, baseURL string) (LLMProvider, error) {
+	timeout := EnvDuration("IASI_LLM_TIMEOUT", 60*time.Second)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	switch name {
+	case ProviderGemini:
+		return &GeminiProvider{APIKey: EnvString("GEMINI_API_KEY", ""), ModelName: model, BaseURL: baseURL, Client: client}, nil
+	case ProviderOpenAI:
+		return &OpenAIProvider{APIKey: EnvString("OPENAI_API_KEY", ""), ModelName: model, BaseURL: baseURL, Client: client}, nil
+	case ProviderOllama:
+		return &OllamaProvider{ModelName: model, BaseURL: baseURL, Client: client}, nil
+	case ProviderFake:
+		return &FakeProvider{ModelName: model}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q (want gemini, openai, ollama or fake)", name)
+}
+
+// postJSON posts payload as JSON to url and decodes a 2xx response into out. It returns the raw body.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload, out interface{}) ([]byte, error) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	// Log the raw LLM response for debugging
+	log.Printf("[DEBUG] Raw LLM API response: %s", TruncateString(string(body), 1000))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, httpError(resp, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return body, fmt.Errorf("failed to decode LLM response: %w", err)
+	}
+	return body, nil
+}
+
+// postStream posts payload as JSON to url and calls onLine with every non-empty line of a 2xx
+// streamed response, e.g. the "data:" lines of Server-Sent Events or NDJSON objects.
+//
+// client.Timeout would also cut off reading the body, so it does not apply to the whole stream: the
+// stream runs until ctx is done and only fails when no line arrives for that long. The time to the
+// response headers is bounded by the transport's ResponseHeaderTimeout.
+func postStream(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}, onLine func(line []byte) error) error {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	idle := client.Timeout
+	streaming := *client
+	streaming.Timeout = 0
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stalled int32
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.AfterFunc(idle, func() {
+			atomic.StoreInt32(&stalled, 1)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	stallError := func(err error) error {
+		if atomic.LoadInt32(&stalled) == 1 {
+			return &LLMError{Kind: LLMUnavailable, Message: fmt.Sprintf("stream stalled: nothing received for %s", idle), Err: err}
+		}
+		return transportError(ctx, err)
+	}
+	req, err := http.NewRequestWithContext(streamCtx, "POST", url, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := streaming.Do(req)
+	if err != nil {
+		return stallError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		log.Printf("[DEBUG] Raw LLM API response: %s", TruncateString(string(body), 1000))
+		return httpError(resp, body)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if timer != nil {
+			timer.Reset(idle)
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := onLine(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stallError(err)
+	}
+	return nil
+}
+
+// sseData returns the payload of a Server-Sent Events "data:" line.
+func sseData(line []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return nil, false
+	}
+	return bytes.TrimSpace(line[len("data:"):]), true
+}
@@ -0,0 +1,128 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LLMErrorKind classifies why a model call failed.
+type LLMErrorKind string
+
+const (
+	// LLMRateLimited means the quota or request rate of the account was exceeded.
+	LLMRateLimited LLMErrorKind = "rate_limited"
+	// LLMOverloaded means the backend is temporarily failing or overloaded.
+	LLMOverloaded LLMErrorKind = "overloaded"
+	// LLMUnavailable means the backend could not be reached or did not answer in time.
+	LLMUnavailable LLMErrorKind = "unavailable"
+	// LLMInvalidKey means the API key is missing, invalid or not allowed to use the model.
+	LLMInvalidKey LLMErrorKind = "invalid_key"
+	// LLMSafetyBlocked means the backend refused the prompt or the answer for safety reasons.
+	LLMSafetyBlocked LLMErrorKind = "safety_blocked"
+	// LLMEmptyResponse means the backend answered without any text.
+	LLMEmptyResponse LLMErrorKind = "empty_response"
+	// LLMBadRequest means the backend rejected the request, e.g. an unknown model.
+	LLMBadRequest LLMErrorKind = "bad_request"
+)
+
+// Transient reports whether a call failing with this kind may succeed when retried.
+func (k LLMErrorKind) Transient() bool {
+	return k == LLMRateLimited || k == LLMOverloaded || k == LLMUnavailable
+}
+
+// FallsBack reports whether a call failing with this kind may succeed on another backend: the transient
+// failures and empty answers. Rejected requests and keys and safety refusals would fail the same way or
+// hide their cause, so they are not sent elsewhere.
+func (k LLMErrorKind) FallsBack() bool {
+	return k.Transient() || k == LLMEmptyResponse
+}
+
+// LLMError is a classified failure of a model call.
+type LLMError struct {
+	Kind     LLMErrorKind
+	Provider string
+	Model    string
+	// Status is the HTTP status of the backend response, zero when there was none.
+	Status int
+	// RetryAfter is how long the backend asked to wait before retrying, zero when it did not say.
+	RetryAfter time.Duration
+	Message    string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *LLMError) Error() string {
+	var sb strings.Builder
+	if e.Provider != "" {
+		sb.WriteString(e.Provider + " ")
+	}
+	sb.WriteString(strings.ReplaceAll(string(e.Kind), "_", " "))
+	if e.Status != 0 {
+		fmt.Fprintf(&sb, " (HTTP %d)", e.Status)
+	}
+	if e.RetryAfter > 0 {
+		fmt.Fprintf(&sb, ", retry after %s", e.RetryAfter)
+	}
+	if e.Message != "" {
+		sb.WriteString(": " + e.Message)
+	} else if e.Err != nil {
+		sb.WriteString(": " + e.Err.Error())
+	}
+	return sb.String()
+}
+
+func (e *LLMError) Unwrap() error { return e.Err }
+
+// withSource sets the provider and model of err when it is an *LLMError that does not name them yet.
+func withSource(err error, provider, model string) error {
+	var le *LLMError
+	if errors.As(err, &le) && le.Provider == "" {
+		le.Provider = provider
+		le.Model = model
+	}
+	return err
+}
+
+// geminiRetryDelayRe finds the retry delay Gemini puts in the details of a 429 body.
+var geminiRetryDelayRe = regexp.MustCompile(`"retryDelay"\s*:\s*"(\d+(?:\.\d+)?)s"`)
+
+// httpError classifies a non-2xx backend response.
+func httpError(resp *http.Response, body []byte) *LLMError {
+	e := &LLMError{Status: resp.StatusCode, Message: TruncateString(strings.TrimSpace(string(body)), 500)}
+	lower := strings.ToLower(string(body))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Kind = LLMRateLimited
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden ||
+		strings.Contains(lower, "api key not valid") || strings.Contains(lower, "invalid_api_key"):
+		e.Kind = LLMInvalidKey
+	case resp.StatusCode >= 500:
+		e.Kind = LLMOverloaded
+	default:
+		e.Kind = LLMBadRequest
+	}
+	e.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	if e.RetryAfter <= 0 {
+		e.RetryAfter = 0
+		if m := geminiRetryDelayRe.FindSubmatch(body); m != nil {
+			secs, _ := strconv.ParseFloat(string(m[1]), 64)
+			e.RetryAfter = time.Duration(secs * float64(time.Second))
+		}
+	}
+	return e
+}
+
+// transportError classifies a failure to get a response from the backend. Cancellation by the
+// caller is returned as is.
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return &LLMError{Kind: LLMUnavailable, Err: err}
+}
@@ -0,0 +1,99 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     string
+		body       string
+		kind       LLMErrorKind
+		retryAfter time.Duration
+	}{
+		{"rate limited", 429, "7", `{}`, LLMRateLimited, 7 * time.Second},
+		{"gemini retry delay", 429, "", `{"error":{"details":[{"retryDelay": "1.5s"}]}}`, LLMRateLimited, 1500 * time.Millisecond},
+		{"unauthorized", 401, "", `{}`, LLMInvalidKey, 0},
+		{"forbidden", 403, "", `{}`, LLMInvalidKey, 0},
+		{"gemini invalid key", 400, "", `{"error":{"message":"API key not valid. Please pass a valid API key."}}`, LLMInvalidKey, 0},
+		{"openai invalid key", 400, "", `{"error":{"code":"invalid_api_key"}}`, LLMInvalidKey, 0},
+		{"overloaded", 503, "", `{"error":{"message":"The model is overloaded."}}`, LLMOverloaded, 0},
+		{"server error", 500, "", ``, LLMOverloaded, 0},
+		{"unknown model", 404, "", `{"error":{"message":"models/nope is not found"}}`, LLMBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			e := httpError(resp, []byte(tt.body))
+			if e.Kind != tt.kind || e.Status != tt.status || e.RetryAfter != tt.retryAfter {
+				t.Errorf("httpError() = %s, status %d, retry after %s; want %s, %d, %s", e.Kind, e.Status, e.RetryAfter, tt.kind, tt.status, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	cause := errors.New("connection refused")
+	var le *LLMError
+	if err := transportError(context.Background(), cause); !errors.As(err, &le) || le.Kind != LLMUnavailable || !errors.Is(err, cause) {
+		t.Errorf("transportError() = %v, want an unavailable backend wrapping the cause", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := transportError(ctx, cause); err != context.Canceled {
+		t.Errorf("transportError() after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestLLMErrorKindTransient(t *testing.T) {
+	for kind, want := range map[LLMErrorKind]bool{
+		LLMRateLimited: true, LLMOverloaded: true, LLMUnavailable: true,
+		LLMInvalidKey: false, LLMSafetyBlocked: false, LLMEmptyResponse: false, LLMBadRequest: false,
+	} {
+		if kind.Transient() != want {
+			t.Errorf("%s.Transient() = %v, want %v", kind, !want, want)
+		}
+	}
+}
+
+func TestLLMErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *LLMError
+		want string
+	}{
+		{&LLMError{Kind: LLMRateLimited, Provider: "gemini", Status: 429, RetryAfter: 2 * time.Second, Message: "quota"},
+			"gemini rate limited (HTTP 429), retry after 2s: quota"},
+		{&LLMError{Kind: LLMUnavailable, Err: errors.New("dial tcp: timeout")}, "unavailable: dial tcp: timeout"},
+		{&LLMError{Kind: LLMEmptyResponse}, "empty response"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestWithSource(t *testing.T) {
+	err := withSource(&LLMError{Kind: LLMOverloaded}, "gemini", "flash")
+	var le *LLMError
+	if !errors.As(err, &le) || le.Provider != "gemini" || le.Model != "flash" {
+		t.Fatalf("withSource() = %+v", err)
+	}
+	// The provider that failed first is kept when the error passes through a wrapper.
+	if withSource(err, "fallback", "other"); le.Provider != "gemini" || le.Model != "flash" {
+		t.Errorf("withSource() renamed the source to %s/%s", le.Provider, le.Model)
+	}
+	plain := errors.New("boom")
+	if withSource(plain, "gemini", "flash") != plain {
+		t.Error("withSource() changed an unclassified error")
+	}
+}
@@ -0,0 +1,106 @@
+package iasiutils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeStep is one scripted reply of a FakeProvider.
+type FakeStep struct {
+	// Text is returned as the response when Err is nil.
+	Text string
+	// Err is returned instead of a response when set.
+	Err error
+	// Delay is waited before replying, or until the request context is done.
+	Delay time.Duration
+}
+
+// FakeProvider is a deterministic LLMProvider for tests and offline UI work. It replies with
+// its Steps in order, repeating the last one, and records every request it receives.
+type FakeProvider struct {
+	Steps     []FakeStep
+	ModelName string
+
+	mu    sync.Mutex
+	calls []LLMRequest
+}
+
+// fakeEditorial is the canned reply of the provider selected with IASI_LLM_PROVIDER=fake.
+const fakeEditorial = `{"hints":["Think about what the problem asks for.","Try a smaller example by hand.","Look for a known algorithm that fits the constraints."],"editorial":"## Idea\n\nThis is a canned editorial from the fake LLM provider."}`
+
+// NewFakeProvider returns a FakeProvider scripted with steps.
+func NewFakeProvider(steps ...FakeStep) *FakeProvider {
+	return &FakeProvider{Steps: steps}
+}
+
+func (fp *FakeProvider) Name() string { return ProviderFake }
+
+func (fp *FakeProvider) Model() string {
+	if fp.ModelName != "" {
+		return fp.ModelName
+	}
+	return "fake"
+}
+
+// Generate replies with the next scripted step.
+func (fp *FakeProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	fp.mu.Lock()
+	n := len(fp.calls)
+	fp.calls = append(fp.calls, req)
+	step := FakeStep{Text: fakeEditorial}
+	if len(fp.Steps) > 0 {
+		if n >= len(fp.Steps) {
+			n = len(fp.Steps) - 1
+		}
+		step = fp.Steps[n]
+	}
+	fp.mu.Unlock()
+
+	if step.Delay > 0 {
+		select {
+		case <-time.After(step.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+	return &LLMResponse{Text: step.Text, Provider: fp.Name(), Model: fp.Model(), Usage: fakeUsage(req, step.Text)}, nil
+}
+
+// GenerateStream replies like Generate, passing the text to onChunk word by word.
+func (fp *FakeProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	resp, err := fp.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range strings.SplitAfter(resp.Text, " ") {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if chunk != "" {
+			onChunk(chunk)
+		}
+	}
+	return resp, nil
+}
+
+// fakeUsage counts words as tokens, so tests can check usage accounting.
+func fakeUsage(req LLMRequest, text string) TokenUsage {
+	prompt := len(strings.Fields(req.SystemPrompt)) + len(strings.Fields(req.Prompt))
+	for _, m := range req.History {
+		prompt += len(strings.Fields(m.Content))
+	}
+	output := len(strings.Fields(text))
+	return TokenUsage{PromptTokens: prompt, OutputTokens: output, TotalTokens: prompt + output}
+}
+
+// Calls returns the requests received so far.
+func (fp *FakeProvider) Calls() []LLMRequest {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return append([]LLMRequest(nil), fp.calls...)
+}
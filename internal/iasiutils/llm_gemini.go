@@ -0,0 +1,236 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GeminiProvider calls the Google Gemini generateContent API.
+type GeminiProvider struct {
+	APIKey    string
+	ModelName string
+	BaseURL   string
+	Client    *http.Client
+}
+
+const (
+	defaultGeminiModel = "gemini-1.5-flash"
+	// responseMimeType and responseSchema are only accepted by the v1beta surface.
+	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+)
+
+func (gp *GeminiProvider) Name() string { return ProviderGemini }
+
+func (gp *GeminiProvider) Model() string {
+	if gp.ModelName != "" {
+		return gp.ModelName
+	}
+	return defaultGeminiModel
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	// Role is "user" or "model".
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	ResponseMimeType string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]interface{} `json:"responseSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	// SystemInstruction carries the system prompt apart from the turns, so it applies to the whole
+	// conversation however much of the history is sent.
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// geminiSchema converts a JSON Schema to the OpenAPI subset Gemini accepts, which spells types in upper case.
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		switch val := v.(type) {
+		case string:
+			if k == "type" {
+				val = strings.ToUpper(val)
+			}
+			out[k] = val
+		case map[string]interface{}:
+			if k == "properties" {
+				props := make(map[string]interface{}, len(val))
+				for name, prop := range val {
+					props[name] = geminiSchema(prop.(map[string]interface{}))
+				}
+				out[k] = props
+			} else {
+				out[k] = geminiSchema(val)
+			}
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	// PromptFeedback tells why the prompt was blocked, if it was.
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// request builds the generateContent payload: the history turns, then the prompt as a user turn, with
+// the system prompt as the system instruction.
+func (gp *GeminiProvider) request(req LLMRequest) geminiRequest {
+	var contents []geminiContent
+	for _, m := range req.History {
+		role := "user"
+		if m.Role == ChatRoleAssistant {
+			role = "model"
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: []geminiPart{{Text: m.Content}}})
+	}
+	contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: req.Prompt}}})
+	payload := geminiRequest{Contents: contents}
+	if strings.TrimSpace(req.SystemPrompt) != "" {
+		payload.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.SystemPrompt}}}
+	}
+	if req.Schema != nil {
+		payload.GenerationConfig = &geminiGenerationConfig{
+			ResponseMimeType: "application/json",
+			ResponseSchema:   geminiSchema(req.Schema.JSONSchema()),
+		}
+	}
+	return payload
+}
+
+// endpoint returns the URL of a model method such as "generateContent".
+func (gp *GeminiProvider) endpoint(method string) (string, error) {
+	if gp.APIKey == "" {
+		return "", &LLMError{Kind: LLMInvalidKey, Message: "GEMINI_API_KEY not set"}
+	}
+	base := gp.BaseURL
+	if base == "" {
+		base = defaultGeminiBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/models/" + gp.Model() + ":" + method + "?key=" + gp.APIKey, nil
+}
+
+// text joins the parts of the first candidate.
+func (r *geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, p := range r.Candidates[0].Content.Parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
+
+// geminiBlockReasons are the finish reasons of an answer withheld by Gemini's filters.
+var geminiBlockReasons = map[string]bool{"SAFETY": true, "PROHIBITED_CONTENT": true, "BLOCKLIST": true, "SPII": true, "RECITATION": true}
+
+// blockReason returns why Gemini withheld the answer, or "" if it did not.
+func (r *geminiResponse) blockReason() string {
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		return r.PromptFeedback.BlockReason
+	}
+	if len(r.Candidates) > 0 && geminiBlockReasons[r.Candidates[0].FinishReason] {
+		return r.Candidates[0].FinishReason
+	}
+	return ""
+}
+
+// emptyError explains an answer without text.
+func (r *geminiResponse) emptyError(raw string) error {
+	if reason := r.blockReason(); reason != "" {
+		return &LLMError{Kind: LLMSafetyBlocked, Message: "blocked by Gemini: " + reason}
+	}
+	return &LLMError{Kind: LLMEmptyResponse, Message: "No LLM response candidates. Raw response: " + TruncateString(raw, 1000)}
+}
+
+// usage returns the token counts of the response. Streamed chunks each carry the counts so far.
+func (r *geminiResponse) usage() (TokenUsage, bool) {
+	if r.UsageMetadata == nil {
+		return TokenUsage{}, false
+	}
+	return TokenUsage{
+		PromptTokens: r.UsageMetadata.PromptTokenCount,
+		OutputTokens: r.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:  r.UsageMetadata.TotalTokenCount,
+	}, true
+}
+
+// Generate calls generateContent.
+func (gp *GeminiProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	url, err := gp.endpoint("generateContent")
+	if err != nil {
+		return nil, err
+	}
+	var parsed geminiResponse
+	body, err := postJSON(ctx, gp.Client, url, nil, gp.request(req), &parsed)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Candidates) == 0 || len(parsed.Candidates[0].Content.Parts) == 0 {
+		return nil, parsed.emptyError(string(body))
+	}
+	usage, _ := parsed.usage()
+	return &LLMResponse{Text: parsed.text(), Provider: gp.Name(), Model: gp.Model(), Usage: usage}, nil
+}
+
+// GenerateStream calls streamGenerateContent with Server-Sent Events.
+func (gp *GeminiProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	url, err := gp.endpoint("streamGenerateContent")
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	var usage TokenUsage
+	var last geminiResponse
+	err = postStream(ctx, gp.Client, url+"&alt=sse", nil, gp.request(req), func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode LLM stream: %w", err)
+		}
+		if u, ok := chunk.usage(); ok {
+			usage = u
+		}
+		last = chunk
+		if text := chunk.text(); text != "" {
+			sb.WriteString(text)
+			onChunk(text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, last.emptyError("(stream)")
+	}
+	return &LLMResponse{Text: sb.String(), Provider: gp.Name(), Model: gp.Model(), Usage: usage}, nil
+}
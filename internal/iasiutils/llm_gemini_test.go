@@ -0,0 +1,61 @@
+package iasiutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGeminiEndpoint(t *testing.T) {
+	gp := &GeminiProvider{APIKey: "k"}
+	url, err := gp.endpoint("generateContent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://generativelanguage.googleapis.com/v1beta/models/" + defaultGeminiModel + ":generateContent?key=k"; url != want {
+		t.Errorf("endpoint = %s, want %s", url, want)
+	}
+	var llmErr *LLMError
+	if _, err := (&GeminiProvider{}).endpoint("generateContent"); !errors.As(err, &llmErr) || llmErr.Kind != LLMInvalidKey {
+		t.Errorf("endpoint without a key: %v, want an invalid key error", err)
+	}
+}
+
+func TestGeminiRequest(t *testing.T) {
+	gp := &GeminiProvider{APIKey: "k"}
+	schema := OutputSchema{MinHints: 2, MaxHints: 4}
+	req := gp.request(LLMRequest{
+		SystemPrompt: "system",
+		History:      []ChatMessage{{Role: ChatRoleUser, Content: "q1"}, {Role: ChatRoleAssistant, Content: "a1"}},
+		Prompt:       "q2",
+		Schema:       &schema,
+	})
+	var roles, texts []string
+	for _, c := range req.Contents {
+		roles = append(roles, c.Role)
+		for _, p := range c.Parts {
+			texts = append(texts, p.Text)
+		}
+	}
+	if got := strings.Join(roles, ","); got != "user,model,user" {
+		t.Errorf("roles = %s, want user,model,user", got)
+	}
+	if got := strings.Join(texts, ","); got != "q1,a1,q2" {
+		t.Errorf("parts = %s, want the turns only", got)
+	}
+	if si := req.SystemInstruction; si == nil || len(si.Parts) != 1 || si.Parts[0].Text != "system" || si.Role != "" {
+		t.Errorf("system instruction = %+v, want the system prompt", si)
+	}
+	cfg := req.GenerationConfig
+	if cfg == nil || cfg.ResponseMimeType != "application/json" || cfg.ResponseSchema["type"] != "OBJECT" {
+		t.Fatalf("generation config = %+v, want a JSON response schema", cfg)
+	}
+	hints := cfg.ResponseSchema["properties"].(map[string]interface{})["hints"].(map[string]interface{})
+	if hints["type"] != "ARRAY" || hints["minItems"] != 2 || hints["maxItems"] != 4 {
+		t.Errorf("hints schema = %v", hints)
+	}
+
+	if req := gp.request(LLMRequest{Prompt: "hint"}); req.GenerationConfig != nil || req.SystemInstruction != nil {
+		t.Errorf("a request without a schema or a system prompt sent %+v, %+v", req.GenerationConfig, req.SystemInstruction)
+	}
+}
@@ -0,0 +1,109 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider calls a local Ollama server through its /api/chat endpoint.
+type OllamaProvider struct {
+	ModelName string
+	BaseURL   string
+	Client    *http.Client
+}
+
+const (
+	defaultOllamaModel   = "llama3.1"
+	defaultOllamaBaseURL = "http://localhost:11434"
+)
+
+func (op *OllamaProvider) Name() string { return ProviderOllama }
+
+func (op *OllamaProvider) Model() string {
+	if op.ModelName != "" {
+		return op.ModelName
+	}
+	return defaultOllamaModel
+}
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	// Format constrains the answer to a JSON schema.
+	Format map[string]interface{} `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message chatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error"`
+	// The token counts are set on the final response.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (r *ollamaResponse) usage() TokenUsage {
+	return TokenUsage{PromptTokens: r.PromptEvalCount, OutputTokens: r.EvalCount, TotalTokens: r.PromptEvalCount + r.EvalCount}
+}
+
+// request builds the /api/chat URL and payload.
+func (op *OllamaProvider) request(req LLMRequest, stream bool) (string, ollamaRequest) {
+	base := op.BaseURL
+	if base == "" {
+		base = defaultOllamaBaseURL
+	}
+	payload := ollamaRequest{Model: op.Model(), Messages: chatMessages(req), Stream: stream}
+	if req.Schema != nil {
+		payload.Format = req.Schema.JSONSchema()
+	}
+	return strings.TrimSuffix(base, "/") + "/api/chat", payload
+}
+
+// Generate sends a non-streaming chat request to {BaseURL}/api/chat.
+func (op *OllamaProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	url, payload := op.request(req, false)
+	var parsed ollamaResponse
+	body, err := postJSON(ctx, op.Client, url, nil, payload, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Message.Content == "" {
+		return nil, &LLMError{Kind: LLMEmptyResponse, Message: "Empty LLM response. Raw response: " + TruncateString(string(body), 1000)}
+	}
+	return &LLMResponse{Text: parsed.Message.Content, Provider: op.Name(), Model: op.Model(), Usage: parsed.usage()}, nil
+}
+
+// GenerateStream sends a streaming chat request, which Ollama answers with one JSON object per line.
+func (op *OllamaProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	url, payload := op.request(req, true)
+	var sb strings.Builder
+	var usage TokenUsage
+	err := postStream(ctx, op.Client, url, nil, payload, func(line []byte) error {
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to decode LLM stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("LLM stream error: %s", chunk.Error)
+		}
+		if chunk.Done {
+			usage = chunk.usage()
+		}
+		if chunk.Message.Content != "" {
+			sb.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sb.Len() == 0 {
+		return nil, &LLMError{Kind: LLMEmptyResponse, Message: "Empty LLM response stream"}
+	}
+	return &LLMResponse{Text: sb.String(), Provider: op.Name(), Model: op.Model(), Usage: usage}, nil
+}
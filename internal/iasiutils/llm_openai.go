@@ -0,0 +1,167 @@
+package iasiutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider calls an OpenAI-compatible chat completions API, which also covers
+// self-hosted servers such as vLLM, LM Studio or llama.cpp through BaseURL.
+type OpenAIProvider struct {
+	APIKey    string
+	ModelName string
+	BaseURL   string
+	Client    *http.Client
+}
+
+const (
+	defaultOpenAIModel   = "gpt-4o-mini"
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+)
+
+func (op *OpenAIProvider) Name() string { return ProviderOpenAI }
+
+func (op *OpenAIProvider) Model() string {
+	if op.ModelName != "" {
+		return op.ModelName
+	}
+	return defaultOpenAIModel
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []chatMessage          `json:"messages"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	Stream         bool                   `json:"stream,omitempty"`
+	StreamOptions  map[string]interface{} `json:"stream_options,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		// Delta is set instead of Message on streamed chunks.
+		Delta        chatMessage `json:"delta"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	// Usage is sent in the last streamed chunk when asked for with stream_options.
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (r *openAIResponse) usage() (TokenUsage, bool) {
+	if r.Usage == nil {
+		return TokenUsage{}, false
+	}
+	return TokenUsage{PromptTokens: r.Usage.PromptTokens, OutputTokens: r.Usage.CompletionTokens, TotalTokens: r.Usage.TotalTokens}, true
+}
+
+// chatMessages turns a request into system, history and user chat messages.
+func chatMessages(req LLMRequest) []chatMessage {
+	var messages []chatMessage
+	if strings.TrimSpace(req.SystemPrompt) != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	for _, m := range req.History {
+		messages = append(messages, chatMessage{Role: m.Role, Content: m.Content})
+	}
+	return append(messages, chatMessage{Role: "user", Content: req.Prompt})
+}
+
+// request builds the chat completions payload and its headers.
+func (op *OpenAIProvider) request(req LLMRequest) (url string, headers map[string]string, payload openAIRequest, err error) {
+	base := op.BaseURL
+	if base == "" {
+		base = defaultOpenAIBaseURL
+	}
+	if op.APIKey == "" && base == defaultOpenAIBaseURL {
+		return "", nil, payload, &LLMError{Kind: LLMInvalidKey, Message: "OPENAI_API_KEY not set"}
+	}
+	headers = map[string]string{}
+	if op.APIKey != "" {
+		headers["Authorization"] = "Bearer " + op.APIKey
+	}
+	payload = openAIRequest{Model: op.Model(), Messages: chatMessages(req)}
+	if req.Schema != nil {
+		payload.ResponseFormat = map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "editorial", "schema": req.Schema.JSONSchema()},
+		}
+	}
+	return strings.TrimSuffix(base, "/") + "/chat/completions", headers, payload, nil
+}
+
+// Generate sends the request to {BaseURL}/chat/completions.
+func (op *OpenAIProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	url, headers, payload, err := op.request(req)
+	if err != nil {
+		return nil, err
+	}
+	var parsed openAIResponse
+	body, err := postJSON(ctx, op.Client, url, headers, payload, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Choices) > 0 && parsed.Choices[0].FinishReason == "content_filter" {
+		return nil, &LLMError{Kind: LLMSafetyBlocked, Message: "blocked by the content filter"}
+	}
+	if len(parsed.Choices) == 0 || parsed.Choices[0].Message.Content == "" {
+		return nil, &LLMError{Kind: LLMEmptyResponse, Message: "No LLM response choices. Raw response: " + TruncateString(string(body), 1000)}
+	}
+	usage, _ := parsed.usage()
+	return &LLMResponse{Text: parsed.Choices[0].Message.Content, Provider: op.Name(), Model: op.Model(), Usage: usage}, nil
+}
+
+// GenerateStream sends the request with "stream": true and reads the Server-Sent Events deltas.
+func (op *OpenAIProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	url, headers, payload, err := op.request(req)
+	if err != nil {
+		return nil, err
+	}
+	payload.Stream = true
+	payload.StreamOptions = map[string]interface{}{"include_usage": true}
+	var sb strings.Builder
+	var usage TokenUsage
+	filtered := false
+	err = postStream(ctx, op.Client, url, headers, payload, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok || string(data) == "[DONE]" {
+			return nil
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode LLM stream: %w", err)
+		}
+		if u, ok := chunk.usage(); ok {
+			usage = u
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason == "content_filter" {
+			filtered = true
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			sb.WriteString(chunk.Choices[0].Delta.Content)
+			onChunk(chunk.Choices[0].Delta.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filtered {
+		return nil, &LLMError{Kind: LLMSafetyBlocked, Message: "blocked by the content filter"}
+	}
+	if sb.Len() == 0 {
+		return nil, &LLMError{Kind: LLMEmptyResponse, Message: "No LLM response choices in stream"}
+	}
+	return &LLMResponse{Text: sb.String(), Provider: op.Name(), Model: op.Model(), Usage: usage}, nil
+}
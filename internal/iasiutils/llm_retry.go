@@ -0,0 +1,149 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// RetryProvider retries the transient failures of Provider (rate limits, overload and unreachable
+// backends) with exponential backoff and jitter, honoring the delay the backend asks for. A stream is
+// only retried if it failed before its first chunk, so callers never see an answer twice.
+type RetryProvider struct {
+	Provider    LLMProvider
+	MaxRetries  int
+	BaseBackoff time.Duration
+	// MaxBackoff caps the backoff; a backend asking to wait longer fails the call at once instead.
+	MaxBackoff time.Duration
+}
+
+// NewRetryProvider wraps p with the default retry policy.
+func NewRetryProvider(p LLMProvider, maxRetries int) *RetryProvider {
+	return &RetryProvider{Provider: p, MaxRetries: maxRetries, BaseBackoff: time.Second, MaxBackoff: 30 * time.Second}
+}
+
+func (rp *RetryProvider) Name() string  { return rp.Provider.Name() }
+func (rp *RetryProvider) Model() string { return rp.Provider.Model() }
+
+// Generate calls the provider until it succeeds, fails for good or runs out of retries.
+func (rp *RetryProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return rp.retry(ctx, func() (*LLMResponse, bool, error) {
+		resp, err := rp.Provider.Generate(ctx, req)
+		return resp, true, err
+	})
+}
+
+// GenerateStream is Generate for streamed answers.
+func (rp *RetryProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	return rp.retry(ctx, func() (*LLMResponse, bool, error) {
+		streamed := false
+		resp, err := rp.Provider.GenerateStream(ctx, req, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		return resp, !streamed, err
+	})
+}
+
+// retry runs call until it succeeds or returns an error that is not worth retrying. call reports
+// whether it may be retried after a failure.
+func (rp *RetryProvider) retry(ctx context.Context, call func() (*LLMResponse, bool, error)) (*LLMResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, retryable, err := call()
+		if err == nil {
+			return resp, nil
+		}
+		err = withSource(err, rp.Name(), rp.Model())
+		var le *LLMError
+		if !retryable || attempt > rp.MaxRetries || !errors.As(err, &le) || !le.Kind.Transient() {
+			return nil, err
+		}
+		delay := rp.backoff(attempt)
+		if le.RetryAfter > 0 {
+			if rp.MaxBackoff > 0 && le.RetryAfter > rp.MaxBackoff {
+				return nil, err
+			}
+			delay = le.RetryAfter
+		}
+		log.Printf("[WARN] LLM call failed (%v), retrying in %s (attempt %d/%d)", err, delay.Round(time.Millisecond), attempt, rp.MaxRetries)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// backoff returns the delay before the given retry: exponential in attempt, capped at MaxBackoff,
+// with the upper half randomized.
+func (rp *RetryProvider) backoff(attempt int) time.Duration {
+	d := rp.BaseBackoff << uint(attempt-1)
+	if d <= 0 || (rp.MaxBackoff > 0 && d > rp.MaxBackoff) {
+		d = rp.MaxBackoff
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// FallbackProvider tries its Providers in order, moving to the next one when a call fails with an
+// error kind that FallsBack; any other failure is returned as it is. A stream only falls back if it
+// failed before its first chunk. Name and Model are the first provider's; the response names the
+// provider that answered.
+type FallbackProvider struct {
+	Providers []LLMProvider
+}
+
+// NewFallbackProvider returns a provider trying primary, then each of fallbacks.
+func NewFallbackProvider(primary LLMProvider, fallbacks ...LLMProvider) *FallbackProvider {
+	return &FallbackProvider{Providers: append([]LLMProvider{primary}, fallbacks...)}
+}
+
+func (fp *FallbackProvider) Name() string  { return fp.Providers[0].Name() }
+func (fp *FallbackProvider) Model() string { return fp.Providers[0].Model() }
+
+// Generate returns the first successful answer, or the error of the last provider tried.
+func (fp *FallbackProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	return fp.each(ctx, func(p LLMProvider) (*LLMResponse, bool, error) {
+		resp, err := p.Generate(ctx, req)
+		return resp, true, err
+	})
+}
+
+// GenerateStream is Generate for streamed answers.
+func (fp *FallbackProvider) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	streamed := false
+	return fp.each(ctx, func(p LLMProvider) (*LLMResponse, bool, error) {
+		resp, err := p.GenerateStream(ctx, req, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		return resp, !streamed, err
+	})
+}
+
+func (fp *FallbackProvider) each(ctx context.Context, call func(LLMProvider) (*LLMResponse, bool, error)) (*LLMResponse, error) {
+	var err error
+	for i, p := range fp.Providers {
+		var resp *LLMResponse
+		var canFallBack bool
+		resp, canFallBack, err = call(p)
+		if err == nil {
+			return resp, nil
+		}
+		err = withSource(err, p.Name(), p.Model())
+		var le *LLMError
+		if !canFallBack || ctx.Err() != nil || !errors.As(err, &le) || !le.Kind.FallsBack() {
+			return nil, err
+		}
+		if i+1 < len(fp.Providers) {
+			next := fp.Providers[i+1]
+			log.Printf("[WARN] LLM %s (%s) failed (%v), falling back to %s (%s)", p.Name(), p.Model(), err, next.Name(), next.Model())
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,161 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkThenFail streams one chunk and then fails, like a connection dropped mid-answer.
+type chunkThenFail struct {
+	FakeProvider
+}
+
+func (cf *chunkThenFail) GenerateStream(ctx context.Context, req LLMRequest, onChunk func(string)) (*LLMResponse, error) {
+	cf.Generate(ctx, req)
+	onChunk("partial ")
+	return nil, &LLMError{Kind: LLMUnavailable, Message: "connection reset"}
+}
+
+func fastRetry(p LLMProvider, maxRetries int) *RetryProvider {
+	rp := NewRetryProvider(p, maxRetries)
+	rp.BaseBackoff = time.Millisecond
+	return rp
+}
+
+func TestRetryProvider(t *testing.T) {
+	overloaded := &LLMError{Kind: LLMOverloaded, Status: 503}
+	tests := []struct {
+		name  string
+		steps []FakeStep
+		calls int
+		err   LLMErrorKind
+	}{
+		{"transient then success", []FakeStep{{Err: overloaded}, {Err: &LLMError{Kind: LLMRateLimited, RetryAfter: time.Millisecond}}, {Text: "ok"}}, 3, ""},
+		{"out of retries", []FakeStep{{Err: overloaded}}, 3, LLMOverloaded},
+		{"permanent failure", []FakeStep{{Err: &LLMError{Kind: LLMInvalidKey}}, {Text: "ok"}}, 1, LLMInvalidKey},
+		{"asked to wait too long", []FakeStep{{Err: &LLMError{Kind: LLMRateLimited, RetryAfter: time.Hour}}, {Text: "ok"}}, 1, LLMRateLimited},
+		{"unclassified error", []FakeStep{{Err: errors.New("boom")}, {Text: "ok"}}, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeProvider(tt.steps...)
+			resp, err := fastRetry(fake, 2).Generate(context.Background(), LLMRequest{Prompt: "p"})
+			if n := len(fake.Calls()); n != tt.calls {
+				t.Errorf("got %d calls, want %d", n, tt.calls)
+			}
+			var le *LLMError
+			switch {
+			case tt.err != "":
+				if !errors.As(err, &le) || le.Kind != tt.err || le.Provider != "fake" {
+					t.Errorf("error = %v, want %s from the fake provider", err, tt.err)
+				}
+			case strings.HasPrefix(tt.name, "unclassified"):
+				if err == nil {
+					t.Error("an unclassified error was swallowed")
+				}
+			case err != nil || resp.Text != "ok":
+				t.Errorf("Generate() = %+v, %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestRetryProviderStream(t *testing.T) {
+	fake := NewFakeProvider(FakeStep{Err: &LLMError{Kind: LLMOverloaded}}, FakeStep{Text: "two words"})
+	var chunks []string
+	resp, err := fastRetry(fake, 2).GenerateStream(context.Background(), LLMRequest{}, func(c string) { chunks = append(chunks, c) })
+	if err != nil || resp.Text != "two words" || strings.Join(chunks, "") != "two words" || len(fake.Calls()) != 2 {
+		t.Fatalf("stream failing before its first chunk: %v, chunks %q after %d calls", err, chunks, len(fake.Calls()))
+	}
+
+	// Once a chunk was passed on, retrying would repeat it.
+	dropped := &chunkThenFail{}
+	chunks = nil
+	_, err = fastRetry(dropped, 2).GenerateStream(context.Background(), LLMRequest{}, func(c string) { chunks = append(chunks, c) })
+	if err == nil || len(dropped.Calls()) != 1 || len(chunks) != 1 {
+		t.Fatalf("stream failing mid-answer: %v, chunks %q after %d calls; want no retry", err, chunks, len(dropped.Calls()))
+	}
+}
+
+func TestRetryProviderCanceled(t *testing.T) {
+	fake := NewFakeProvider(FakeStep{Err: &LLMError{Kind: LLMOverloaded}})
+	rp := NewRetryProvider(fake, 5)
+	rp.BaseBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := rp.Generate(ctx, LLMRequest{}); !errors.Is(err, context.DeadlineExceeded) || len(fake.Calls()) != 1 {
+		t.Fatalf("Generate() = %v after %d calls, want the context error while waiting", err, len(fake.Calls()))
+	}
+}
+
+func TestRetryProviderBackoff(t *testing.T) {
+	rp := &RetryProvider{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 5: time.Second, 40: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := rp.backoff(attempt); d < max/2 || d >= max {
+				t.Fatalf("backoff(%d) = %s, want in [%s, %s)", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestFallbackProvider(t *testing.T) {
+	primary := NewFakeProvider(FakeStep{Err: &LLMError{Kind: LLMOverloaded}}, FakeStep{Err: &LLMError{Kind: LLMEmptyResponse}})
+	primary.ModelName = "primary"
+	backup := NewFakeProvider(FakeStep{Text: "ok"})
+	backup.ModelName = "backup"
+	fp := NewFallbackProvider(primary, backup)
+	if fp.Name() != "fake" || fp.Model() != "primary" {
+		t.Errorf("fallback provider is %s/%s, want the primary's name", fp.Name(), fp.Model())
+	}
+	for i := 1; i <= 2; i++ {
+		resp, err := fp.Generate(context.Background(), LLMRequest{})
+		if err != nil || resp.Model != "backup" || len(primary.Calls()) != i || len(backup.Calls()) != i {
+			t.Fatalf("Generate() #%d = %+v, %v; want the backup's answer", i, resp, err)
+		}
+	}
+
+	// Failures another backend would not fix are returned with their cause.
+	for _, kind := range []LLMErrorKind{LLMInvalidKey, LLMBadRequest, LLMSafetyBlocked} {
+		failing := NewFakeProvider(FakeStep{Err: &LLMError{Kind: kind}})
+		unused := NewFakeProvider(FakeStep{Text: "ok"})
+		_, err := NewFallbackProvider(failing, unused).Generate(context.Background(), LLMRequest{})
+		var le *LLMError
+		if !errors.As(err, &le) || le.Kind != kind || le.Provider != "fake" || len(unused.Calls()) != 0 {
+			t.Errorf("primary failing with %s: %v, fallback called %d times; want the primary's error", kind, err, len(unused.Calls()))
+		}
+	}
+	unused := NewFakeProvider(FakeStep{Text: "ok"})
+	if _, err := NewFallbackProvider(NewFakeProvider(FakeStep{Err: errors.New("boom")}), unused).Generate(context.Background(), LLMRequest{}); err == nil || len(unused.Calls()) != 0 {
+		t.Errorf("unclassified error: %v, fallback called %d times; want no fallback", err, len(unused.Calls()))
+	}
+
+	// All failing returns the last error, naming the provider that made it.
+	failing := NewFakeProvider(FakeStep{Err: &LLMError{Kind: LLMOverloaded}})
+	failing.ModelName = "last"
+	_, err := NewFallbackProvider(NewFakeProvider(FakeStep{Err: &LLMError{Kind: LLMRateLimited}}), failing).Generate(context.Background(), LLMRequest{})
+	var le *LLMError
+	if !errors.As(err, &le) || le.Kind != LLMOverloaded || le.Model != "last" {
+		t.Errorf("all providers failing: %v, want the last provider's error", err)
+	}
+}
+
+func TestFallbackProviderStream(t *testing.T) {
+	dropped := &chunkThenFail{}
+	backup := NewFakeProvider(FakeStep{Text: "ok"})
+	var chunks []string
+	_, err := NewFallbackProvider(dropped, backup).GenerateStream(context.Background(), LLMRequest{}, func(c string) { chunks = append(chunks, c) })
+	if err == nil || len(backup.Calls()) != 0 || strings.Join(chunks, "") != "partial " {
+		t.Fatalf("stream failing mid-answer: %v, chunks %q; want no fallback", err, chunks)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := NewFakeProvider(FakeStep{Text: "late", Delay: time.Second})
+	if _, err := NewFallbackProvider(canceled, backup).GenerateStream(ctx, LLMRequest{}, func(string) {}); !errors.Is(err, context.Canceled) || len(backup.Calls()) != 0 {
+		t.Fatalf("canceled stream: %v, backup called %d times; want no fallback", err, len(backup.Calls()))
+	}
+}
@@ -0,0 +1,89 @@
+package iasiutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStreamServer streams the given lines, waiting gap before each one.
+func newStreamServer(t *testing.T, gap time.Duration, lines ...string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for _, line := range lines {
+			select {
+			case <-time.After(gap):
+			case <-r.Context().Done():
+				return
+			}
+			fmt.Fprintf(w, "%s\n\n", line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collectStream(ctx context.Context, client *http.Client, url string) (string, error) {
+	var sb strings.Builder
+	err := postStream(ctx, client, url, nil, map[string]string{}, func(line []byte) error {
+		sb.Write(line)
+		return nil
+	})
+	return sb.String(), err
+}
+
+func TestPostStreamOutlivesClientTimeout(t *testing.T) {
+	srv := newStreamServer(t, 60*time.Millisecond, "a", "b", "c", "d", "e")
+	client := &http.Client{Timeout: 150 * time.Millisecond}
+	start := time.Now()
+	got, err := collectStream(context.Background(), client, srv.URL)
+	if err != nil {
+		t.Fatalf("stream of %s failed: %v", time.Since(start), err)
+	}
+	if got != "abcde" {
+		t.Errorf("stream = %q, want every line", got)
+	}
+	if time.Since(start) < client.Timeout {
+		t.Errorf("stream took %s, want longer than the client timeout for the test to mean anything", time.Since(start))
+	}
+}
+
+func TestPostStreamStalls(t *testing.T) {
+	srv := newStreamServer(t, 300*time.Millisecond, "a")
+	_, err := collectStream(context.Background(), &http.Client{Timeout: 50 * time.Millisecond}, srv.URL)
+	var llmErr *LLMError
+	if !errors.As(err, &llmErr) || llmErr.Kind != LLMUnavailable || !strings.Contains(err.Error(), "stalled") {
+		t.Fatalf("stalled stream error = %v, want an unavailable backend", err)
+	}
+}
+
+func TestPostStreamCanceled(t *testing.T) {
+	srv := newStreamServer(t, 200*time.Millisecond, "a", "b")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := collectStream(ctx, &http.Client{}, srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("canceled stream error = %v, want the context error", err)
+	}
+}
+
+func TestNewLLMProviderTimeouts(t *testing.T) {
+	t.Setenv("IASI_LLM_TIMEOUT", "5s")
+	llm, err := NewLLMProvider(ProviderGemini, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := llm.(*GeminiProvider).Client
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || client.Timeout != 5*time.Second || transport.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("client timeout %s, transport %T, want 5s overall and for the response headers", client.Timeout, client.Transport)
+	}
+}
@@ -0,0 +1,359 @@
+package iasiutils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// infoarenaBaseURL is prepended to relative links and images in converted statements.
+const infoarenaBaseURL = "https://www.infoarena.ro"
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// HTMLToMarkdown converts an Infoarena statement fragment to Markdown. Sub/superscripts and
+// LaTeX images become inline math ($...$), tables become GFM tables, example tables and
+// <pre> blocks become fenced code blocks.
+func HTMLToMarkdown(sel *goquery.Selection) string {
+	mc := &markdownConverter{}
+	for _, n := range sel.Nodes {
+		mc.block(n)
+	}
+	out := blankLinesRe.ReplaceAllString(mc.sb.String(), "\n\n")
+	return strings.TrimSpace(out)
+}
+
+type markdownConverter struct {
+	sb strings.Builder
+	// listDepth is the nesting level of the list being rendered.
+	listDepth int
+}
+
+// block renders n and its children, separating block-level elements with blank lines.
+func (mc *markdownConverter) block(n *html.Node) {
+	if n.Type == html.TextNode {
+		text := collapseSpaces(n.Data)
+		if s := mc.sb.String(); s == "" || strings.HasSuffix(s, "\n") {
+			text = strings.TrimLeft(text, " ")
+		}
+		mc.sb.WriteString(text)
+		return
+	}
+	if n.Type != html.ElementNode && n.Type != html.DocumentNode {
+		return
+	}
+	switch n.Data {
+	case "script", "style":
+		return
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		mc.paragraphBreak()
+		mc.sb.WriteString(strings.Repeat("#", level) + " " + strings.TrimSpace(inlineMarkdown(n)))
+		mc.paragraphBreak()
+	case "p", "div":
+		mc.paragraphBreak()
+		mc.children(n)
+		mc.paragraphBreak()
+	case "pre":
+		mc.paragraphBreak()
+		mc.fence(nodeText(n))
+		mc.paragraphBreak()
+	case "ul", "ol":
+		mc.list(n)
+	case "table":
+		mc.paragraphBreak()
+		if hasClass(n, "example") {
+			mc.exampleTable(n)
+		} else {
+			mc.table(n)
+		}
+		mc.paragraphBreak()
+	case "blockquote":
+		mc.paragraphBreak()
+		inner := &markdownConverter{}
+		inner.children(n)
+		for _, line := range strings.Split(strings.TrimSpace(inner.sb.String()), "\n") {
+			mc.sb.WriteString("> " + line + "\n")
+		}
+		mc.paragraphBreak()
+	case "hr":
+		mc.paragraphBreak()
+		mc.sb.WriteString("---")
+		mc.paragraphBreak()
+	default:
+		mc.sb.WriteString(inlineElement(n))
+	}
+}
+
+func (mc *markdownConverter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		mc.block(c)
+	}
+}
+
+func (mc *markdownConverter) paragraphBreak() {
+	s := mc.sb.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		mc.sb.WriteString("\n")
+		return
+	}
+	mc.sb.WriteString("\n\n")
+}
+
+func (mc *markdownConverter) fence(code string) {
+	code = strings.Trim(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	mc.sb.WriteString("```\n" + code + "\n```")
+}
+
+func (mc *markdownConverter) list(n *html.Node) {
+	if mc.listDepth == 0 {
+		mc.paragraphBreak()
+	}
+	mc.listDepth++
+	indent := strings.Repeat("  ", mc.listDepth-1)
+	i := 0
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.Data != "li" {
+			continue
+		}
+		i++
+		marker := "- "
+		if n.Data == "ol" {
+			marker = strconv.Itoa(i) + ". "
+		}
+		var text strings.Builder
+		var nested []*html.Node
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.Data == "ul" || c.Data == "ol") {
+				nested = append(nested, c)
+				continue
+			}
+			text.WriteString(inlineNode(c))
+		}
+		mc.sb.WriteString(indent + marker + strings.TrimSpace(text.String()) + "\n")
+		for _, c := range nested {
+			mc.list(c)
+		}
+	}
+	mc.listDepth--
+	if mc.listDepth == 0 {
+		mc.sb.WriteString("\n")
+	}
+}
+
+// table renders a GFM table; the first row is used as the header.
+func (mc *markdownConverter) table(n *html.Node) {
+	var rows [][]string
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.Data == "tr" {
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+					row = append(row, tableCell(cell))
+				}
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+			return false
+		}
+		return true
+	})
+	if len(rows) == 0 {
+		return
+	}
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	writeRow := func(row []string) {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		mc.sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	writeRow(rows[0])
+	sep := make([]string, width)
+	for i := range sep {
+		sep[i] = "---"
+	}
+	writeRow(sep)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+}
+
+// exampleTable renders each sample as fenced blocks labelled with the file names from the header row.
+func (mc *markdownConverter) exampleTable(n *html.Node) {
+	var header []string
+	first := true
+	walk(n, func(c *html.Node) bool {
+		if c.Type != html.ElementNode || c.Data != "tr" {
+			return true
+		}
+		var cells []*html.Node
+		for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+				cells = append(cells, cell)
+			}
+		}
+		if first && (len(cells) > 0 && cells[0].Data == "th") {
+			for _, cell := range cells {
+				header = append(header, strings.TrimSpace(nodeText(cell)))
+			}
+			first = false
+			return false
+		}
+		first = false
+		for i, cell := range cells {
+			label := ""
+			if i < len(header) {
+				label = header[i]
+			}
+			if label != "" {
+				mc.sb.WriteString("**" + label + "**\n\n")
+			}
+			mc.fence(nodeText(cell))
+			mc.sb.WriteString("\n\n")
+		}
+		return false
+	})
+}
+
+func tableCell(n *html.Node) string {
+	s := strings.TrimSpace(inlineMarkdown(n))
+	s = strings.ReplaceAll(s, "|", "\\|")
+	// Line breaks inside a cell become <br>, without the trailing spaces of a Markdown hard break.
+	s = strings.ReplaceAll(s, "  \n", "\n")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// inlineMarkdown renders the children of n as inline Markdown.
+func inlineMarkdown(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(inlineNode(c))
+	}
+	return sb.String()
+}
+
+func inlineNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseSpaces(n.Data)
+	case html.ElementNode:
+		return inlineElement(n)
+	}
+	return ""
+}
+
+func inlineElement(n *html.Node) string {
+	inner := func() string { return inlineMarkdown(n) }
+	switch n.Data {
+	case "script", "style":
+		return ""
+	case "br":
+		return "  \n"
+	case "em", "i":
+		return wrapInline("*", inner())
+	case "strong", "b":
+		return wrapInline("**", inner())
+	case "code", "tt", "kbd":
+		return "`" + strings.TrimSpace(nodeText(n)) + "`"
+	case "sub":
+		return "$_{" + strings.TrimSpace(nodeText(n)) + "}$"
+	case "sup":
+		return "$^{" + strings.TrimSpace(nodeText(n)) + "}$"
+	case "a":
+		href := absoluteURL(attr(n, "href"))
+		text := strings.TrimSpace(inner())
+		if href == "" || text == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		alt := attr(n, "alt")
+		if hasClass(n, "latex") || hasClass(n, "tex") || strings.Contains(attr(n, "src"), "latex") {
+			return "$" + strings.Trim(alt, "$") + "$"
+		}
+		return "![" + alt + "](" + absoluteURL(attr(n, "src")) + ")"
+	case "span":
+		if hasClass(n, "tex") || hasClass(n, "math") || hasClass(n, "latex") {
+			return "$" + strings.Trim(strings.TrimSpace(nodeText(n)), "$") + "$"
+		}
+	}
+	return inner()
+}
+
+// wrapInline wraps text in marker, keeping surrounding spaces outside so the emphasis stays valid.
+func wrapInline(marker, text string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:len(text)-len(strings.TrimLeft(text, " "))]
+	trail := text[len(strings.TrimRight(text, " ")):]
+	return lead + marker + trimmed + marker + trail
+}
+
+var spacesRe = regexp.MustCompile(`\s+`)
+
+func collapseSpaces(s string) string {
+	return spacesRe.ReplaceAllString(s, " ")
+}
+
+// nodeText returns the raw text of n with whitespace preserved.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		} else if c.Type == html.ElementNode && c.Data == "br" {
+			sb.WriteString("\n")
+		}
+		return true
+	})
+	return sb.String()
+}
+
+// walk visits the descendants of n depth-first; fn returns false to skip a node's children.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if fn(c) {
+			walk(c, fn)
+		}
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func absoluteURL(href string) string {
+	if strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") {
+		return infoarenaBaseURL + href
+	}
+	return href
+}
@@ -0,0 +1,86 @@
+package iasiutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{
+			name: "paragraphs and inline formatting",
+			html: `<p>Se dă un <b>şir</b> de <em>N</em> numere.</p><p>Afişaţi <code> suma </code>.</p>`,
+			want: "Se dă un **şir** de *N* numere.\n\nAfişaţi `suma`.",
+		},
+		{
+			name: "emphasis keeps spaces outside the markers",
+			html: `<p>un<i> cuvânt </i>aici</p>`,
+			want: "un *cuvânt* aici",
+		},
+		{
+			name: "headings",
+			html: `<h2>Cerinţă</h2><p>Text.</p><h3> Explicaţie </h3>`,
+			want: "## Cerinţă\n\nText.\n\n### Explicaţie",
+		},
+		{
+			name: "sub and superscripts become inline math",
+			html: `<p>1 ≤ a<sub>i</sub> ≤ 10<sup>9</sup></p>`,
+			want: "1 ≤ a$_{i}$ ≤ 10$^{9}$",
+		},
+		{
+			name: "latex images and spans",
+			html: `<p>Calculaţi <img class="latex" alt="$\sum a_i$" src="/latex/1.png"/> şi <span class="tex">x^2</span>.</p>`,
+			want: "Calculaţi $\\sum a_i$ şi $x^2$.",
+		},
+		{
+			name: "relative links and images",
+			html: `<p><a href="/problema/ciur">ciur</a> <img alt="figura" src="/static/fig.png"/> <a href="https://example.com">extern</a></p>`,
+			want: "[ciur](https://www.infoarena.ro/problema/ciur) ![figura](https://www.infoarena.ro/static/fig.png) [extern](https://example.com)",
+		},
+		{
+			name: "nested lists",
+			html: `<ul><li>unu<ul><li>doi</li></ul></li><li>trei</li></ul><ol><li>a</li><li>b</li></ol>`,
+			want: "- unu\n  - doi\n- trei\n\n1. a\n2. b",
+		},
+		{
+			name: "pre keeps whitespace",
+			html: "<pre>\n3 4\n  1 2\n</pre>",
+			want: "```\n3 4\n  1 2\n```",
+		},
+		{
+			name: "tables escape pipes and pad short rows",
+			html: `<table><tr><th>N</th><th>Punctaj</th></tr><tr><td>a|b</td></tr><tr><td>10</td><td>50<br>puncte</td></tr></table>`,
+			want: "| N | Punctaj |\n| --- | --- |\n| a\\|b |  |\n| 10 | 50<br>puncte |",
+		},
+		{
+			name: "example tables become labelled code blocks",
+			html: `<table class="example"><tr><th>a.in</th><th>a.out</th></tr><tr><td>1 2</td><td>3</td></tr></table>`,
+			want: "**a.in**\n\n```\n1 2\n```\n\n**a.out**\n\n```\n3\n```",
+		},
+		{
+			name: "blockquotes and rules",
+			html: `<blockquote><p>Atenţie!</p></blockquote><hr/><p>Gata.</p>`,
+			want: "> Atenţie!\n\n---\n\nGata.",
+		},
+		{
+			name: "scripts are dropped",
+			html: `<p>Text<script>alert(1)</script></p><style>p {}</style>`,
+			want: "Text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div id="root">` + tt.html + `</div>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := HTMLToMarkdown(doc.Find("#root").Children()); got != tt.want {
+				t.Errorf("HTMLToMarkdown() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package iasiutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DefaultMonitorDir is where synced monitor submissions are persisted, one JSON file per user.
+const DefaultMonitorDir = "data/monitor"
+
+// monitorSnapshotVersion is bumped whenever the stored submission format changes; older
+// snapshots are discarded and re-synced from scratch.
+const monitorSnapshotVersion = 2
+
+// monitorSnapshot is the on-disk format of a user's synced monitor, submissions newest first.
+type monitorSnapshot struct {
+	Version     int          `json:"version"`
+	Username    string       `json:"username"`
+	SyncedAt    time.Time    `json:"synced_at"`
+	Submissions []Submission `json:"submissions"`
+}
+
+// MonitorSync keeps a local copy of a user's monitor and only downloads submissions
+// newer than the ones already stored.
+type MonitorSync struct {
+	Fetcher  Fetcher
+	Dir      string
+	PageSize int
+}
+
+func (ms *MonitorSync) dir() string {
+	if ms.Dir != "" {
+		return ms.Dir
+	}
+	return DefaultMonitorDir
+}
+
+func (ms *MonitorSync) pageSize() int {
+	if ms.PageSize > 0 {
+		return ms.PageSize
+	}
+	return 250
+}
+
+func (ms *MonitorSync) path(username string) string {
+	return filepath.Join(ms.dir(), username+".json")
+}
+
+// Sync walks /monitor newest-first until it reaches a job already stored, persists the merged
+// submissions and returns all of the user's submissions, newest first.
+func (ms *MonitorSync) Sync(username string) ([]Submission, error) {
+	stored, err := ms.Load(username)
+	if err != nil {
+		return nil, err
+	}
+	// Submissions still being evaluated are dropped and fetched again so their final status is
+	// recorded; the walk continues until it passes the oldest of them.
+	var final []Submission
+	var pendingMin int64 = -1
+	for _, sub := range stored {
+		if sub.State.Pending() {
+			if id, err := strconv.ParseInt(sub.JobID, 10, 64); err == nil && (pendingMin < 0 || id < pendingMin) {
+				pendingMin = id
+			}
+			continue
+		}
+		final = append(final, sub)
+	}
+	stored = final
+	known := make(map[string]bool, len(stored))
+	var newest int64 = -1
+	for _, sub := range stored {
+		known[sub.JobID] = true
+		if id, err := strconv.ParseInt(sub.JobID, 10, 64); err == nil && id > newest {
+			newest = id
+		}
+	}
+
+	var fresh []Submission
+	reached := false
+	for offset := 0; !reached; offset += ms.pageSize() {
+		page, err := FetchMonitorPage(ms.Fetcher, username, offset, ms.pageSize())
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range page {
+			id, err := strconv.ParseInt(sub.JobID, 10, 64)
+			if err == nil && pendingMin >= 0 && id >= pendingMin {
+				if !known[sub.JobID] {
+					fresh = append(fresh, sub)
+				}
+				continue
+			}
+			if known[sub.JobID] || (err == nil && newest >= 0 && id <= newest) {
+				reached = true
+				break
+			}
+			fresh = append(fresh, sub)
+		}
+		if len(page) < ms.pageSize() {
+			break // Last page reached
+		}
+	}
+	log.Printf("[INFO] Monitor sync for %s: %d new submissions, %d already stored", username, len(fresh), len(stored))
+
+	subs := append(fresh, stored...)
+	if err := ms.save(username, subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Load returns the submissions stored for username, or nil if the user was never synced.
+func (ms *MonitorSync) Load(username string) ([]Submission, error) {
+	data, err := ioutil.ReadFile(ms.path(username))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap monitorSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse stored monitor %s: %w", ms.path(username), err)
+	}
+	if snap.Version != monitorSnapshotVersion {
+		log.Printf("[INFO] Stored monitor %s has version %d, want %d; re-syncing from scratch", ms.path(username), snap.Version, monitorSnapshotVersion)
+		return nil, nil
+	}
+	for i := range snap.Submissions {
+		// Re-derive the state so parser improvements apply to stored submissions too.
+		snap.Submissions[i].State, snap.Submissions[i].Score = ParseEvaluation(snap.Submissions[i].Status)
+	}
+	return snap.Submissions, nil
+}
+
+func (ms *MonitorSync) save(username string, subs []Submission) error {
+	return writeJSONFile(ms.path(username), monitorSnapshot{Version: monitorSnapshotVersion, Username: username, SyncedAt: time.Now(), Submissions: subs})
+}
+
+// FetchMonitorPage fetches and parses one page of the user's monitor, newest entries first.
+func FetchMonitorPage(fetcher Fetcher, username string, offset, size int) ([]Submission, error) {
+	url := fmt.Sprintf("https://www.infoarena.ro/monitor?user=%s&display_entries=%d&first_entry=%d", username, size, offset)
+	body, err := fetcher.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	subs, err := ParseMonitorPage(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", url, err)
+	}
+	return subs, nil
+}
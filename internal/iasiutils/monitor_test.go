@@ -0,0 +1,113 @@
+package iasiutils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// monitorRow is one submission of a generated monitor page.
+type monitorRow struct {
+	id, problem, status string
+}
+
+// monitorPage renders rows as an Infoarena monitor page.
+func monitorPage(rows ...monitorRow) string {
+	var sb strings.Builder
+	sb.WriteString(`<table class="monitor"><tbody>`)
+	for _, r := range rows {
+		fmt.Fprintf(&sb, `<tr><td><a href="/job_detail/%[1]s">#%[1]s</a></td><td><a href="/utilizator/mentor">Mentor</a></td>`+
+			`<td><a href="/problema/%[2]s">%[2]s</a></td><td><a href="/runda/arhiva">arhiva</a></td><td>0.2 kb</td>`+
+			`<td>12 mar 24 10:15:07</td><td><a href="/job_detail/%[1]s">%[3]s</a></td></tr>`, r.id, r.problem, r.status)
+	}
+	sb.WriteString(`</tbody></table>`)
+	return sb.String()
+}
+
+func monitorURL(offset int) string {
+	return fmt.Sprintf("GET https://www.infoarena.ro/monitor?user=mentor&display_entries=2&first_entry=%d", offset)
+}
+
+func jobIDs(subs []Submission) string {
+	ids := make([]string, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.JobID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestMonitorSync(t *testing.T) {
+	const done = "Evaluare completa: 100 puncte"
+	ms := &MonitorSync{Dir: t.TempDir(), PageSize: 2}
+
+	// The first sync walks every page, until one comes back short.
+	first := &stubFetcher{pages: map[string]string{
+		monitorURL(0): monitorPage(monitorRow{"12", "adunare", done}, monitorRow{"11", "ciur", "In asteptare"}),
+		monitorURL(2): monitorPage(monitorRow{"10", "ciur", "Eroare de compilare"}),
+	}}
+	ms.Fetcher = first
+	subs, err := ms.Sync("mentor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jobIDs(subs); got != "12,11,10" || first.calls != 2 {
+		t.Fatalf("first sync = %s in %d requests, want 12,11,10 in 2", got, first.calls)
+	}
+
+	// The next sync stops at the newest stored job, but walks past the oldest pending one (11) so
+	// its final status replaces the stored one.
+	next := &stubFetcher{pages: map[string]string{
+		monitorURL(0): monitorPage(monitorRow{"14", "adunare", done}, monitorRow{"13", "adunare", "Se evalueaza"}),
+		monitorURL(2): monitorPage(monitorRow{"12", "adunare", done}, monitorRow{"11", "ciur", "Evaluare completa: 30 puncte"}),
+		monitorURL(4): monitorPage(monitorRow{"10", "ciur", "Eroare de compilare"}, monitorRow{"9", "ciur", done}),
+	}}
+	ms.Fetcher = next
+	subs, err = ms.Sync("mentor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jobIDs(subs); got != "14,13,11,12,10" {
+		t.Errorf("incremental sync = %s, want the new jobs, the re-fetched pending job, then the stored ones", got)
+	}
+	if next.calls != 3 {
+		t.Errorf("incremental sync made %d requests, want 3 (stopping at job 10)", next.calls)
+	}
+	for _, sub := range subs {
+		if sub.JobID == "11" && (sub.State != EvalComplete || sub.Score != 30) {
+			t.Errorf("pending job 11 = %v %d after re-sync, want complete with 30 points", sub.State, sub.Score)
+		}
+	}
+
+	// Sync persisted the merge: loading gives the same jobs, and a sync with nothing new only
+	// re-fetches the job still evaluating (13).
+	stored, err := ms.Load("mentor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jobIDs(stored); got != jobIDs(subs) {
+		t.Errorf("Load = %s, want %s", got, jobIDs(subs))
+	}
+	last := &stubFetcher{pages: map[string]string{
+		monitorURL(0): monitorPage(monitorRow{"14", "adunare", done}, monitorRow{"13", "adunare", done}),
+		monitorURL(2): monitorPage(monitorRow{"12", "adunare", done}, monitorRow{"11", "ciur", "Evaluare completa: 30 puncte"}),
+	}}
+	ms.Fetcher = last
+	subs, err = ms.Sync("mentor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 5 || subs[0].JobID != "13" || subs[0].State != EvalComplete {
+		t.Errorf("last sync = %s with %v first, want 5 jobs with 13 completed", jobIDs(subs), subs[0].State)
+	}
+}
+
+func TestMonitorSyncDiscardsOldSnapshots(t *testing.T) {
+	ms := &MonitorSync{Dir: t.TempDir()}
+	if err := writeJSONFile(ms.path("mentor"), monitorSnapshot{Version: monitorSnapshotVersion - 1, Submissions: []Submission{{JobID: "1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := ms.Load("mentor")
+	if err != nil || subs != nil {
+		t.Errorf("Load of an old snapshot = %v, %v; want nothing", subs, err)
+	}
+}
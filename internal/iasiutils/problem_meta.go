@@ -0,0 +1,156 @@
+package iasiutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// DefaultProblemMetaDir is where scraped problem metadata is cached, one JSON file per problem slug.
+const DefaultProblemMetaDir = "data/problems"
+
+// ProblemMeta is the info table shown at the top of an Infoarena problem page.
+type ProblemMeta struct {
+	Slug             string  `json:"slug"`
+	Title            string  `json:"title"`
+	Author           string  `json:"author"`
+	Source           string  `json:"source"`
+	TimeLimitSeconds float64 `json:"time_limit_seconds"`
+	MemoryLimitKB    int     `json:"memory_limit_kb"`
+	// Difficulty is the number of difficulty stars or the rating written on the page, e.g. 2.5; 0 when
+	// the problem is not rated.
+	Difficulty float64   `json:"difficulty"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// ProblemMetaFromStatement returns the metadata of problem slug read from its parsed statement.
+func ProblemMetaFromStatement(slug string, ps *ProblemStatement) *ProblemMeta {
+	return &ProblemMeta{
+		Slug:             slug,
+		Title:            ps.Title,
+		Author:           ps.Author,
+		Source:           ps.Source,
+		TimeLimitSeconds: ps.TimeLimitSeconds,
+		MemoryLimitKB:    ps.MemoryLimitKB,
+		Difficulty:       ps.Difficulty,
+		FetchedAt:        time.Now(),
+	}
+}
+
+// parseDifficulty counts the filled star icons in the difficulty cell, falling back to a number in its
+// text, e.g. "2.5" or "3,5".
+func parseDifficulty(cell *goquery.Selection) float64 {
+	stars := 0
+	cell.Find("img, span, i").Each(func(i int, s *goquery.Selection) {
+		class, _ := s.Attr("class")
+		src, _ := s.Attr("src")
+		marker := strings.ToLower(class + " " + src)
+		if strings.Contains(marker, "star") && !strings.Contains(marker, "empty") && !strings.Contains(marker, "gray") && !strings.Contains(marker, "off") {
+			stars++
+		}
+	})
+	if stars > 0 {
+		return float64(stars)
+	}
+	if m := numberRe.FindString(cell.Text()); m != "" {
+		n, err := strconv.ParseFloat(strings.ReplaceAll(m, ",", "."), 64)
+		if err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// FetchProblemMeta fetches the problem page for slug and parses its metadata.
+func (ii *InfoarenaIngestor) FetchProblemMeta(slug string) (*ProblemMeta, error) {
+	ps, err := ii.FetchProblemStatement("https://www.infoarena.ro/problema/" + slug)
+	if err != nil {
+		return nil, err
+	}
+	return ProblemMetaFromStatement(slug, ps), nil
+}
+
+// ProblemMetaCache serves problem metadata from disk, scraping and storing it on first use.
+// It is safe for concurrent use.
+type ProblemMetaCache struct {
+	Ingestor *InfoarenaIngestor
+	Dir      string
+
+	mu     sync.RWMutex
+	loaded map[string]*ProblemMeta
+}
+
+func (pc *ProblemMetaCache) dir() string {
+	if pc.Dir != "" {
+		return pc.Dir
+	}
+	return DefaultProblemMetaDir
+}
+
+// Cached returns the metadata for slug if it has already been loaded, without scraping.
+func (pc *ProblemMetaCache) Cached(slug string) (*ProblemMeta, bool) {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	meta, ok := pc.loaded[slug]
+	return meta, ok
+}
+
+// Get returns the metadata for slug, reading the disk cache or scraping the problem page.
+func (pc *ProblemMetaCache) Get(slug string) (*ProblemMeta, error) {
+	if slug == "" {
+		return nil, fmt.Errorf("empty problem slug")
+	}
+	if meta, ok := pc.Cached(slug); ok {
+		return meta, nil
+	}
+	path := filepath.Join(pc.dir(), slug+".json")
+	var meta *ProblemMeta
+	if data, err := ioutil.ReadFile(path); err == nil {
+		// Caches written before the limit was stored in seconds hold it in nanoseconds under "time_limit".
+		var cached struct {
+			ProblemMeta
+			LegacyTimeLimit time.Duration `json:"time_limit"`
+		}
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return nil, fmt.Errorf("failed to parse cached metadata %s: %w", path, err)
+		}
+		meta = &cached.ProblemMeta
+		if meta.TimeLimitSeconds == 0 && cached.LegacyTimeLimit > 0 {
+			meta.TimeLimitSeconds = cached.LegacyTimeLimit.Seconds()
+		}
+	} else {
+		fetched, err := pc.Ingestor.FetchProblemMeta(slug)
+		if err != nil {
+			return nil, err
+		}
+		meta = fetched
+		if err := writeJSONFile(path, meta); err != nil {
+			return nil, err
+		}
+	}
+	pc.mu.Lock()
+	if pc.loaded == nil {
+		pc.loaded = make(map[string]*ProblemMeta)
+	}
+	pc.loaded[slug] = meta
+	pc.mu.Unlock()
+	return meta, nil
+}
+
+// Preload loads the metadata for every slug, logging failures instead of stopping.
+func (pc *ProblemMetaCache) Preload(slugs []string) {
+	for _, slug := range slugs {
+		if _, err := pc.Get(slug); err != nil {
+			log.Printf("[WARN] Failed to load metadata for %s: %v", slug, err)
+		}
+	}
+	log.Printf("[INFO] Problem metadata loaded for %d problems", len(slugs))
+}
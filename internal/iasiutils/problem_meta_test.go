@@ -0,0 +1,82 @@
+package iasiutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestProblemMetaCache(t *testing.T) {
+	dir := t.TempDir()
+	pc := &ProblemMetaCache{Ingestor: &InfoarenaIngestor{Fetcher: &ReplayFetcher{Dir: fixturesDir}}, Dir: dir}
+	meta, err := pc.Get("adunare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProblemMeta{Slug: "adunare", Title: "Adunare", Author: "Ion Popescu", Source: "ONI 2002, clasa a 5-a",
+		TimeLimitSeconds: 0.1, MemoryLimitKB: 65536, Difficulty: 2}
+	got := *meta
+	got.FetchedAt = want.FetchedAt
+	if got != want {
+		t.Errorf("meta =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// A new cache serves the file written by the first one without scraping.
+	pc = &ProblemMetaCache{Ingestor: &InfoarenaIngestor{Fetcher: &stubFetcher{}}, Dir: dir}
+	if meta, err := pc.Get("adunare"); err != nil || meta.TimeLimitSeconds != 0.1 {
+		t.Errorf("cached meta = %+v, %v", meta, err)
+	}
+	if _, ok := pc.Cached("ciur"); ok {
+		t.Error("Cached reported a problem never loaded")
+	}
+	if _, err := pc.Get("ciur"); err == nil {
+		t.Error("Get of a problem missing from the cache and the fetcher succeeded")
+	}
+}
+
+func TestProblemMetaCacheLegacyTimeLimit(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"slug": "ciur", "title": "Ciur", "time_limit": 200000000, "memory_limit_kb": 16384}`
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ciur.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pc := &ProblemMetaCache{Ingestor: &InfoarenaIngestor{Fetcher: &stubFetcher{}}, Dir: dir}
+	meta, err := pc.Get("ciur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TimeLimitSeconds != 0.2 || meta.MemoryLimitKB != 16384 {
+		t.Errorf("legacy meta limits = %g s, %d KB; want 0.2 s, 16384 KB", meta.TimeLimitSeconds, meta.MemoryLimitKB)
+	}
+}
+
+func TestParseDifficulty(t *testing.T) {
+	tests := []struct {
+		cell string
+		want float64
+	}{
+		{`<img src="/static/images/star.png"><img src="/static/images/star.png"><img src="/static/images/star-empty.png">`, 2},
+		{`<span class="star"></span><span class="star off"></span>`, 1},
+		{"4", 4},
+		{"2.5 / 5", 2.5},
+		{"3,5", 3.5},
+		{"nerecenzată", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader("<table><tr><td>" + tt.cell + "</td></tr></table>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseDifficulty(doc.Find("td")); got != tt.want {
+			t.Errorf("parseDifficulty(%q) = %g, want %g", tt.cell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package iasiutils
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultPromptTokenBudget bounds the estimated size of a prompt when Recipe.TokenBudget is zero.
+const DefaultPromptTokenBudget = 12000
+
+// ErrPromptTooLong is returned by BuildLLMPrompt when the prompt cannot be shrunk to the token budget.
+var ErrPromptTooLong = errors.New("prompt exceeds the token budget")
+
+// EstimateTokens approximates the number of tokens of text, counting about four characters per token.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// truncateToTokens keeps the whole lines of text that fit in about tokens tokens, marking the cut.
+func truncateToTokens(text string, tokens int) string {
+	if EstimateTokens(text) <= tokens {
+		return text
+	}
+	const marker = "\n... (truncated)"
+	limit := tokens*4 - len(marker)
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if utf8.RuneCountInString(sb.String())+utf8.RuneCountInString(line) > limit {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimRight(sb.String(), "\n") + marker
+}
+
+// StripComments removes the comments and blank lines of code written in lang, leaving string literals
+// and Pascal compiler directives alone. Code in an unknown language only loses its blank lines.
+func StripComments(code string, lang Language) string {
+	switch lang {
+	case LangC, LangCpp, LangJava:
+		code = stripComments(code, []string{"//"}, [][2]string{{"/*", "*/"}}, `"'`, `\`)
+	case LangPython:
+		code = stripComments(code, []string{"#"}, nil, `"'`, `\`)
+	case LangPascal:
+		code = stripComments(code, []string{"//"}, [][2]string{{"(*", "*)"}, {"{", "}"}}, `'`, "")
+	}
+	var lines []string
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripComments drops line comments up to the end of the line and block comments, skipping over
+// string literals delimited by one of quotes, in which escape (if any) escapes the next character.
+func stripComments(code string, line []string, block [][2]string, quotes, escape string) string {
+	var sb strings.Builder
+	for i := 0; i < len(code); {
+		c := code[i]
+		if strings.IndexByte(quotes, c) >= 0 {
+			j := i + 1
+			for j < len(code) && code[j] != c && code[j] != '\n' {
+				if escape != "" && code[j] == escape[0] {
+					j++
+				}
+				j++
+			}
+			if j < len(code) && code[j] == c {
+				j++
+			}
+			if j > len(code) {
+				j = len(code)
+			}
+			sb.WriteString(code[i:j])
+			i = j
+			continue
+		}
+		skipped := false
+		for _, prefix := range line {
+			if strings.HasPrefix(code[i:], prefix) {
+				end := strings.IndexByte(code[i:], '\n')
+				if end < 0 {
+					end = len(code) - i
+				}
+				i += end
+				skipped = true
+				break
+			}
+		}
+		for _, b := range block {
+			if skipped || !strings.HasPrefix(code[i:], b[0]) || strings.HasPrefix(code[i:], "{$") {
+				continue
+			}
+			end := strings.Index(code[i+len(b[0]):], b[1])
+			if end < 0 {
+				i = len(code)
+			} else {
+				i += len(b[0]) + end + len(b[1])
+			}
+			skipped = true
+		}
+		if !skipped {
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
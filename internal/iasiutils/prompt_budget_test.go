@@ -0,0 +1,128 @@
+package iasiutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"şşşş", 1}, // Runes are counted, not bytes.
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	text := "line one\nline two\nline three\n"
+	if got := truncateToTokens(text, 100); got != text {
+		t.Errorf("text within the budget was changed to %q", got)
+	}
+	if got, want := truncateToTokens(text, 7), "line one\n... (truncated)"; got != want {
+		t.Errorf("truncateToTokens() = %q, want %q", got, want)
+	}
+	if got := truncateToTokens(text, 1); got != "\n... (truncated)" {
+		t.Errorf("truncateToTokens() to less than a line = %q", got)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		lang Language
+		want string
+	}{
+		{"cpp", "#include <cstdio>\n// read\nint a; /* the sum\n of both */ int b;  \n\n\nint main() {}",
+			LangCpp, "#include <cstdio>\nint a;  int b;\nint main() {}"},
+		{"strings are kept", `printf("// not a comment /* */"); // comment` + "\nputs('\\'');",
+			LangC, `printf("// not a comment /* */");` + "\nputs('\\'');"},
+		{"escaped quote in a string", `s = "a\"//b"; // c`, LangJava, `s = "a\"//b";`},
+		{"python", "# read\nx = int('#1')  # parse\n\nprint(x)", LangPython, "x = int('#1')\nprint(x)"},
+		{"pascal", "{$MODE OBJFPC}\n{ comment }\n(* another *)\nbegin writeln('{x}'); // done\nend.",
+			LangPascal, "{$MODE OBJFPC}\nbegin writeln('{x}');\nend."},
+		{"unterminated block comment", "int a; /* to the end", LangCpp, "int a;"},
+		{"unknown language keeps comments", "// kept\n\nx", LangUnknown, "// kept\nx"},
+	}
+	for _, tt := range tests {
+		if got := StripComments(tt.code, tt.lang); got != tt.want {
+			t.Errorf("%s: StripComments() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildLLMPromptShrinks(t *testing.T) {
+	legend := strings.TrimSpace(strings.Repeat("Ana are mere. ", 60))
+	ps := &ProblemStatement{
+		Title:       "Adunare",
+		Legend:      legend,
+		Task:        "Afisati a+b.",
+		Examples:    []Example{{Input: "1 2", Output: "3"}, {Input: "40 2", Output: "42"}},
+		Explanation: "1 + 2 = 3.",
+	}
+	source := &SourceFile{Language: LangCpp, Code: "int main() {\n" + strings.Repeat("    // explain the step\n", 50) + "    return 0;\n}"}
+	r, err := ParseRecipe("test", `{{define "prompt"}}{{.Statement}}
+{{.Solution}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlimited := r.TokenBudget
+	r.TokenBudget = -1
+	full, _, err := r.BuildLLMPrompt(ps, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.TokenBudget = unlimited
+
+	// Each budget is met by taking one more step: drop the examples, strip comments, trim the legend,
+	// drop the explanations.
+	steps := []struct {
+		name string
+		gone string
+		kept string
+	}{
+		{"examples", "40 2", "explain the step"},
+		{"comments", "explain the step", legend},
+		{"legend", legend, "1 + 2 = 3."},
+		{"explanation", "1 + 2 = 3.", "return 0;"},
+	}
+	prev := full
+	for _, step := range steps {
+		r.TokenBudget = EstimateTokens(prev) - 1
+		prompt, _, err := r.BuildLLMPrompt(ps, source)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if EstimateTokens(prompt) > r.TokenBudget || strings.Contains(prompt, step.gone) || !strings.Contains(prompt, step.kept) ||
+			!strings.Contains(prompt, "1 2") {
+			t.Fatalf("%s: budget %d, prompt:\n%s", step.name, r.TokenBudget, prompt)
+		}
+		prev = prompt
+	}
+
+	// Then the source is truncated, and a budget too small for the statement alone is an error.
+	r.TokenBudget = EstimateTokens(prev) - 2
+	if prompt, _, err := r.BuildLLMPrompt(ps, source); err != nil || !strings.Contains(prompt, "... (truncated)") {
+		t.Fatalf("truncating the source: %v\n%s", err, prompt)
+	}
+	r.TokenBudget = 20
+	if _, _, err := r.BuildLLMPrompt(ps, source); !errors.Is(err, ErrPromptTooLong) {
+		t.Fatalf("tiny budget: error = %v, want ErrPromptTooLong", err)
+	}
+
+	// The caller's statement and source are not shrunk.
+	if len(ps.Examples) != 2 || ps.Explanation == "" || !strings.Contains(source.Code, "explain the step") {
+		t.Errorf("inputs were modified: %+v %q", ps, source.Code)
+	}
+}
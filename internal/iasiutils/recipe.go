@@ -1,31 +1,531 @@
 package iasiutils
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
 
-// Recipe handles prompt building and related logic for LLMs.
+// Recipe handles prompt building and related logic for LLMs. Its prompts come from a text/template
+// that defines a "prompt" template and optionally "system", "hint", "chat" and "schema" templates, see
+// LoadRecipe.
 type Recipe struct {
-	SystemPrompt string
+	Name string
+	// Schema bounds the generated Editorial; ParseRecipe reads it from the "schema" template, see
+	// parseSchema. DefaultOutputSchema is used when nil.
+	Schema *OutputSchema
+	// Vars are the per-request variables passed to the templates.
+	Vars PromptVars
+	// TokenBudget is the estimated number of tokens the prompts may use, see BuildLLMPrompt.
+	// Zero selects DefaultPromptTokenBudget and a negative value disables the limit.
+	TokenBudget int
+	// Spoilers decides what happens to output leaking the solution. DefaultSpoilerPolicy is used when nil.
+	Spoilers *SpoilerPolicy
+
+	tmpl *template.Template
+	hash string
+}
+
+// PromptVars are the variables a request can set on a recipe.
+type PromptVars struct {
+	// Hints is the number of hints asked for; 0 lets the recipe decide.
+	Hints int
+	// Level is the audience the hints and editorial are written for, e.g. "beginner" or "advanced".
+	Level string
+	// Lang is the code of the language the output is written in, see OutputLanguages. Empty means DefaultLang.
+	Lang string
+}
+
+// DefaultLang is the output language used when a request does not pick one.
+const DefaultLang = "en"
+
+// OutputLanguages maps the supported output language codes to the names used in prompts.
+var OutputLanguages = map[string]string{
+	"en": "English",
+	"ro": "Romanian",
 }
 
-// BuildLLMPrompt creates a prompt for the LLM using the problem statement and solution
-func (r *Recipe) BuildLLMPrompt(statement, solution string) (prompt string, systemPrompt string) {
-	if len(statement) == 0 {
-		statement = "(Problem statement could not be fetched)"
+// promptData is the data the recipe templates are executed with.
+type promptData struct {
+	// Statement is the whole statement as text; the sections below are empty when it was not structured.
+	Statement   string
+	Title       string
+	Legend      string
+	Task        string
+	Input       string
+	Output      string
+	Constraints string
+	Examples    []Example
+	Explanation string
+	// Language is the language of the solution, Code its source and Solution the source in a fenced block.
+	Language string
+	Code     string
+	Solution string
+	Hints    int
+	Level    string
+	// Lang is the output language code and LangName its English name, e.g. "ro" and "Romanian".
+	Lang     string
+	LangName string
+	// OutputFormat describes the JSON answer expected by the schema, or the text a hint step answers with.
+	OutputFormat string
+	// Revealed are the hints already given, HintNumber the one asked for out of Steps, and Final is set
+	// when the editorial is asked for instead; only the "hint" template uses them.
+	Revealed   []string
+	HintNumber int
+	Steps      int
+	Final      bool
+}
+
+const (
+	// DefaultRecipesDir is where recipe templates are looked up when no directory is given.
+	DefaultRecipesDir = "recipes"
+	// DefaultRecipeName is the recipe used when a request does not pick one.
+	DefaultRecipeName = "default"
+)
+
+// ErrUnknownRecipe is returned by LoadRecipe when no template exists for the name.
+var ErrUnknownRecipe = errors.New("unknown recipe")
+
+var recipeNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// defaultRecipeTemplate is the built-in default recipe, used unless the recipes directory overrides it.
+const defaultRecipeTemplate = `{{define "system" -}}
+You are a helpful assistant for competitive programming and you know very well the competitive programming platform, Codeforces and how editorials and hints are written there. Always answer in {{.LangName}}.
+{{- end}}
+
+{{define "prompt" -}}
+You are an expert competitive programming assistant. Given the following problem statement and its solution, generate:
+	- some helpful hints for a student (in {{.LangName}}, do not give away the full solution). Make them so that the student can understand the key ideas and approach to solve the problem on their own. They should gradually lead the student to the solution, without revealing it directly. {{if .Hints}}Provide exactly {{.Hints}} hints.{{else}}Provide around 3 hints. Adjust the number based on the complexity and difficulty of the problem.{{end}} Keep the hints concise and to the point, rather short, don't give away too much.
+	- a detailed editorial (in {{.LangName}}, explaining the solution and key ideas). Don't include snippets of code from the solution. Do an editoril like on Codeforces. Please structure it in markdown format with the necessary sections. Use the solution only as guidance, do not use any namings from the solution at all. You can use names from the task itself.
+{{- if .Level}}
+Write for a {{.Level}} audience.
+{{- end}}
+
+Problem statement:
+{{.Statement}}
+
+Solution (written in {{.Language}}, this is not the official solution):
+{{.Solution}}
+
+{{.OutputFormat}}
+{{- end}}
+`
+
+// defaultHintTemplate is the "hint" template of recipes that do not define one.
+const defaultHintTemplate = `You are an expert competitive programming assistant helping a student who is stuck on the problem below.
+{{- if .Final}} The student has read all the hints below and still needs the full explanation. Write a detailed editorial (in {{.LangName}}, explaining the solution and key ideas) that builds on these hints. Don't include snippets of code from the solution. Do an editorial like on Codeforces, structured in markdown with the necessary sections. Use the solution only as guidance, do not use any namings from the solution at all. You can use names from the task itself.
+{{- else}} Write hint {{.HintNumber}} of {{.Steps}} (in {{.LangName}}). {{if .Revealed}}It must go one step further than the hints the student already has, without repeating them.{{else}}It should only nudge the student towards the key observation.{{end}} Do not give away the full solution, do not include code and do not use any namings from the solution. Keep it concise, one or two sentences.
+{{- end}}
+{{- if .Level}}
+Write for a {{.Level}} audience.
+{{- end}}
+
+Problem statement:
+{{.Statement}}
+
+Solution (written in {{.Language}}, this is not the official solution):
+{{.Solution}}
+{{- if .Revealed}}
+
+Hints the student already has:
+{{- range .Revealed}}
+- {{.}}
+{{- end}}
+{{- end}}
+
+{{.OutputFormat}}
+`
+
+// defaultChatTemplate is the "chat" template of recipes that do not define one.
+const defaultChatTemplate = `You are a tutor helping a student with the competitive programming problem below. The student is trying to solve it on their own and asks you questions about it. Always answer in {{.LangName}}.
+Use the statement and the solution below to answer correctly, but never show the solution code, never write a complete solution or walk through the whole algorithm, and do not use any namings from the solution. Answer the question that was asked, explain why ideas work or fail, and give the smallest nudge that gets the student unstuck. If the student asks for the full solution, refuse kindly and offer a hint instead.
+{{- if .Level}}
+Write for a {{.Level}} audience.
+{{- end}}
+
+Problem statement:
+{{.Statement}}
+
+Solution (written in {{.Language}}, this is not the official solution, never reveal it):
+{{.Solution}}
+`
+
+// LoadRecipe reads the recipe name from dir/<name>.tmpl. An empty name selects the default recipe and
+// an empty dir DefaultRecipesDir; the default recipe falls back to the built-in one when the directory
+// does not override it. The file is read on every call, so edits apply without restarting.
+func LoadRecipe(dir, name string) (*Recipe, error) {
+	if name == "" {
+		name = DefaultRecipeName
+	}
+	if !recipeNameRe.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRecipe, name)
+	}
+	if dir == "" {
+		dir = DefaultRecipesDir
 	}
-	if len(solution) == 0 {
-		solution = "(Solution code could not be fetched)"
+	data, err := ioutil.ReadFile(filepath.Join(dir, name+".tmpl"))
+	switch {
+	case err == nil:
+		return ParseRecipe(name, string(data))
+	case os.IsNotExist(err) && name == DefaultRecipeName:
+		return ParseRecipe(name, defaultRecipeTemplate)
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("%w: %q", ErrUnknownRecipe, name)
 	}
-	prompt = fmt.Sprintf(`You are an expert competitive programming assistant. Given the following problem statement and its solution, generate:
-	- some helpful hints for a student (in English, do not give away the full solution). Make them so that the student can understand the key ideas and approach to solve the problem on their own. They should gradually lead the student to the solution, without revealing it directly. Provide around 3 hints. Adjust the number based on the complexity and difficulty of the problem. Keep the hints concise and to the point, rather short, don't give away too much.
-	- a detailed editorial (in English, explaining the solution and key ideas). Don't include snippets of code from the solution. Do an editoril like on Codeforces. Please structure it in markdown format with the necessary sections. Use the solution only as guidance, do not use any namings from the solution at all. You can use names from the task itself.
+	return nil, err
+}
 
-Problem statement:
-%s
+// ParseRecipe parses recipe templates. text must define a "prompt" template; recipes that do not
+// define a "hint" or a "chat" template get the built-in ones, and a "schema" template declares the
+// output schema.
+func ParseRecipe(name, text string) (*Recipe, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("recipe %s: %w", name, err)
+	}
+	if tmpl.Lookup("prompt") == nil {
+		return nil, fmt.Errorf(`recipe %s: no "prompt" template defined`, name)
+	}
+	// The hash covers everything the prompts are made of besides the request: the recipe text, the
+	// built-in templates it uses, the output schema and the prompt building code.
+	hashed := []string{promptVersion, text}
+	for _, def := range []struct{ name, text string }{{"hint", defaultHintTemplate}, {"chat", defaultChatTemplate}} {
+		if tmpl.Lookup(def.name) != nil {
+			continue
+		}
+		if _, err := tmpl.New(def.name).Parse(def.text); err != nil {
+			return nil, fmt.Errorf("recipe %s: %w", name, err)
+		}
+		hashed = append(hashed, def.text)
+	}
+	r := &Recipe{Name: name, tmpl: tmpl}
+	if t := tmpl.Lookup("schema"); t != nil {
+		var sb strings.Builder
+		if err := t.Execute(&sb, nil); err != nil {
+			return nil, fmt.Errorf("recipe %s: %w", name, err)
+		}
+		schema, err := parseSchema(sb.String())
+		if err != nil {
+			return nil, fmt.Errorf("recipe %s: schema: %w", name, err)
+		}
+		r.Schema = schema
+	}
+	r.hash = recipeHash(append(hashed, r.OutputSchema().describe())...)
+	return r, nil
+}
+
+// parseSchema reads the "schema" template of a recipe: one "key: value" per line, with the keys
+// min_hints, max_hints and require_complexity. Keys that are not given keep their DefaultOutputSchema
+// value; blank lines and lines starting with # are skipped.
+func parseSchema(text string) (*OutputSchema, error) {
+	schema := DefaultOutputSchema
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %q is not key: value", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "min_hints":
+			schema.MinHints, err = strconv.Atoi(value)
+		case "max_hints":
+			schema.MaxHints, err = strconv.Atoi(value)
+		case "require_complexity":
+			schema.RequireComplexity, err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	if schema.MinHints < 0 || schema.MaxHints < 0 || (schema.MaxHints > 0 && schema.MinHints > schema.MaxHints) {
+		return nil, fmt.Errorf("invalid hint bounds %d to %d", schema.MinHints, schema.MaxHints)
+	}
+	return &schema, nil
+}
+
+// promptVersion is part of every recipe hash. Bump it when a change to the code, e.g. to the shrink
+// steps of build, changes the prompts made from the same recipe.
+const promptVersion = "2"
 
-Solution (this is not the official solution):
-%s
+// recipeHash identifies the parts a recipe's prompts are made of, so editorials made with an older
+// version can be told apart.
+func recipeHash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Hash identifies the prompts the recipe makes: it changes with the template text the recipe was
+// parsed from, the built-in templates it falls back to, its output schema and promptVersion.
+func (r *Recipe) Hash() string {
+	if r.hash == "" {
+		def, err := ParseRecipe(DefaultRecipeName, defaultRecipeTemplate)
+		if err != nil {
+			return ""
+		}
+		return def.hash
+	}
+	return r.hash
+}
 
-Return a JSON object with two fields: "hints" (an array of strings) and "editorial" (a string).`, statement, solution)
-	systemPrompt = r.SystemPrompt
-	return
+// ListRecipes returns the names of the recipes available in dir, including the default one.
+func ListRecipes(dir string) []string {
+	if dir == "" {
+		dir = DefaultRecipesDir
+	}
+	names := []string{DefaultRecipeName}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".tmpl")
+		if name != DefaultRecipeName && recipeNameRe.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// OutputSchema returns the schema the recipe's output is validated against. A requested hint count
+// replaces the schema bounds.
+func (r *Recipe) OutputSchema() OutputSchema {
+	schema := DefaultOutputSchema
+	if r.Schema != nil {
+		schema = *r.Schema
+	}
+	if r.Vars.Hints > 0 {
+		schema.MinHints = r.Vars.Hints
+		schema.MaxHints = r.Vars.Hints
+	}
+	return schema
+}
+
+// BuildLLMPrompt creates a prompt for the LLM using the problem statement and solution. A Recipe
+// that was not loaded with LoadRecipe or ParseRecipe uses the built-in default templates.
+//
+// When the prompts are estimated to exceed the token budget, the inputs are shrunk step by step until
+// they fit: examples beyond the first are dropped, comments and blank lines are stripped from the source,
+// the legend is trimmed, the example explanations are dropped and finally the source and an unstructured
+// statement are truncated. What was cut is logged. ErrPromptTooLong is returned if the prompts still do
+// not fit.
+func (r *Recipe) BuildLLMPrompt(ps *ProblemStatement, source *SourceFile) (prompt string, systemPrompt string, err error) {
+	return r.build("prompt", ps, source, nil)
+}
+
+// BuildHintPrompt creates the prompt for the next step of a hint chain of steps hints, given the hints
+// already revealed: hint len(revealed)+1, or the editorial once all the hints were given. It renders
+// the "hint" template and keeps to the token budget like BuildLLMPrompt.
+func (r *Recipe) BuildHintPrompt(ps *ProblemStatement, source *SourceFile, revealed []string, steps int) (prompt string, systemPrompt string, err error) {
+	return r.build("hint", ps, source, func(data *promptData) {
+		data.Revealed = revealed
+		data.HintNumber = len(revealed) + 1
+		data.Steps = steps
+		data.Final = len(revealed) >= steps
+		data.OutputFormat = "Answer with the text of the hint only, without any introduction."
+		if data.Final {
+			data.OutputFormat = "Answer with the editorial only, in markdown, without any introduction."
+		}
+	})
+}
+
+// BuildChatPrompt creates the system prompt of a tutor chat about a problem: the "system" template
+// followed by the "chat" template, which grounds the model in the statement and the solution. It keeps
+// to the token budget like BuildLLMPrompt; the conversation is not counted.
+func (r *Recipe) BuildChatPrompt(ps *ProblemStatement, source *SourceFile) (string, error) {
+	chat, systemPrompt, err := r.build("chat", ps, source, nil)
+	if err != nil {
+		return "", err
+	}
+	if systemPrompt == "" {
+		return strings.TrimSpace(chat), nil
+	}
+	return systemPrompt + "\n\n" + strings.TrimSpace(chat), nil
+}
+
+// build renders the template name with the data of a statement and a source, adjusted by set when
+// it is not nil, and shrinks the inputs to the token budget.
+func (r *Recipe) build(name string, ps *ProblemStatement, source *SourceFile, set func(*promptData)) (prompt string, systemPrompt string, err error) {
+	prompt, systemPrompt, err = r.render(name, ps, source, set)
+	if err != nil {
+		return "", "", err
+	}
+	budget := r.TokenBudget
+	if budget == 0 {
+		budget = DefaultPromptTokenBudget
+	}
+	tokens := EstimateTokens(prompt) + EstimateTokens(systemPrompt)
+	if budget < 0 || tokens <= budget {
+		return prompt, systemPrompt, nil
+	}
+
+	// Shrink copies, the caller's statement and source are left as they are.
+	initial := tokens
+	if ps != nil {
+		shrunk := *ps
+		ps = &shrunk
+	}
+	if source != nil {
+		shrunk := *source
+		source = &shrunk
+	}
+	var cuts []string
+	steps := []func() string{
+		func() string {
+			if ps == nil || !ps.Structured() || len(ps.Examples) <= 1 {
+				return ""
+			}
+			cut := fmt.Sprintf("dropped %d of %d examples", len(ps.Examples)-1, len(ps.Examples))
+			ps.Examples = ps.Examples[:1]
+			return cut
+		},
+		func() string {
+			if source == nil {
+				return ""
+			}
+			stripped := StripComments(source.Code, source.Language)
+			if len(stripped) >= len(source.Code) {
+				return ""
+			}
+			cut := fmt.Sprintf("stripped comments and blank lines from the source (%d to %d lines)", strings.Count(source.Code, "\n")+1, strings.Count(stripped, "\n")+1)
+			source.Code = stripped
+			return cut
+		},
+		func() string {
+			const maxLegend = 400
+			if ps == nil || utf8.RuneCountInString(ps.Legend) <= maxLegend {
+				return ""
+			}
+			cut := fmt.Sprintf("trimmed the legend from %d characters", utf8.RuneCountInString(ps.Legend))
+			ps.Legend = string([]rune(ps.Legend)[:maxLegend]) + "..."
+			return cut
+		},
+		func() string {
+			if ps == nil || !ps.Structured() || ps.Explanation == "" {
+				return ""
+			}
+			ps.Explanation = ""
+			return "dropped the example explanations"
+		},
+		func() string {
+			if source == nil {
+				return ""
+			}
+			code := truncateToTokens(source.Code, EstimateTokens(source.Code)-(tokens-budget))
+			if code == source.Code {
+				return ""
+			}
+			cut := fmt.Sprintf("truncated the source to %d lines", strings.Count(code, "\n"))
+			source.Code = code
+			return cut
+		},
+		func() string {
+			if ps == nil || ps.Structured() {
+				return ""
+			}
+			text := truncateToTokens(ps.Text, EstimateTokens(ps.Text)-(tokens-budget))
+			if text == ps.Text {
+				return ""
+			}
+			ps.Text = text
+			return "truncated the statement"
+		},
+	}
+	for _, step := range steps {
+		cut := step()
+		if cut == "" {
+			continue
+		}
+		cuts = append(cuts, cut)
+		if prompt, systemPrompt, err = r.render(name, ps, source, set); err != nil {
+			return "", "", err
+		}
+		if tokens = EstimateTokens(prompt) + EstimateTokens(systemPrompt); tokens <= budget {
+			break
+		}
+	}
+	if len(cuts) > 0 {
+		log.Printf("[WARN] Prompt of recipe %s shrunk from ~%d to ~%d tokens (budget %d): %s", r.Name, initial, tokens, budget, strings.Join(cuts, "; "))
+	}
+	if tokens > budget {
+		return "", "", fmt.Errorf("%w: ~%d tokens, budget %d", ErrPromptTooLong, tokens, budget)
+	}
+	return prompt, systemPrompt, nil
+}
+
+// render executes the template name and the system template for a statement and a source.
+func (r *Recipe) render(name string, ps *ProblemStatement, source *SourceFile, set func(*promptData)) (prompt string, systemPrompt string, err error) {
+	data := promptData{
+		Statement:    "(Problem statement could not be fetched)",
+		Language:     string(LangUnknown),
+		Solution:     "(Solution code could not be fetched)",
+		Hints:        r.Vars.Hints,
+		Level:        r.Vars.Level,
+		Lang:         r.Vars.Lang,
+		OutputFormat: r.OutputSchema().describe(),
+	}
+	if data.Lang == "" {
+		data.Lang = DefaultLang
+	}
+	data.LangName = OutputLanguages[data.Lang]
+	if data.LangName == "" {
+		data.LangName = data.Lang
+	}
+	if ps != nil {
+		if statement := ps.String(); statement != "" {
+			data.Statement = statement
+		}
+		data.Title = ps.Title
+		data.Legend = ps.Legend
+		data.Task = ps.Task
+		data.Input = ps.Input
+		data.Output = ps.Output
+		data.Constraints = ps.Constraints
+		data.Examples = ps.Examples
+		data.Explanation = ps.Explanation
+	}
+	if source != nil && len(source.Code) > 0 {
+		data.Language = string(source.Language)
+		data.Code = source.Code
+		data.Solution = "```" + source.Language.FenceTag() + "\n" + source.Code + "\n```"
+	}
+	if set != nil {
+		set(&data)
+	}
+	tmpl := r.tmpl
+	if tmpl == nil {
+		def, err := ParseRecipe(DefaultRecipeName, defaultRecipeTemplate)
+		if err != nil {
+			return "", "", err
+		}
+		tmpl = def.tmpl
+	}
+	var sb strings.Builder
+	if err := tmpl.ExecuteTemplate(&sb, name, data); err != nil {
+		return "", "", fmt.Errorf("recipe %s: %w", r.Name, err)
+	}
+	prompt = sb.String()
+	if tmpl.Lookup("system") != nil {
+		sb.Reset()
+		if err := tmpl.ExecuteTemplate(&sb, "system", data); err != nil {
+			return "", "", fmt.Errorf("recipe %s: %w", r.Name, err)
+		}
+		systemPrompt = strings.TrimSpace(sb.String())
+	}
+	return prompt, systemPrompt, nil
 }
@@ -0,0 +1,102 @@
+package iasiutils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPrompt = `{{define "prompt"}}{{.Statement}}{{end}}`
+
+func TestParseRecipeSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		want    *OutputSchema
+		wantErr string
+	}{
+		{"no schema template", "", nil, ""},
+		{"all keys", `{{define "schema"}}
+# bounds of the hints
+min_hints: 2
+max_hints: 3
+require_complexity: true
+{{end}}`, &OutputSchema{MinHints: 2, MaxHints: 3, RequireComplexity: true}, ""},
+		{"missing keys keep the defaults", `{{define "schema"}}max_hints: 8{{end}}`, &OutputSchema{MinHints: DefaultOutputSchema.MinHints, MaxHints: 8}, ""},
+		{"unknown key", `{{define "schema"}}hints: 3{{end}}`, nil, `unknown key "hints"`},
+		{"not a number", `{{define "schema"}}min_hints: many{{end}}`, nil, "invalid min_hints"},
+		{"bounds out of order", `{{define "schema"}}min_hints: 5
+max_hints: 2{{end}}`, nil, "invalid hint bounds"},
+		{"not key: value", `{{define "schema"}}min_hints 5{{end}}`, nil, "not key: value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecipe("test", testPrompt+tt.schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseRecipe() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (r.Schema == nil) != (tt.want == nil) || (r.Schema != nil && *r.Schema != *tt.want) {
+				t.Errorf("Schema = %+v, want %+v", r.Schema, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecipeOutputSchema(t *testing.T) {
+	r, err := LoadRecipe("../../recipes", "beginner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.OutputSchema(); got != (OutputSchema{MinHints: 4, MaxHints: 5}) {
+		t.Errorf("beginner schema = %+v, want 4 to 5 hints", got)
+	}
+	r.Vars.Hints = 2
+	if got := r.OutputSchema(); got.MinHints != 2 || got.MaxHints != 2 {
+		t.Errorf("schema with 2 hints requested = %+v", got)
+	}
+	if got := (&Recipe{}).OutputSchema(); got != DefaultOutputSchema {
+		t.Errorf("schema of a recipe without one = %+v, want the default", got)
+	}
+}
+
+func TestRecipeHash(t *testing.T) {
+	hash := func(text string) string {
+		r, err := ParseRecipe("test", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Hash()
+	}
+	base := hash(testPrompt)
+	if base != hash(testPrompt) {
+		t.Fatal("Hash() differs for the same recipe")
+	}
+	// The built-in templates and the schema the prompts use are part of the hash.
+	if want := recipeHash(promptVersion, testPrompt, defaultHintTemplate, defaultChatTemplate, DefaultOutputSchema.describe()); base != want {
+		t.Errorf("Hash() = %s, want %s", base, want)
+	}
+	for name, text := range map[string]string{
+		"prompt":      `{{define "prompt"}}{{.Title}}{{end}}`,
+		"hint":        testPrompt + `{{define "hint"}}{{.HintNumber}}{{end}}`,
+		"chat":        testPrompt + `{{define "chat"}}Tutor.{{end}}`,
+		"schema":      testPrompt + `{{define "schema"}}max_hints: 8{{end}}`,
+		"system":      testPrompt + `{{define "system"}}Be brief.{{end}}`,
+		"schema keys": testPrompt + `{{define "schema"}}require_complexity: true{{end}}`,
+	} {
+		if hash(text) == base {
+			t.Errorf("changing the %s template kept the hash", name)
+		}
+	}
+	def, err := ParseRecipe(DefaultRecipeName, defaultRecipeTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (&Recipe{}).Hash(); got != def.Hash() {
+		t.Errorf("Hash() of a recipe that was not parsed = %s, want the default recipe's %s", got, def.Hash())
+	}
+}
@@ -0,0 +1,201 @@
+package iasiutils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent identifies the tracker to Infoarena when IASI_USER_AGENT is not set.
+const DefaultUserAgent = "iasi-tracker/1.0 (+https://github.com/MihaiZegheru/iasi)"
+
+// ScrapeClient is the Fetcher used for live Infoarena requests. It paces requests to a
+// requests-per-second budget, applies a per-request timeout, sends a custom User-Agent
+// and retries transient failures (timeouts, 429 and 5xx) with exponential backoff and jitter.
+// A ScrapeClient is safe for concurrent use; all scrapers should share one instance.
+type ScrapeClient struct {
+	Client            *http.Client
+	UserAgent         string
+	RequestsPerSecond float64
+	MaxRetries        int
+	BaseBackoff       time.Duration
+	MaxBackoff        time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewScrapeClient returns a ScrapeClient with the given budget and the default retry policy.
+func NewScrapeClient(requestsPerSecond float64, timeout time.Duration, userAgent string) *ScrapeClient {
+	return &ScrapeClient{
+		Client:            &http.Client{Timeout: timeout},
+		UserAgent:         userAgent,
+		RequestsPerSecond: requestsPerSecond,
+		MaxRetries:        3,
+		BaseBackoff:       500 * time.Millisecond,
+		MaxBackoff:        15 * time.Second,
+	}
+}
+
+// NewScrapeClientFromEnv builds a ScrapeClient configured by IASI_SCRAPE_RPS, IASI_SCRAPE_TIMEOUT,
+// IASI_SCRAPE_RETRIES and IASI_USER_AGENT.
+func NewScrapeClientFromEnv() *ScrapeClient {
+	userAgent := EnvString("IASI_USER_AGENT", DefaultUserAgent)
+	sc := NewScrapeClient(EnvFloat("IASI_SCRAPE_RPS", 1), EnvDuration("IASI_SCRAPE_TIMEOUT", 30*time.Second), userAgent)
+	sc.MaxRetries = EnvInt("IASI_SCRAPE_RETRIES", sc.MaxRetries)
+	return sc
+}
+
+var (
+	defaultScrapeClient     *ScrapeClient
+	defaultScrapeClientOnce sync.Once
+)
+
+// DefaultScrapeClient returns the process-wide ScrapeClient, configured from the environment on first use.
+func DefaultScrapeClient() *ScrapeClient {
+	defaultScrapeClientOnce.Do(func() {
+		defaultScrapeClient = NewScrapeClientFromEnv()
+	})
+	return defaultScrapeClient
+}
+
+// Get fetches url with a GET request.
+func (sc *ScrapeClient) Get(url string) ([]byte, error) {
+	return sc.do("GET", url, "")
+}
+
+// PostForm posts form to url.
+func (sc *ScrapeClient) PostForm(url string, form string) ([]byte, error) {
+	return sc.do("POST", url, form)
+}
+
+// transientError marks a failure worth retrying, optionally with a server-requested delay.
+type transientError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (te *transientError) Error() string { return te.err.Error() }
+func (te *transientError) Unwrap() error { return te.err }
+
+func (sc *ScrapeClient) do(method, url, form string) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= sc.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := sc.backoff(attempt)
+			var te *transientError
+			if errors.As(lastErr, &te) && te.retryAfter > delay {
+				delay = te.retryAfter
+			}
+			log.Printf("[WARN] %s %s failed (%v), retrying in %s (attempt %d/%d)", method, url, lastErr, delay.Round(time.Millisecond), attempt, sc.MaxRetries)
+			time.Sleep(delay)
+		}
+		body, err := sc.once(method, url, form)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		var te *transientError
+		if !errors.As(err, &te) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("giving up on %s %s after %d attempts: %w", method, url, sc.MaxRetries+1, lastErr)
+}
+
+func (sc *ScrapeClient) once(method, url, form string) ([]byte, error) {
+	sc.wait()
+	var req *http.Request
+	var err error
+	if method == "POST" {
+		req, err = http.NewRequest(method, url, strings.NewReader(form))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequest(method, url, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sc.UserAgent != "" {
+		req.Header.Set("User-Agent", sc.UserAgent)
+	}
+	client := sc.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Transport failures (timeouts, resets, DNS hiccups) are retried.
+		return nil, &transientError{err: err}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &transientError{err: err}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, &transientError{
+			err:        fmt.Errorf("%s: HTTP %d", url, resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// wait blocks until the next request slot allowed by RequestsPerSecond.
+func (sc *ScrapeClient) wait() {
+	if sc.RequestsPerSecond <= 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / sc.RequestsPerSecond)
+	sc.mu.Lock()
+	now := time.Now()
+	slot := sc.next
+	if slot.Before(now) {
+		slot = now
+	}
+	sc.next = slot.Add(interval)
+	sc.mu.Unlock()
+	time.Sleep(time.Until(slot))
+}
+
+// backoff returns the delay before the given retry: exponential in attempt, capped at MaxBackoff,
+// with the upper half randomized so concurrent scrapers do not retry in lockstep.
+func (sc *ScrapeClient) backoff(attempt int) time.Duration {
+	d := sc.BaseBackoff << uint(attempt-1)
+	if d <= 0 || (sc.MaxBackoff > 0 && d > sc.MaxBackoff) {
+		d = sc.MaxBackoff
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package iasiutils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers the i-th request with statuses[i] (200 once the script runs out) and
+// records when each request arrived and with which User-Agent.
+type scriptedServer struct {
+	*httptest.Server
+	statuses   []int
+	retryAfter string
+
+	mu     sync.Mutex
+	times  []time.Time
+	agents []string
+	forms  []string
+}
+
+func newScriptedServer(t *testing.T, retryAfter string, statuses ...int) *scriptedServer {
+	ss := &scriptedServer{statuses: statuses, retryAfter: retryAfter}
+	ss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ss.mu.Lock()
+		n := len(ss.times)
+		ss.times = append(ss.times, time.Now())
+		ss.agents = append(ss.agents, r.UserAgent())
+		ss.forms = append(ss.forms, r.PostForm.Encode())
+		ss.mu.Unlock()
+		status := http.StatusOK
+		if n < len(ss.statuses) {
+			status = ss.statuses[n]
+		}
+		if status == http.StatusTooManyRequests && ss.retryAfter != "" {
+			w.Header().Set("Retry-After", ss.retryAfter)
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "response %d", n+1)
+	}))
+	t.Cleanup(ss.Close)
+	return ss
+}
+
+func (ss *scriptedServer) requests() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.times)
+}
+
+// gap returns the time between the arrival of requests i and j.
+func (ss *scriptedServer) gap(i, j int) time.Duration {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.times[j].Sub(ss.times[i])
+}
+
+// testScrapeClient returns a client without pacing and with millisecond backoffs.
+func testScrapeClient() *ScrapeClient {
+	sc := NewScrapeClient(0, 5*time.Second, "iasi-test/1.0")
+	sc.BaseBackoff = time.Millisecond
+	sc.MaxBackoff = 5 * time.Millisecond
+	return sc
+}
+
+func TestScrapeClientRetryAfter(t *testing.T) {
+	ss := newScriptedServer(t, "1", http.StatusTooManyRequests)
+	body, err := testScrapeClient().Get(ss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "response 2" {
+		t.Errorf("body = %q, want the response to the retry", body)
+	}
+	if ss.requests() != 2 {
+		t.Fatalf("made %d requests, want 2", ss.requests())
+	}
+	if waited := ss.gap(0, 1); waited < 900*time.Millisecond {
+		t.Errorf("retried after %s, want the 1s asked by Retry-After", waited)
+	}
+}
+
+func TestScrapeClientRetriesServerErrors(t *testing.T) {
+	ss := newScriptedServer(t, "", http.StatusBadGateway, http.StatusServiceUnavailable)
+	body, err := testScrapeClient().PostForm(ss.URL, "force_view_source=Vezi+sursa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "response 3" {
+		t.Errorf("body = %q, want the third response", body)
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for i, form := range ss.forms {
+		if form != "force_view_source=Vezi+sursa" {
+			t.Errorf("request %d posted %q, want the form on every attempt", i+1, form)
+		}
+	}
+}
+
+func TestScrapeClientGivesUp(t *testing.T) {
+	ss := newScriptedServer(t, "", 500, 500, 500, 500, 500)
+	sc := testScrapeClient()
+	sc.MaxRetries = 2
+	_, err := sc.Get(ss.URL)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Fatalf("Get error = %v, want giving up after 3 attempts", err)
+	}
+	if ss.requests() != 3 {
+		t.Errorf("made %d requests, want 3", ss.requests())
+	}
+}
+
+func TestScrapeClientDoesNotRetryClientErrors(t *testing.T) {
+	ss := newScriptedServer(t, "", http.StatusNotFound)
+	_, err := testScrapeClient().Get(ss.URL)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Fatalf("Get error = %v, want HTTP 404", err)
+	}
+	if ss.requests() != 1 {
+		t.Errorf("made %d requests, want 1", ss.requests())
+	}
+}
+
+func TestScrapeClientUserAgent(t *testing.T) {
+	ss := newScriptedServer(t, "", http.StatusInternalServerError)
+	if _, err := testScrapeClient().Get(ss.URL); err != nil {
+		t.Fatal(err)
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for i, ua := range ss.agents {
+		if ua != "iasi-test/1.0" {
+			t.Errorf("request %d sent User-Agent %q", i+1, ua)
+		}
+	}
+}
+
+func TestScrapeClientPacing(t *testing.T) {
+	ss := newScriptedServer(t, "")
+	sc := testScrapeClient()
+	sc.RequestsPerSecond = 20
+	for i := 0; i < 3; i++ {
+		if _, err := sc.Get(ss.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spent := ss.gap(0, 2); spent < 90*time.Millisecond {
+		t.Errorf("3 requests at 20 per second took %s, want at least 100ms", spent)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"7", 7 * time.Second},
+		{" 2 ", 2 * time.Second},
+		{"soon", 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Hour},
+	}
+	for _, tt := range tests {
+		got := parseRetryAfter(tt.in)
+		if got < tt.want-2*time.Second || got > tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package iasiutils
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Language is the programming language of a submitted source.
+type Language string
+
+const (
+	LangUnknown Language = "unknown"
+	LangCpp     Language = "C++"
+	LangC       Language = "C"
+	LangPascal  Language = "Pascal"
+	LangJava    Language = "Java"
+	LangPython  Language = "Python"
+)
+
+// Ext returns the usual file extension for the language, including the dot.
+func (l Language) Ext() string {
+	switch l {
+	case LangCpp:
+		return ".cpp"
+	case LangC:
+		return ".c"
+	case LangPascal:
+		return ".pas"
+	case LangJava:
+		return ".java"
+	case LangPython:
+		return ".py"
+	}
+	return ".txt"
+}
+
+// FenceTag returns the Markdown code fence tag for the language.
+func (l Language) FenceTag() string {
+	switch l {
+	case LangCpp:
+		return "cpp"
+	case LangC:
+		return "c"
+	case LangPascal:
+		return "pascal"
+	case LangJava:
+		return "java"
+	case LangPython:
+		return "python"
+	}
+	return ""
+}
+
+// SourceFile is the source code of a submission.
+type SourceFile struct {
+	Language Language `json:"language"`
+	Code     string   `json:"code"`
+}
+
+// JobDetail is the information read from a job_detail page.
+type JobDetail struct {
+	ProblemURL string
+	// Compiler is the raw compiler id shown on the page, e.g. "cpp-64" or "fpc".
+	Compiler string
+}
+
+// ParseJobDetail reads the problem link and the compiler from a job_detail page.
+func ParseJobDetail(body []byte) (*JobDetail, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	jd := &JobDetail{}
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if exists && strings.HasPrefix(href, "/problema/") {
+			jd.ProblemURL = "https://www.infoarena.ro" + href
+		}
+	})
+	doc.Find("table").Each(func(i int, table *goquery.Selection) {
+		eachLabelValue(table, func(label, value string, _ *goquery.Selection) {
+			if strings.HasPrefix(label, "compilator") || strings.HasPrefix(label, "limbaj") {
+				jd.Compiler = value
+			}
+		})
+	})
+	return jd, nil
+}
+
+// LanguageFromCompiler maps an Infoarena compiler id to its language.
+func LanguageFromCompiler(compiler string) Language {
+	c := strings.ToLower(strings.TrimSpace(compiler))
+	switch {
+	case c == "":
+		return LangUnknown
+	case strings.Contains(c, "cpp") || strings.Contains(c, "c++") || strings.Contains(c, "g++"):
+		return LangCpp
+	case strings.Contains(c, "java"):
+		return LangJava
+	case strings.HasPrefix(c, "py"):
+		return LangPython
+	case strings.Contains(c, "fpc") || strings.Contains(c, "pas"):
+		return LangPascal
+	case c == "c" || strings.HasPrefix(c, "c-") || strings.HasPrefix(c, "c ") || strings.Contains(c, "gcc"):
+		return LangC
+	}
+	return LangUnknown
+}
+
+// guessLanguage infers the language from highlighter classes or the code itself.
+func guessLanguage(class, code string) Language {
+	for _, c := range strings.Fields(class) {
+		if lang := LanguageFromCompiler(strings.TrimPrefix(c, "language-")); lang != LangUnknown {
+			return lang
+		}
+	}
+	switch {
+	case strings.Contains(code, "#include"):
+		if strings.Contains(code, "iostream") || strings.Contains(code, "using namespace") || strings.Contains(code, "bits/stdc++") {
+			return LangCpp
+		}
+		return LangC
+	case strings.Contains(code, "public static void main"):
+		return LangJava
+	case strings.Contains(strings.ToLower(code), "begin") && strings.Contains(strings.ToLower(code), "end."):
+		return LangPascal
+	case strings.Contains(code, "def ") || strings.Contains(code, "print("):
+		return LangPython
+	}
+	return LangUnknown
+}
+
+// ParseSourceFile picks the single source block from a view-source page. Among the <textarea>,
+// <code> and <pre> elements it takes the innermost one with the longest text and keeps its
+// whitespace exactly. The language comes from compiler when known.
+func ParseSourceFile(body []byte, compiler string) (*SourceFile, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var best *goquery.Selection
+	bestCode := ""
+	doc.Find("textarea, code, pre").Each(func(i int, sel *goquery.Selection) {
+		if sel.Find("textarea, code, pre").Length() > 0 {
+			return // Highlighters nest <code> in <pre>; only the innermost block is considered.
+		}
+		code := nodeText(sel.Get(0))
+		if len(strings.TrimSpace(code)) > 0 && len(code) > len(bestCode) {
+			best = sel
+			bestCode = code
+		}
+	})
+	src := &SourceFile{Language: LanguageFromCompiler(compiler)}
+	if best == nil {
+		return src, nil
+	}
+	src.Code = strings.Trim(strings.ReplaceAll(bestCode, "\r\n", "\n"), "\n")
+	if src.Language == LangUnknown {
+		class, _ := best.Attr("class")
+		src.Language = guessLanguage(class, src.Code)
+	}
+	return src, nil
+}
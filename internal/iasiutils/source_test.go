@@ -0,0 +1,137 @@
+package iasiutils
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseJobDetail(t *testing.T) {
+	body, err := ioutil.ReadFile(fixturePath(fixturesDir, "GET", "https://www.infoarena.ro/job_detail/3012", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		body []byte
+		want JobDetail
+	}{
+		{"recorded page", body, JobDetail{ProblemURL: "https://www.infoarena.ro/problema/adunare", Compiler: "cpp-64"}},
+		{"language label", []byte(`<a href="/problema/ciur">Ciur</a><table><tr><td>Limbaj:</td><td> fpc </td></tr></table>`),
+			JobDetail{ProblemURL: "https://www.infoarena.ro/problema/ciur", Compiler: "fpc"}},
+		{"no problem link", []byte(`<table><tr><th>Compilator</th><td>py</td></tr></table>`), JobDetail{Compiler: "py"}},
+	}
+	for _, tt := range tests {
+		jd, err := ParseJobDetail(tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *jd != tt.want {
+			t.Errorf("%s: ParseJobDetail() = %+v, want %+v", tt.name, *jd, tt.want)
+		}
+	}
+}
+
+func TestLanguageFromCompiler(t *testing.T) {
+	tests := []struct {
+		compiler string
+		want     Language
+	}{
+		{"cpp-64", LangCpp},
+		{"cpp-32", LangCpp},
+		{"C++", LangCpp},
+		{"g++ 4.8", LangCpp},
+		{"c-64", LangC},
+		{"c", LangC},
+		{"gcc", LangC},
+		{"fpc", LangPascal},
+		{"pas", LangPascal},
+		{"java", LangJava},
+		{"py", LangPython},
+		{"python3", LangPython},
+		{" CPP ", LangCpp},
+		{"", LangUnknown},
+		{"rust", LangUnknown},
+	}
+	for _, tt := range tests {
+		if got := LanguageFromCompiler(tt.compiler); got != tt.want {
+			t.Errorf("LanguageFromCompiler(%q) = %s, want %s", tt.compiler, got, tt.want)
+		}
+	}
+}
+
+func TestParseSourceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		compiler string
+		want     SourceFile
+	}{
+		{
+			name:     "highlighter nests code in pre",
+			body:     "<pre><code class=\"language-cpp\">int main() {\n    return 0;\n}\n</code></pre>",
+			compiler: "cpp-64",
+			want:     SourceFile{Language: LangCpp, Code: "int main() {\n    return 0;\n}"},
+		},
+		{
+			name:     "longest block wins over short snippets",
+			body:     "<code>a+b</code><textarea>program p;\r\nbegin\r\n  writeln(1);\r\nend.</textarea>",
+			compiler: "",
+			want:     SourceFile{Language: LangPascal, Code: "program p;\nbegin\n  writeln(1);\nend."},
+		},
+		{
+			name:     "escaped characters and indentation are kept",
+			body:     "<pre>#include &lt;stdio.h&gt;\n\tint x = 1 &amp; 3;</pre>",
+			compiler: "",
+			want:     SourceFile{Language: LangC, Code: "#include <stdio.h>\n\tint x = 1 & 3;"},
+		},
+		{
+			name:     "language from the highlighter class",
+			body:     `<pre class="language-java">class Main {}</pre>`,
+			compiler: "",
+			want:     SourceFile{Language: LangJava, Code: "class Main {}"},
+		},
+		{
+			name:     "python guessed from the code",
+			body:     "<pre>def solve():\n    print(42)</pre>",
+			compiler: "",
+			want:     SourceFile{Language: LangPython, Code: "def solve():\n    print(42)"},
+		},
+		{
+			name:     "compiler wins over the guess",
+			body:     "<pre>#include &lt;iostream&gt;</pre>",
+			compiler: "c-64",
+			want:     SourceFile{Language: LangC, Code: "#include <iostream>"},
+		},
+		{
+			name:     "no source block",
+			body:     "<p>Nu aveti permisiunea sa vedeti sursa.</p><pre>   </pre>",
+			compiler: "cpp-64",
+			want:     SourceFile{Language: LangCpp},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := ParseSourceFile([]byte(tt.body), tt.compiler)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *src != tt.want {
+				t.Errorf("ParseSourceFile() = %+v, want %+v", *src, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchSourceViewSourceForm(t *testing.T) {
+	fetcher := &stubFetcher{pages: map[string]string{
+		"GET https://www.infoarena.ro/job_detail/7?action=view-source":                               `<form><input id="force_view_source"/></form>`,
+		"POST https://www.infoarena.ro/job_detail/7?action=view-source force_view_source=Vezi+sursa": `<pre>int main() {}</pre>`,
+	}}
+	src, err := (&InfoarenaIngestor{Fetcher: fetcher}).FetchSource("7", "cpp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Code != "int main() {}" || fetcher.calls != 2 {
+		t.Errorf("FetchSource() = %q in %d requests, want the code revealed by the form", src.Code, fetcher.calls)
+	}
+}
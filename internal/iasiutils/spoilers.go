@@ -0,0 +1,231 @@
+package iasiutils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LeakReport measures how much of the solution an Editorial gives away.
+type LeakReport struct {
+	// Score grows from 0 (nothing of the solution shows) to 1, see CheckSpoilers.
+	Score float64 `json:"score"`
+	// Flagged is set when Score reached the FlagScore of the SpoilerPolicy.
+	Flagged bool `json:"flagged,omitempty"`
+	// SharedIdentifiers are the solution's own identifiers found in the hints or the editorial.
+	SharedIdentifiers []string `json:"shared_identifiers,omitempty"`
+	// CopiedLines are the solution lines found verbatim in the hints or the editorial.
+	CopiedLines []string `json:"copied_lines,omitempty"`
+	// Overlap is the share of the solution's token n-grams found in the hints or the editorial.
+	Overlap float64 `json:"overlap"`
+	// Problems describes every leak, in the words used to ask the model for a repair.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// SpoilerPolicy decides what happens to an Editorial leaking the solution.
+type SpoilerPolicy struct {
+	// FlagScore flags output scoring at least this much; it is still cached. Zero flags nothing.
+	FlagScore float64
+	// RejectScore rejects output scoring at least this much, like output not matching the schema.
+	// Zero rejects nothing.
+	RejectScore float64
+}
+
+// DefaultSpoilerPolicy is used by recipes that do not set their own policy.
+var DefaultSpoilerPolicy = SpoilerPolicy{FlagScore: 0.3, RejectScore: 0.7}
+
+const (
+	// leakNGram is the length of the token sequences compared between the solution and the output.
+	leakNGram = 6
+	// minCopiedLine is the length a solution line needs, without whitespace, to count as copied.
+	minCopiedLine = 16
+)
+
+var (
+	identifierRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	codeTokenRe  = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|[0-9]+|[^\sA-Za-z0-9_]`)
+)
+
+// commonIdentifiers are keywords, library names and generic variable names. Sharing them with the
+// solution says nothing about it.
+var commonIdentifiers = stringSet(`
+	and array as auto begin bool boolean break byte case catch char cin class const continue cout def default
+	delete div do double elif else end endl false final float for from function goto if import in include
+	inline int integer interface iostream is long main map max min mod namespace new nil not null of or pair
+	print printf private procedure program public queue range read readln real record return scanf set short
+	signed sizeof stack static std stdio string struct switch template then this throw to true try type typedef
+	uint unsigned until using var vector void while with write writeln bits stdc push_back size sort swap
+	abs len input output freopen fin fout ifstream ofstream fstream ios sync_with_stdio tie cstdio cstring
+	algorithm first second begin end ll ull ans res sum cnt count result temp tmp val value sol answer
+`)
+
+// commonWords are ordinary English words and names of well-known techniques that solutions use as
+// identifiers. Hints and editorials use them in prose, so finding them there says nothing either.
+var commonWords = stringSet(`
+	all any best bit both current cur dist distance done down each edge edges found graph half high left
+	length level limit line list low mid middle next node nodes number numbers pos position prev previous
+	right start step steps stop total up used visited seen last big small other key keys index idx
+	dp bfs dfs gcd lcm sieve prime primes divisor divisors digit digits matrix tree root heap
+`)
+
+func stringSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// CheckSpoilers compares ed against the solution source and the hint ordering rule. Three signals
+// feed the Score:
+//   - identifiers of the solution that appear in the text in any case, other than keywords, common
+//     names, ordinary words and words of the statement (0.1 each),
+//   - solution lines copied verbatim (0.2 each),
+//   - the share of the solution's token 6-grams found in the text (counted twice);
+//
+// and each hint that contains code or reveals more than the hint after it adds 0.2. Each hint is
+// measured on its own, so a hint repeating the names of the hint before it reveals at least as much.
+// The score is capped at 1.
+func CheckSpoilers(ed *Editorial, ps *ProblemStatement, source *SourceFile) LeakReport {
+	var report LeakReport
+	if source == nil || strings.TrimSpace(source.Code) == "" {
+		return report
+	}
+	code := StripComments(source.Code, source.Language)
+	statement := ""
+	if ps != nil {
+		statement = ps.String()
+	}
+	idents := solutionIdentifiers(code, statement)
+	lines := solutionLines(code)
+	texts := append(append([]string(nil), ed.Hints...), ed.Editorial)
+
+	shared := map[string]bool{}
+	copied := map[string]bool{}
+	// revealed counts the identifiers and lines each text gives away, for the hint ordering rule.
+	revealed := make([]int, len(texts))
+	for i, text := range texts {
+		inText := map[string]bool{}
+		for _, word := range identifierRe.FindAllString(text, -1) {
+			if name, ok := idents[strings.ToLower(word)]; ok && !inText[name] {
+				inText[name] = true
+				shared[name] = true
+				revealed[i]++
+			}
+		}
+		squashed := squashSpaces(text)
+		for _, line := range lines {
+			if strings.Contains(squashed, squashSpaces(line)) {
+				copied[line] = true
+				revealed[i] += 2
+			}
+		}
+	}
+	report.SharedIdentifiers = sortedKeys(shared)
+	report.CopiedLines = sortedKeys(copied)
+	report.Overlap = ngramOverlap(code, strings.Join(texts, "\n"))
+
+	score := 0.1*float64(len(shared)) + 0.2*float64(len(copied)) + 2*report.Overlap
+	if len(shared) > 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("the output reuses identifiers from the solution (%s); use names from the statement or describe the idea instead", strings.Join(report.SharedIdentifiers, ", ")))
+	}
+	if len(copied) > 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("the output copies %d lines of the solution code", len(copied)))
+	}
+	if report.Overlap >= 0.1 {
+		report.Problems = append(report.Problems, fmt.Sprintf("%.0f%% of the solution code appears in the output", 100*report.Overlap))
+	}
+	for i, hint := range ed.Hints {
+		switch {
+		case strings.Contains(hint, "```"):
+			score += 0.2
+			report.Problems = append(report.Problems, fmt.Sprintf("hint %d contains code; hints must only point towards the idea", i+1))
+		case i+1 < len(ed.Hints) && revealed[i] > revealed[i+1]:
+			score += 0.2
+			report.Problems = append(report.Problems, fmt.Sprintf("hint %d reveals more of the solution than hint %d; hints must go from vague to specific", i+1, i+2))
+		}
+	}
+	report.Score = math.Round(math.Min(score, 1)*100) / 100
+	return report
+}
+
+// solutionIdentifiers returns the identifiers of code that are specific to the solution, keyed by
+// their lower case form. Names written in several cases keep the first spelling.
+func solutionIdentifiers(code, statement string) map[string]string {
+	inStatement := map[string]bool{}
+	for _, word := range identifierRe.FindAllString(NormalizeRomanian(statement), -1) {
+		inStatement[word] = true
+	}
+	idents := map[string]string{}
+	for _, word := range identifierRe.FindAllString(stripStringLiterals(code), -1) {
+		lower := strings.ToLower(word)
+		if len(word) < 2 || commonIdentifiers[lower] || commonWords[lower] || inStatement[lower] {
+			continue
+		}
+		if _, ok := idents[lower]; !ok {
+			idents[lower] = word
+		}
+	}
+	return idents
+}
+
+// stringLiteralRe matches the string literals of the supported languages, which hold text rather than names.
+var stringLiteralRe = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'`)
+
+func stripStringLiterals(code string) string {
+	return stringLiteralRe.ReplaceAllString(code, " ")
+}
+
+// solutionLines returns the lines of code long enough to tell whether they were copied.
+func solutionLines(code string) []string {
+	var lines []string
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if len(squashSpaces(line)) >= minCopiedLine {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func squashSpaces(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// ngramOverlap returns the share of the token n-grams of code that also occur in text.
+func ngramOverlap(code, text string) float64 {
+	codeGrams := ngrams(codeTokenRe.FindAllString(code, -1))
+	if len(codeGrams) == 0 {
+		return 0
+	}
+	textGrams := ngrams(codeTokenRe.FindAllString(text, -1))
+	found := 0
+	for gram := range codeGrams {
+		if textGrams[gram] {
+			found++
+		}
+	}
+	return math.Round(float64(found)/float64(len(codeGrams))*100) / 100
+}
+
+func ngrams(tokens []string) map[string]bool {
+	grams := map[string]bool{}
+	for i := 0; i+leakNGram <= len(tokens); i++ {
+		grams[strings.Join(tokens[i:i+leakNGram], " ")] = true
+	}
+	return grams
+}
+
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
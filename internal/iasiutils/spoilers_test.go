@@ -0,0 +1,97 @@
+package iasiutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spoilerSource sums the weights of a knapsack with a few names specific to the solution.
+const spoilerSource = `#include <iostream>
+// read the items
+int main() {
+    int n, capacity, best = 0, total = 0;
+    std::cin >> n >> capacity;
+    long long bestWeight[1001] = {0};
+    for (int i = 0; i < n; i++) {
+        int w; std::cin >> w;
+        for (int c = capacity; c >= w; c--) bestWeight[c] = std::max(bestWeight[c], bestWeight[c - w] + w);
+    }
+    std::cout << bestWeight[capacity] << "\n";
+}`
+
+func checkSpoilers(hints []string, editorial string) LeakReport {
+	ps := &ProblemStatement{Task: "Given N items and the Capacity of the knapsack, print the greatest weight that fits."}
+	return CheckSpoilers(&Editorial{Hints: hints, Editorial: editorial}, ps, &SourceFile{Language: LangCpp, Code: spoilerSource})
+}
+
+func TestCheckSpoilersClean(t *testing.T) {
+	report := checkSpoilers(
+		[]string{"Think about the best total weight for each capacity.", "Use DP over the capacity, going from the largest value down."},
+		"## Idea\n\nKeep, for every capacity, the maximum total weight that fits. Process the items one by one, "+
+			"going down over the capacities so every item is used once. The answer is the value for the full capacity.",
+	)
+	// capacity is a word of the statement; best, total and DP are ordinary words.
+	if report.Score != 0 || len(report.SharedIdentifiers) != 0 || len(report.Problems) != 0 {
+		t.Errorf("clean editorial: %+v", report)
+	}
+}
+
+func TestCheckSpoilersIdentifiers(t *testing.T) {
+	report := checkSpoilers(nil, "Fill BESTWEIGHT from the end; bestweight[c] is the answer.")
+	if !reflect.DeepEqual(report.SharedIdentifiers, []string{"bestWeight"}) || report.Score != 0.1 {
+		t.Errorf("identifier in another case: %+v", report)
+	}
+}
+
+func TestCheckSpoilersCopiedCode(t *testing.T) {
+	report := checkSpoilers([]string{"```cpp\nint w;\n```"},
+		"Loop with for (int c = capacity; c >= w; c--) bestWeight[c] = std::max(bestWeight[c], bestWeight[c - w] + w);")
+	if len(report.CopiedLines) != 1 || report.Overlap == 0 || report.Score < DefaultSpoilerPolicy.FlagScore {
+		t.Errorf("copied line: %+v", report)
+	}
+	if len(report.Problems) < 3 || !strings.Contains(strings.Join(report.Problems, "\n"), "hint 1 contains code") {
+		t.Errorf("problems = %q", report.Problems)
+	}
+}
+
+func TestCheckSpoilersHintOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		hints   []string
+		flagged bool
+	}{
+		{"later hint repeats the name", []string{"Look at bestWeight.", "Update bestWeight going down."}, false},
+		{"vague to specific", []string{"Think about capacities.", "Fill bestWeight going down."}, false},
+		{"specific first", []string{"Fill bestWeight going down.", "Think about capacities."}, true},
+		{"in another case", []string{"Fill BestWeight.", "Think about capacities."}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := checkSpoilers(tt.hints, "")
+			flagged := strings.Contains(strings.Join(report.Problems, "\n"), "hint 1 reveals more of the solution than hint 2")
+			if flagged != tt.flagged {
+				t.Errorf("hint order flagged: %v, want %v; %+v", flagged, tt.flagged, report)
+			}
+		})
+	}
+}
+
+func TestCheckSpoilersWithoutSource(t *testing.T) {
+	ed := &Editorial{Hints: []string{"```cpp\nint x;\n```"}, Editorial: "x"}
+	if report := CheckSpoilers(ed, nil, nil); report.Score != 0 || report.Problems != nil {
+		t.Errorf("without a source: %+v", report)
+	}
+	if report := CheckSpoilers(ed, nil, &SourceFile{Code: "  \n"}); report.Score != 0 {
+		t.Errorf("with an empty source: %+v", report)
+	}
+}
+
+func TestSolutionIdentifiers(t *testing.T) {
+	code := `int Capacity, maxSum, sum, x; string s = "hiddenName"; vector<int> Weights, weights;`
+	got := solutionIdentifiers(code, "Capacitatea şi greutăţile (weights).")
+	want := map[string]string{"capacity": "Capacity", "maxsum": "maxSum"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("solutionIdentifiers() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,314 @@
+package iasiutils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ProblemStatement is an Infoarena problem page split into its usual sections.
+type ProblemStatement struct {
+	Title      string `json:"title"`
+	InputFile  string `json:"input_file"`
+	OutputFile string `json:"output_file"`
+	// TimeLimitSeconds is the execution time limit per test, zero if not found.
+	TimeLimitSeconds float64 `json:"time_limit_seconds"`
+	// MemoryLimitKB is the memory limit in kilobytes, zero if not found.
+	MemoryLimitKB int    `json:"memory_limit_kb"`
+	Author        string `json:"author,omitempty"`
+	Source        string `json:"source,omitempty"`
+	// Difficulty is the number of difficulty stars or the rating written on the page, e.g. 2.5; 0 when
+	// the problem is not rated.
+	Difficulty float64 `json:"difficulty,omitempty"`
+
+	// Legend is the story text before the first section heading. Sections are Markdown.
+	Legend      string    `json:"legend"`
+	Task        string    `json:"task"`        // Cerinta
+	Input       string    `json:"input"`       // Date de intrare
+	Output      string    `json:"output"`      // Date de iesire
+	Constraints string    `json:"constraints"` // Restrictii
+	Examples    []Example `json:"examples"`    // Exemple
+	// Explanation holds the example explanations (Explicatie), if any.
+	Explanation string `json:"explanation"`
+
+	// Text is the whole statement flattened to plain text, used when the sections cannot be found.
+	Text string `json:"text"`
+	// Markdown is the whole statement converted to Markdown, see HTMLToMarkdown.
+	Markdown string `json:"markdown"`
+}
+
+// Example is one sample input/output pair from the statement.
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// Structured reports whether the statement sections were recognized.
+func (ps *ProblemStatement) Structured() bool {
+	return ps.Task != "" || ps.Input != "" || ps.Output != ""
+}
+
+// String renders the statement as plain text, section by section.
+func (ps *ProblemStatement) String() string {
+	if !ps.Structured() {
+		return ps.Text
+	}
+	var sb strings.Builder
+	section := func(title, body string) {
+		if strings.TrimSpace(body) == "" {
+			return
+		}
+		fmt.Fprintf(&sb, "%s\n%s\n\n", title, strings.TrimSpace(body))
+	}
+	if ps.Title != "" {
+		fmt.Fprintf(&sb, "%s\n\n", ps.Title)
+	}
+	if ps.InputFile != "" || ps.OutputFile != "" {
+		fmt.Fprintf(&sb, "Input file: %s, output file: %s\n", ps.InputFile, ps.OutputFile)
+	}
+	if ps.TimeLimitSeconds > 0 {
+		fmt.Fprintf(&sb, "Time limit: %g s\n", ps.TimeLimitSeconds)
+	}
+	if ps.MemoryLimitKB > 0 {
+		fmt.Fprintf(&sb, "Memory limit: %d KB\n", ps.MemoryLimitKB)
+	}
+	sb.WriteString("\n")
+	section("Legend", ps.Legend)
+	section("Task", ps.Task)
+	section("Input", ps.Input)
+	section("Output", ps.Output)
+	section("Constraints", ps.Constraints)
+	for i, ex := range ps.Examples {
+		fmt.Fprintf(&sb, "Example %d input:\n%s\nExample %d output:\n%s\n\n", i+1, ex.Input, i+1, ex.Output)
+	}
+	section("Explanation", ps.Explanation)
+	return strings.TrimSpace(sb.String())
+}
+
+// Statement section identifiers, matched against normalized headings.
+const (
+	sectionLegend = iota
+	sectionTask
+	sectionInput
+	sectionOutput
+	sectionConstraints
+	sectionExamples
+	sectionExplanation
+	sectionOther
+)
+
+func classifyHeading(heading string) int {
+	h := NormalizeRomanian(heading)
+	switch {
+	case strings.HasPrefix(h, "cerint"):
+		return sectionTask
+	case strings.HasPrefix(h, "date de intrare"):
+		return sectionInput
+	case strings.HasPrefix(h, "date de iesire"):
+		return sectionOutput
+	case strings.HasPrefix(h, "restrict"):
+		return sectionConstraints
+	case strings.HasPrefix(h, "exempl"):
+		return sectionExamples
+	case strings.HasPrefix(h, "explica"):
+		return sectionExplanation
+	}
+	return sectionOther
+}
+
+var romanianReplacer = strings.NewReplacer(
+	"ă", "a", "â", "a", "î", "i", "ș", "s", "ş", "s", "ț", "t", "ţ", "t",
+	"Ă", "a", "Â", "a", "Î", "i", "Ș", "s", "Ş", "s", "Ț", "t", "Ţ", "t",
+)
+
+// NormalizeRomanian lowercases s, strips Romanian diacritics and collapses whitespace, for matching labels.
+func NormalizeRomanian(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(romanianReplacer.Replace(s))), " ")
+}
+
+var numberRe = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+
+// ParseProblemStatement extracts the structured statement from an Infoarena problem page.
+func ParseProblemStatement(body []byte) (*ProblemStatement, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	ps := &ProblemStatement{}
+
+	root := doc.Find(".wiki_text_block").First()
+	ps.Text = strings.TrimSpace(root.Text())
+	if ps.Text == "" {
+		// fallback: try .content .problem-text
+		root = doc.Find(".content .problem-text").First()
+		ps.Text = strings.TrimSpace(root.Text())
+	}
+	if ps.Text == "" {
+		// fallback: try just .content
+		root = doc.Find(".content").First()
+		ps.Text = strings.TrimSpace(root.Text())
+	}
+	if ps.Text == "" {
+		// fallback: try body text
+		ps.Text = strings.TrimSpace(doc.Find("body").Text())
+		return ps, nil
+	}
+	ps.Markdown = HTMLToMarkdown(root)
+
+	infoTables := parseInfoTables(doc, ps)
+	ps.Title = strings.TrimSpace(root.Find("h1").First().Text())
+
+	sections := make(map[int]*strings.Builder)
+	current := sectionLegend
+	appendText := func(text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		sb, ok := sections[current]
+		if !ok {
+			sb = &strings.Builder{}
+			sections[current] = sb
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(text)
+	}
+	root.Children().Each(func(i int, s *goquery.Selection) {
+		if infoTables[s.Get(0)] || s.Find("table").FilterFunction(func(_ int, t *goquery.Selection) bool { return infoTables[t.Get(0)] }).Length() > 0 {
+			return
+		}
+		switch goquery.NodeName(s) {
+		case "h1":
+			return
+		case "h2", "h3", "h4":
+			current = classifyHeading(s.Text())
+			return
+		case "table":
+			if current == sectionExamples || s.HasClass("example") {
+				ps.Examples = append(ps.Examples, parseExampleTable(s)...)
+				return
+			}
+		}
+		appendText(HTMLToMarkdown(s))
+	})
+
+	get := func(section int) string {
+		if sb, ok := sections[section]; ok {
+			return sb.String()
+		}
+		return ""
+	}
+	ps.Legend = get(sectionLegend)
+	ps.Task = get(sectionTask)
+	ps.Input = get(sectionInput)
+	ps.Output = get(sectionOutput)
+	ps.Constraints = get(sectionConstraints)
+	ps.Explanation = get(sectionExplanation)
+	return ps, nil
+}
+
+// parseInfoTables fills file names, limits, author, source and difficulty from the label/value info
+// table at the top of the problem page and returns the tables it consumed.
+func parseInfoTables(doc *goquery.Document, ps *ProblemStatement) map[*html.Node]bool {
+	consumed := make(map[*html.Node]bool)
+	doc.Find("table").Each(func(i int, table *goquery.Selection) {
+		found := false
+		eachLabelValue(table, func(label, value string, cell *goquery.Selection) {
+			switch {
+			case strings.HasPrefix(label, "fisierul intrare") || strings.HasPrefix(label, "fisiere"):
+				files := strings.Split(value, ",")
+				if len(files) >= 2 {
+					ps.InputFile = strings.TrimSpace(files[0])
+					ps.OutputFile = strings.TrimSpace(files[1])
+					found = true
+				}
+			case strings.HasPrefix(label, "timp executie") || strings.HasPrefix(label, "limita de timp"):
+				ps.TimeLimitSeconds = parseSeconds(value)
+				found = true
+			case strings.HasPrefix(label, "limita de memorie"):
+				ps.MemoryLimitKB = parseKilobytes(value)
+				found = true
+			case strings.HasPrefix(label, "autor"):
+				ps.Author = strings.Join(strings.Fields(value), " ")
+			case strings.HasPrefix(label, "sursa"):
+				ps.Source = strings.Join(strings.Fields(value), " ")
+			case strings.HasPrefix(label, "dificultate"):
+				ps.Difficulty = parseDifficulty(cell)
+			}
+		})
+		if found {
+			consumed[table.Get(0)] = true
+		}
+	})
+	return consumed
+}
+
+// eachLabelValue calls fn for every (label, value) pair of adjacent cells in table, with labels
+// normalized. cell is the value cell, for values that are not plain text.
+func eachLabelValue(table *goquery.Selection, fn func(label, value string, cell *goquery.Selection)) {
+	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		cells := tr.Find("td, th")
+		for j := 0; j+1 < cells.Length(); j += 2 {
+			label := strings.TrimSuffix(NormalizeRomanian(cells.Eq(j).Text()), ":")
+			fn(label, strings.TrimSpace(cells.Eq(j+1).Text()), cells.Eq(j+1))
+		}
+	})
+}
+
+// parseExampleTable reads sample pairs from a table whose first row names the input and output files.
+func parseExampleTable(table *goquery.Selection) []Example {
+	var examples []Example
+	table.Find("tr").Each(func(i int, tr *goquery.Selection) {
+		cells := tr.Find("td")
+		if cells.Length() < 2 {
+			return // Header row
+		}
+		examples = append(examples, Example{
+			Input:  trimExample(cells.Eq(0).Text()),
+			Output: trimExample(cells.Eq(1).Text()),
+		})
+	})
+	return examples
+}
+
+// trimExample strips the blank lines around a sample while keeping inner whitespace.
+func trimExample(s string) string {
+	return strings.Trim(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
+
+// parseSeconds reads values like "0.1 sec" as a number of seconds.
+func parseSeconds(value string) float64 {
+	m := numberRe.FindString(value)
+	if m == "" {
+		return 0
+	}
+	secs, err := strconv.ParseFloat(strings.ReplaceAll(m, ",", "."), 64)
+	if err != nil {
+		return 0
+	}
+	return secs
+}
+
+// parseKilobytes reads values like "65536 kbytes" or "16 MB" as kilobytes.
+func parseKilobytes(value string) int {
+	m := numberRe.FindString(value)
+	if m == "" {
+		return 0
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(m, ",", "."), 64)
+	if err != nil {
+		return 0
+	}
+	v := strings.ToLower(value)
+	if strings.Contains(v, "mb") || strings.Contains(v, "mega") {
+		n *= 1024
+	}
+	return int(n)
+}
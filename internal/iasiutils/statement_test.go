@@ -0,0 +1,167 @@
+package iasiutils
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseProblemStatementFixture(t *testing.T) {
+	body, err := ioutil.ReadFile(fixturePath(fixturesDir, "GET", "https://www.infoarena.ro/problema/adunare", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps, err := ParseProblemStatement(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		field, got, want string
+	}{
+		{"title", ps.Title, "Adunare"},
+		{"input file", ps.InputFile, "adunare.in"},
+		{"output file", ps.OutputFile, "adunare.out"},
+		{"legend", ps.Legend, "Ana are *două* numere naturale."},
+		{"task", ps.Task, "Se dau două numere naturale `a` şi `b`. Afişaţi suma lor."},
+		{"input", ps.Input, "Fişierul de intrare `adunare.in` conţine numerele `a` şi `b`, separate printr-un spaţiu."},
+		{"output", ps.Output, "În fişierul de ieşire `adunare.out` se va afişa suma `a + b`."},
+		{"explanation", ps.Explanation, "2 + 3 = 5."},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if ps.TimeLimitSeconds != 0.1 || ps.MemoryLimitKB != 65536 {
+		t.Errorf("limits = %g s, %d KB; want 0.1 s, 65536 KB", ps.TimeLimitSeconds, ps.MemoryLimitKB)
+	}
+	if ps.Author != "Ion Popescu" || ps.Source != "ONI 2002, clasa a 5-a" || ps.Difficulty != 2 {
+		t.Errorf("author %q, source %q, difficulty %g", ps.Author, ps.Source, ps.Difficulty)
+	}
+	if !strings.HasPrefix(ps.Constraints, "- `0 ≤ a, b ≤ ") {
+		t.Errorf("constraints = %q, want the list item", ps.Constraints)
+	}
+	if len(ps.Examples) != 1 || ps.Examples[0] != (Example{Input: "2 3", Output: "5"}) {
+		t.Errorf("examples = %+v", ps.Examples)
+	}
+	// The info table is consumed by the limits and must not leak into the legend.
+	if strings.Contains(ps.Legend, "kbytes") {
+		t.Errorf("legend contains the info table: %q", ps.Legend)
+	}
+}
+
+func TestParseProblemStatement(t *testing.T) {
+	page := func(inner string) []byte {
+		return []byte(`<html><body><div class="wiki_text_block">` + inner + `</div></body></html>`)
+	}
+	tests := []struct {
+		name string
+		body []byte
+		want ProblemStatement
+	}{
+		{
+			name: "headings without diacritics and h3 sections",
+			body: page(`<h1>Ciur</h1><h3>Cerinta</h3><p>Numarati primele.</p><h3>Date de intrare</h3><p>Un numar N.</p>` +
+				`<h3>Date de iesire</h3><p>Numarul de prime.</p><h3>Restrictii si precizari</h3><p>2 &lt;= N &lt;= 2 000 000</p>`),
+			want: ProblemStatement{Title: "Ciur", Task: "Numarati primele.", Input: "Un numar N.", Output: "Numarul de prime.", Constraints: "2 <= N <= 2 000 000"},
+		},
+		{
+			name: "several paragraphs in a section and unknown headings",
+			body: page(`<h2>Cerinţa</h2><p>Prima parte.</p><p>A doua parte.</p><h2>Istoric</h2><p>Ignorat.</p>` +
+				`<h2>Date de ieşire</h2><p>Rezultatul.</p>`),
+			want: ProblemStatement{Task: "Prima parte.\nA doua parte.", Output: "Rezultatul."},
+		},
+		{
+			name: "legacy info table and limits in MB",
+			body: page(`<table><tr><td>Fisiere:</td><td>a.in, a.out</td></tr><tr><td>Limita de timp:</td><td>1,5 secunde</td>` +
+				`<td>Limita de memorie:</td><td>16 MB</td></tr></table><h2>Cerinta</h2><p>X.</p>`),
+			want: ProblemStatement{InputFile: "a.in", OutputFile: "a.out", TimeLimitSeconds: 1.5, MemoryLimitKB: 16384, Task: "X."},
+		},
+		{
+			name: "several example tables",
+			body: page(`<h2>Cerinta</h2><p>X.</p><h2>Exemple</h2>` +
+				`<table class="example"><tr><th>a.in</th><th>a.out</th></tr><tr><td>1</td><td>2</td></tr><tr><td>3</td><td>4</td></tr></table>` +
+				`<table><tr><td>5 6</td><td>7</td></tr></table>`),
+			want: ProblemStatement{Task: "X.", Examples: []Example{{"1", "2"}, {"3", "4"}, {"5 6", "7"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := ParseProblemStatement(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := *ps
+			got.Text, got.Markdown = "", ""
+			if got.String() != tt.want.String() || len(got.Examples) != len(tt.want.Examples) ||
+				got.TimeLimitSeconds != tt.want.TimeLimitSeconds || got.MemoryLimitKB != tt.want.MemoryLimitKB {
+				t.Errorf("statement =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProblemStatementUnstructured(t *testing.T) {
+	ps, err := ParseProblemStatement([]byte(`<html><body><div class="wiki_text_block"><p>Doar text.</p></div></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.Structured() || ps.String() != "Doar text." {
+		t.Errorf("statement without sections: structured %v, text %q", ps.Structured(), ps.String())
+	}
+	ps, err = ParseProblemStatement([]byte(`<html><body><p>Pagina nu exista.</p></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.Text != "Pagina nu exista." {
+		t.Errorf("text of a page without a statement block = %q", ps.Text)
+	}
+}
+
+func TestNormalizeRomanian(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Cerinţă", "cerinta"},
+		{"Cerință", "cerinta"},
+		{"  Date   de IEŞIRE ", "date de iesire"},
+		{"Restricţii şi precizări", "restrictii si precizari"},
+		{"ÎN AȘTEPTARE", "in asteptare"},
+		{"Explicaţie\n", "explicatie"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRomanian(tt.in); got != tt.want {
+			t.Errorf("NormalizeRomanian(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	seconds := []struct {
+		in   string
+		want float64
+	}{
+		{"0.1 sec", 0.1},
+		{"1,5 secunde", 1.5},
+		{"2 sec", 2},
+		{"nespecificat", 0},
+	}
+	for _, tt := range seconds {
+		if got := parseSeconds(tt.in); got != tt.want {
+			t.Errorf("parseSeconds(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+	kilobytes := []struct {
+		in   string
+		want int
+	}{
+		{"65536 kbytes", 65536},
+		{"16 MB", 16384},
+		{"0,5 megabytes", 512},
+		{"", 0},
+	}
+	for _, tt := range kilobytes {
+		if got := parseKilobytes(tt.in); got != tt.want {
+			t.Errorf("parseKilobytes(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
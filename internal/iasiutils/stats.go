@@ -0,0 +1,84 @@
+package iasiutils
+
+import (
+	"sort"
+	"time"
+)
+
+// ProblemStats aggregates a user's submissions on one problem.
+type ProblemStats struct {
+	Slug         string
+	Name         string
+	Attempts     int
+	BestScore    int
+	FirstAttempt time.Time
+	// Solved is true once a submission scored 100 points; FirstAC and TimeToFirstAC are only set then.
+	Solved        bool
+	FirstAC       time.Time
+	TimeToFirstAC time.Duration
+	// Representative is the submission shown for the problem: the first 100-point one when solved,
+	// otherwise the earliest submission with the best score.
+	Representative Submission
+	// Submissions lists every evaluated submission on the problem, oldest first.
+	Submissions []Submission
+}
+
+// Time returns the moment the problem enters the timeline: the first AC, or the first attempt if unsolved.
+func (ps ProblemStats) Time() time.Time {
+	if ps.Solved {
+		return ps.FirstAC
+	}
+	return ps.FirstAttempt
+}
+
+// AggregateByProblem groups submissions by problem and returns per-problem statistics sorted by Time.
+// Submissions still being evaluated are ignored.
+func AggregateByProblem(subs []Submission) []ProblemStats {
+	sorted := make([]Submission, 0, len(subs))
+	for _, s := range subs {
+		if !s.State.Pending() {
+			sorted = append(sorted, s)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SubmittedAt.Before(sorted[j].SubmittedAt)
+	})
+
+	byKey := make(map[string]*ProblemStats)
+	var order []string
+	for _, s := range sorted {
+		key := s.ProblemSlug
+		if key == "" {
+			key = s.ProblemName
+		}
+		ps, ok := byKey[key]
+		if !ok {
+			ps = &ProblemStats{Slug: s.ProblemSlug, Name: s.ProblemName, BestScore: -1, FirstAttempt: s.SubmittedAt, Representative: s}
+			byKey[key] = ps
+			order = append(order, key)
+		}
+		ps.Attempts++
+		ps.Submissions = append(ps.Submissions, s)
+		if s.Score > ps.BestScore {
+			ps.BestScore = s.Score
+			if !ps.Solved {
+				ps.Representative = s
+			}
+		}
+		if s.Score == 100 && !ps.Solved {
+			ps.Solved = true
+			ps.FirstAC = s.SubmittedAt
+			ps.TimeToFirstAC = s.SubmittedAt.Sub(ps.FirstAttempt)
+			ps.Representative = s
+		}
+	}
+
+	stats := make([]ProblemStats, 0, len(order))
+	for _, key := range order {
+		stats = append(stats, *byKey[key])
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Time().Before(stats[j].Time())
+	})
+	return stats
+}
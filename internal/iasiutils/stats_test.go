@@ -0,0 +1,96 @@
+package iasiutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateByProblem(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	sub := func(id, slug string, d int, status string) Submission {
+		state, score := ParseEvaluation(status)
+		return Submission{JobID: id, ProblemSlug: slug, ProblemName: slug, SubmittedAt: day(d), Status: status, State: state, Score: score}
+	}
+	tests := []struct {
+		name string
+		subs []Submission
+		want []ProblemStats
+	}{
+		{
+			name: "solved after attempts, newest first as on the monitor",
+			subs: []Submission{
+				sub("4", "adunare", 5, "Evaluare completa: 100 puncte"),
+				sub("3", "adunare", 4, "Evaluare completa: 100 puncte"),
+				sub("2", "adunare", 2, "Evaluare completa: 60 puncte"),
+				sub("1", "adunare", 1, "Eroare de compilare"),
+			},
+			want: []ProblemStats{{Slug: "adunare", Attempts: 4, BestScore: 100, FirstAttempt: day(1),
+				Solved: true, FirstAC: day(4), TimeToFirstAC: 3 * 24 * time.Hour, Representative: Submission{JobID: "3"}}},
+		},
+		{
+			name: "unsolved keeps the earliest best score",
+			subs: []Submission{
+				sub("3", "ciur", 3, "Evaluare completa: 40 puncte"),
+				sub("2", "ciur", 2, "Evaluare completa: 40 puncte"),
+				sub("1", "ciur", 1, "Evaluare completa: 10 puncte"),
+			},
+			want: []ProblemStats{{Slug: "ciur", Attempts: 3, BestScore: 40, FirstAttempt: day(1), Representative: Submission{JobID: "2"}}},
+		},
+		{
+			name: "only compile errors",
+			subs: []Submission{sub("1", "ciur", 1, "Eroare de compilare")},
+			want: []ProblemStats{{Slug: "ciur", Attempts: 1, BestScore: -1, FirstAttempt: day(1), Representative: Submission{JobID: "1"}}},
+		},
+		{
+			name: "pending submissions are ignored",
+			subs: []Submission{
+				sub("2", "ciur", 2, "In asteptare"),
+				sub("1", "ciur", 1, "Evaluare completa: 20 puncte"),
+			},
+			want: []ProblemStats{{Slug: "ciur", Attempts: 1, BestScore: 20, FirstAttempt: day(1), Representative: Submission{JobID: "1"}}},
+		},
+		{
+			name: "problems ordered by first AC, or first attempt when unsolved",
+			subs: []Submission{
+				sub("4", "adunare", 9, "Evaluare completa: 100 puncte"),
+				sub("3", "ciur", 5, "Evaluare completa: 30 puncte"),
+				sub("2", "rucsac", 3, "Evaluare completa: 100 puncte"),
+				sub("1", "adunare", 1, "Evaluare completa: 50 puncte"),
+			},
+			want: []ProblemStats{
+				{Slug: "rucsac", Attempts: 1, BestScore: 100, FirstAttempt: day(3), Solved: true, FirstAC: day(3), Representative: Submission{JobID: "2"}},
+				{Slug: "ciur", Attempts: 1, BestScore: 30, FirstAttempt: day(5), Representative: Submission{JobID: "3"}},
+				{Slug: "adunare", Attempts: 2, BestScore: 100, FirstAttempt: day(1), Solved: true, FirstAC: day(9), TimeToFirstAC: 8 * 24 * time.Hour, Representative: Submission{JobID: "4"}},
+			},
+		},
+		{name: "no submissions", subs: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregateByProblem(tt.subs)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d problems, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Slug != w.Slug || g.Attempts != w.Attempts || g.BestScore != w.BestScore || !g.FirstAttempt.Equal(w.FirstAttempt) ||
+					g.Solved != w.Solved || !g.FirstAC.Equal(w.FirstAC) || g.TimeToFirstAC != w.TimeToFirstAC {
+					t.Errorf("problem %d = %s: %d attempts, best %d, first %s, solved %v at %s after %s; want %s: %d attempts, best %d, first %s, solved %v at %s after %s",
+						i, g.Slug, g.Attempts, g.BestScore, g.FirstAttempt, g.Solved, g.FirstAC, g.TimeToFirstAC,
+						w.Slug, w.Attempts, w.BestScore, w.FirstAttempt, w.Solved, w.FirstAC, w.TimeToFirstAC)
+				}
+				if g.Representative.JobID != w.Representative.JobID {
+					t.Errorf("problem %s is represented by job %s, want %s", g.Slug, g.Representative.JobID, w.Representative.JobID)
+				}
+				if len(g.Submissions) != g.Attempts {
+					t.Errorf("problem %s lists %d submissions for %d attempts", g.Slug, len(g.Submissions), g.Attempts)
+				}
+				for j := 1; j < len(g.Submissions); j++ {
+					if g.Submissions[j].SubmittedAt.Before(g.Submissions[j-1].SubmittedAt) {
+						t.Errorf("problem %s submissions are not oldest first", g.Slug)
+					}
+				}
+			}
+		})
+	}
+}
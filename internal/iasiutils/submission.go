@@ -0,0 +1,221 @@
+package iasiutils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Submission is one parsed row of the Infoarena /monitor table.
+type Submission struct {
+	JobID       string    `json:"job_id"`
+	User        string    `json:"user"`
+	ProblemSlug string    `json:"problem_slug"`
+	ProblemName string    `json:"problem_name"`
+	Round       string    `json:"round"`
+	SourceSize  string    `json:"source_size"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	Status      string    `json:"status"`
+	State       EvalState `json:"state"`
+	// Score is the number of points awarded, or -1 when the status carries no score.
+	Score int `json:"score"`
+}
+
+// EvalState is the evaluation status of a submission, parsed from the monitor "Stare" column.
+type EvalState int
+
+const (
+	EvalUnknown EvalState = iota
+	EvalWaiting
+	EvalEvaluating
+	EvalCompileError
+	EvalComplete
+	EvalSystemError
+)
+
+var evalStateNames = map[EvalState]string{
+	EvalUnknown:      "unknown",
+	EvalWaiting:      "waiting",
+	EvalEvaluating:   "evaluating",
+	EvalCompileError: "compile_error",
+	EvalComplete:     "complete",
+	EvalSystemError:  "system_error",
+}
+
+func (es EvalState) String() string {
+	if name, ok := evalStateNames[es]; ok {
+		return name
+	}
+	return evalStateNames[EvalUnknown]
+}
+
+// MarshalText encodes the state by name so stored submissions stay readable.
+func (es EvalState) MarshalText() ([]byte, error) {
+	return []byte(es.String()), nil
+}
+
+// UnmarshalText decodes a state name written by MarshalText.
+func (es *EvalState) UnmarshalText(text []byte) error {
+	for state, name := range evalStateNames {
+		if name == string(text) {
+			*es = state
+			return nil
+		}
+	}
+	*es = EvalUnknown
+	return nil
+}
+
+// Pending reports whether the submission has not finished evaluating yet.
+func (es EvalState) Pending() bool {
+	return es == EvalWaiting || es == EvalEvaluating
+}
+
+// ParseEvaluation maps an Infoarena status string such as "Evaluare completa: 40 puncte" to
+// its state and score, with or without diacritics. The score is -1 unless the evaluation completed.
+func ParseEvaluation(status string) (EvalState, int) {
+	lower := NormalizeRomanian(status)
+	switch {
+	case strings.Contains(lower, "eroare de compilare"):
+		return EvalCompileError, -1
+	case strings.Contains(lower, "evaluare completa"):
+		if m := scoreRe.FindStringSubmatch(lower); m != nil {
+			score, _ := strconv.Atoi(m[1])
+			return EvalComplete, score
+		}
+		return EvalComplete, 0
+	case strings.Contains(lower, "asteptare"):
+		return EvalWaiting, -1
+	case strings.Contains(lower, "evalu"):
+		return EvalEvaluating, -1
+	case strings.Contains(lower, "eroare"):
+		return EvalSystemError, -1
+	}
+	return EvalUnknown, -1
+}
+
+// ProblemURL returns the Infoarena page of the submitted problem.
+func (s Submission) ProblemURL() string {
+	if s.ProblemSlug == "" {
+		return ""
+	}
+	return "https://www.infoarena.ro/problema/" + s.ProblemSlug
+}
+
+// SolutionURL returns the job_detail page of the submission.
+func (s Submission) SolutionURL() string {
+	return "https://www.infoarena.ro/job_detail/" + s.JobID
+}
+
+// Monitor table columns: ID, Utilizator, Problema, Runda, Marime, Data, Stare.
+const (
+	monitorColJob = iota
+	monitorColUser
+	monitorColProblem
+	monitorColRound
+	monitorColSize
+	monitorColDate
+	monitorColStatus
+	monitorColumns
+)
+
+var scoreRe = regexp.MustCompile(`(\d+)\s*puncte`)
+
+// ParseMonitorPage parses the submissions listed in a /monitor page, in page order (newest first).
+// It returns an error when the table layout does not match the expected columns.
+func ParseMonitorPage(body []byte) ([]Submission, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	var subs []Submission
+	var parseErr error
+	doc.Find("table.monitor tbody tr").EachWithBreak(func(i int, tr *goquery.Selection) bool {
+		cells := tr.Find("td")
+		if cells.Length() <= 1 {
+			return true // Placeholder row such as "no entries"
+		}
+		if cells.Length() != monitorColumns {
+			parseErr = fmt.Errorf("monitor row %d has %d columns, want %d", i, cells.Length(), monitorColumns)
+			return false
+		}
+		sub, err := parseMonitorRow(cells)
+		if err != nil {
+			parseErr = fmt.Errorf("monitor row %d: %w", i, err)
+			return false
+		}
+		subs = append(subs, sub)
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return subs, nil
+}
+
+func parseMonitorRow(cells *goquery.Selection) (Submission, error) {
+	text := func(col int) string {
+		return strings.TrimSpace(cells.Eq(col).Text())
+	}
+	var sub Submission
+
+	sub.JobID = strings.TrimPrefix(text(monitorColJob), "#")
+	if _, err := strconv.ParseInt(sub.JobID, 10, 64); err != nil {
+		return sub, fmt.Errorf("invalid job id %q", text(monitorColJob))
+	}
+
+	sub.User = text(monitorColUser)
+	if href, ok := cells.Eq(monitorColUser).Find("a[href*='/utilizator/']").Attr("href"); ok {
+		sub.User = lastPathSegment(href)
+	}
+
+	sub.ProblemName = text(monitorColProblem)
+	if href, ok := cells.Eq(monitorColProblem).Find("a[href^='/problema/']").Attr("href"); ok {
+		sub.ProblemSlug = lastPathSegment(href)
+	}
+
+	sub.Round = text(monitorColRound)
+	sub.SourceSize = text(monitorColSize)
+
+	submittedAt, err := ParseInfoarenaDate(text(monitorColDate))
+	if err != nil {
+		return sub, fmt.Errorf("invalid date %q: %w", text(monitorColDate), err)
+	}
+	sub.SubmittedAt = submittedAt
+
+	sub.Status = text(monitorColStatus)
+	sub.State, sub.Score = ParseEvaluation(sub.Status)
+	return sub, nil
+}
+
+// lastPathSegment returns the part of href after the last '/', without query string.
+func lastPathSegment(href string) string {
+	if i := strings.IndexAny(href, "?#"); i >= 0 {
+		href = href[:i]
+	}
+	href = strings.TrimSuffix(href, "/")
+	return href[strings.LastIndex(href, "/")+1:]
+}
+
+// ParseInfoarenaDate parses dates like "1 apr 25 13:06:35" to time.Time.
+func ParseInfoarenaDate(s string) (time.Time, error) {
+	months := map[string]string{"ian": "Jan", "feb": "Feb", "mar": "Mar", "apr": "Apr", "mai": "May", "iun": "Jun", "iul": "Jul", "aug": "Aug", "sep": "Sep", "oct": "Oct", "nov": "Nov", "dec": "Dec"}
+	parts := strings.Fields(s)
+	if len(parts) != 4 {
+		return time.Time{}, fmt.Errorf("invalid date format")
+	}
+	day := parts[0]
+	mon, ok := months[strings.ToLower(parts[1])]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid month")
+	}
+	year := parts[2]
+	timepart := parts[3]
+	dateStr := fmt.Sprintf("%s %s %s %s", day, mon, year, timepart)
+	return time.Parse("2 Jan 06 15:04:05", dateStr)
+}
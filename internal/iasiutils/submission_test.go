@@ -0,0 +1,142 @@
+package iasiutils
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMonitorPage(t *testing.T) {
+	body, err := ioutil.ReadFile(fixturePath(fixturesDir, "GET", "https://www.infoarena.ro/monitor?user=mentor&display_entries=250&first_entry=0", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs, err := ParseMonitorPage(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Submission{
+		{JobID: "3012", User: "mentor", ProblemSlug: "adunare", ProblemName: "Adunare", Round: "Arhiva de probleme", SourceSize: "0.18 kb",
+			SubmittedAt: time.Date(2024, 3, 12, 10, 15, 7, 0, time.UTC), Status: "Evaluare completa: 100 puncte", State: EvalComplete, Score: 100},
+		{JobID: "3011", User: "mentor", ProblemSlug: "adunare", ProblemName: "Adunare", Round: "Arhiva de probleme", SourceSize: "0.16 kb",
+			SubmittedAt: time.Date(2024, 3, 11, 21, 40, 55, 0, time.UTC), Status: "Evaluare completa: 40 puncte", State: EvalComplete, Score: 40},
+		{JobID: "3010", User: "mentor", ProblemSlug: "ciur", ProblemName: "Ciurul lui Eratostene", Round: "Arhiva de probleme", SourceSize: "0.41 kb",
+			SubmittedAt: time.Date(2024, 2, 2, 8, 3, 12, 0, time.UTC), Status: "Eroare de compilare", State: EvalCompileError, Score: -1},
+	}
+	if len(subs) != len(want) {
+		t.Fatalf("parsed %d submissions, want %d", len(subs), len(want))
+	}
+	for i := range want {
+		if subs[i] != want[i] {
+			t.Errorf("submission %d =\n%+v\nwant\n%+v", i, subs[i], want[i])
+		}
+	}
+}
+
+func TestParseMonitorPageLayout(t *testing.T) {
+	row := func(cells ...string) string {
+		return "<tr><td>" + strings.Join(cells, "</td><td>") + "</td></tr>"
+	}
+	page := func(rows ...string) []byte {
+		return []byte(`<table class="monitor"><tbody>` + strings.Join(rows, "") + `</tbody></table>`)
+	}
+	tests := []struct {
+		name    string
+		body    []byte
+		want    int
+		wantErr string
+	}{
+		{"empty monitor", page(row("Nu exista nicio sursa")), 0, ""},
+		{"no table", []byte("<p>Pagina nu exista</p>"), 0, ""},
+		{"extra column", page(row("#1", "a", "b", "c", "d", "1 apr 25 13:06:35", "e", "f")), 0, "has 8 columns"},
+		{"bad job id", page(row("job", "a", "b", "c", "d", "1 apr 25 13:06:35", "e")), 0, "invalid job id"},
+		{"bad date", page(row("#1", "a", "b", "c", "d", "ieri", "e")), 0, "invalid date"},
+		{"plain text cells", page(row("#1", "mentor", "Adunare", "arhiva", "1 kb", "1 apr 25 13:06:35", "In asteptare")), 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := ParseMonitorPage(tt.body)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(subs) != tt.want {
+				t.Errorf("parsed %d submissions, want %d", len(subs), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInfoarenaDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"1 apr 25 13:06:35", time.Date(2025, 4, 1, 13, 6, 35, 0, time.UTC), false},
+		{"31 dec 09 23:59:59", time.Date(2009, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{"15 Mai 18 08:00:00", time.Date(2018, 5, 15, 8, 0, 0, 0, time.UTC), false},
+		{"7 iun 21 00:00:01", time.Date(2021, 6, 7, 0, 0, 1, 0, time.UTC), false},
+		{"1 april 25 13:06:35", time.Time{}, true},
+		{"1 apr 25", time.Time{}, true},
+		{"1 apr 25 25:00:00", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseInfoarenaDate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseInfoarenaDate(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseInfoarenaDate(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseEvaluation(t *testing.T) {
+	tests := []struct {
+		status    string
+		wantState EvalState
+		wantScore int
+	}{
+		{"Evaluare completa: 100 puncte", EvalComplete, 100},
+		{"Evaluare completa: 0 puncte", EvalComplete, 0},
+		{"Evaluare completă: 35 puncte", EvalComplete, 35},
+		{"evaluare completa", EvalComplete, 0},
+		{"Eroare de compilare", EvalCompileError, -1},
+		{"Eroare de compilare: expected ';' before '}' token", EvalCompileError, -1},
+		{"În aşteptare", EvalWaiting, -1},
+		{"In asteptare", EvalWaiting, -1},
+		{"Se evaluează", EvalEvaluating, -1},
+		{"Evaluare...", EvalEvaluating, -1},
+		{"Eroare de sistem", EvalSystemError, -1},
+		{"", EvalUnknown, -1},
+		{"Acceptat", EvalUnknown, -1},
+	}
+	for _, tt := range tests {
+		state, score := ParseEvaluation(tt.status)
+		if state != tt.wantState || score != tt.wantScore {
+			t.Errorf("ParseEvaluation(%q) = %v, %d; want %v, %d", tt.status, state, score, tt.wantState, tt.wantScore)
+		}
+	}
+}
+
+func TestEvalStateText(t *testing.T) {
+	for state := range evalStateNames {
+		text, _ := state.MarshalText()
+		var got EvalState
+		if err := got.UnmarshalText(text); err != nil || got != state {
+			t.Errorf("%v round-trips to %v (%v)", state, got, err)
+		}
+	}
+	if !EvalWaiting.Pending() || !EvalEvaluating.Pending() || EvalComplete.Pending() || EvalCompileError.Pending() {
+		t.Error("only waiting and evaluating submissions are pending")
+	}
+}
@@ -0,0 +1,200 @@
+package iasiutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageRecord is the LLM consumption of a day or a problem.
+type UsageRecord struct {
+	// Calls is the number of model calls, repairs included.
+	Calls int `json:"calls"`
+	TokenUsage
+	CostUSD float64 `json:"cost_usd"`
+}
+
+func (r *UsageRecord) add(other UsageRecord) {
+	r.Calls += other.Calls
+	r.TokenUsage = r.TokenUsage.Add(other.TokenUsage)
+	r.CostUSD += other.CostUSD
+}
+
+// DayUsage is the LLM consumption of one day, in total and per problem.
+type DayUsage struct {
+	// Date is the local day, formatted as 2006-01-02.
+	Date string `json:"date"`
+	UsageRecord
+	Problems map[string]*UsageRecord `json:"problems,omitempty"`
+}
+
+// Pricing is the cost of a model's tokens in US dollars per million tokens.
+type Pricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// Cost returns the price of u.
+func (p Pricing) Cost(u TokenUsage) float64 {
+	return (float64(u.PromptTokens)*p.InputPerMillion + float64(u.OutputTokens)*p.OutputPerMillion) / 1e6
+}
+
+// UsageLimits caps the LLM consumption of a day. Zero fields do not limit.
+type UsageLimits struct {
+	DailyTokens  int     `json:"daily_tokens,omitempty"`
+	DailyCostUSD float64 `json:"daily_cost_usd,omitempty"`
+}
+
+// ErrUsageLimit is returned by UsageLedger.Check once the consumption of the day reached a limit.
+var ErrUsageLimit = errors.New("daily LLM usage limit reached")
+
+// DefaultUsageDir is where consumption is recorded when UsageLedger.Dir is empty.
+const DefaultUsageDir = "data/usage"
+
+// UsageLedger records the LLM consumption per day and problem in one file per day under Dir and
+// enforces Limits. It is safe for concurrent use.
+type UsageLedger struct {
+	Dir     string
+	Pricing Pricing
+	Limits  UsageLimits
+
+	mu sync.Mutex
+}
+
+// NewUsageLedgerFromEnv returns a ledger writing to dir, priced with IASI_LLM_INPUT_PRICE and
+// IASI_LLM_OUTPUT_PRICE (US dollars per million tokens) and limited by IASI_DAILY_TOKEN_LIMIT and
+// IASI_DAILY_COST_LIMIT (US dollars).
+func NewUsageLedgerFromEnv(dir string) *UsageLedger {
+	return &UsageLedger{
+		Dir: dir,
+		Pricing: Pricing{
+			InputPerMillion:  EnvFloat("IASI_LLM_INPUT_PRICE", 0),
+			OutputPerMillion: EnvFloat("IASI_LLM_OUTPUT_PRICE", 0),
+		},
+		Limits: UsageLimits{
+			DailyTokens:  EnvInt("IASI_DAILY_TOKEN_LIMIT", 0),
+			DailyCostUSD: EnvFloat("IASI_DAILY_COST_LIMIT", 0),
+		},
+	}
+}
+
+func (ul *UsageLedger) dir() string {
+	if ul.Dir != "" {
+		return ul.Dir
+	}
+	return DefaultUsageDir
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// day reads the consumption of date; a day without a file has none.
+func (ul *UsageLedger) day(date string) (*DayUsage, error) {
+	day := &DayUsage{Date: date, Problems: map[string]*UsageRecord{}}
+	data, err := ioutil.ReadFile(filepath.Join(ul.dir(), date+".json"))
+	if os.IsNotExist(err) {
+		return day, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, day); err != nil {
+		return nil, fmt.Errorf("failed to parse usage of %s: %w", date, err)
+	}
+	if day.Problems == nil {
+		day.Problems = map[string]*UsageRecord{}
+	}
+	return day, nil
+}
+
+// Record adds calls model calls using u for problem to today's consumption.
+func (ul *UsageLedger) Record(problem string, calls int, u TokenUsage) error {
+	if calls == 0 && u == (TokenUsage{}) {
+		return nil
+	}
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	day, err := ul.day(today())
+	if err != nil {
+		return err
+	}
+	rec := UsageRecord{Calls: calls, TokenUsage: u, CostUSD: ul.Pricing.Cost(u)}
+	day.add(rec)
+	if day.Problems[problem] == nil {
+		day.Problems[problem] = &UsageRecord{}
+	}
+	day.Problems[problem].add(rec)
+	return writeJSONFile(filepath.Join(ul.dir(), day.Date+".json"), day)
+}
+
+// Check returns an error wrapping ErrUsageLimit when today's consumption reached one of the Limits.
+func (ul *UsageLedger) Check() error {
+	if ul.Limits == (UsageLimits{}) {
+		return nil
+	}
+	ul.mu.Lock()
+	day, err := ul.day(today())
+	ul.mu.Unlock()
+	if err != nil {
+		log.Printf("[WARN] Failed to read today's LLM usage: %v", err)
+		return nil
+	}
+	if ul.Limits.DailyTokens > 0 && day.TotalTokens >= ul.Limits.DailyTokens {
+		return fmt.Errorf("%w: %d of %d tokens used today, generation resumes tomorrow", ErrUsageLimit, day.TotalTokens, ul.Limits.DailyTokens)
+	}
+	if ul.Limits.DailyCostUSD > 0 && day.CostUSD >= ul.Limits.DailyCostUSD {
+		return fmt.Errorf("%w: $%.4f of $%.2f spent today, generation resumes tomorrow", ErrUsageLimit, day.CostUSD, ul.Limits.DailyCostUSD)
+	}
+	return nil
+}
+
+// UsageSummary is the consumption recorded by a UsageLedger.
+type UsageSummary struct {
+	Today   DayUsage    `json:"today"`
+	Limits  UsageLimits `json:"limits"`
+	Pricing Pricing     `json:"pricing"`
+	// Days lists the recorded days, latest first, without their per-problem breakdown.
+	Days []DayUsage `json:"days"`
+	// Problems totals the consumption of each problem over all days.
+	Problems map[string]*UsageRecord `json:"problems"`
+	Total    UsageRecord             `json:"total"`
+}
+
+// Summary totals the recorded consumption per day and per problem.
+func (ul *UsageLedger) Summary() (*UsageSummary, error) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	sum := &UsageSummary{Limits: ul.Limits, Pricing: ul.Pricing, Days: []DayUsage{}, Problems: map[string]*UsageRecord{}}
+	files, _ := filepath.Glob(filepath.Join(ul.dir(), "*.json"))
+	for _, path := range files {
+		day, err := ul.day(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			log.Printf("[WARN] %v", err)
+			continue
+		}
+		for slug, rec := range day.Problems {
+			if sum.Problems[slug] == nil {
+				sum.Problems[slug] = &UsageRecord{}
+			}
+			sum.Problems[slug].add(*rec)
+		}
+		sum.Total.add(day.UsageRecord)
+		sum.Days = append(sum.Days, DayUsage{Date: day.Date, UsageRecord: day.UsageRecord})
+	}
+	sort.Slice(sum.Days, func(i, j int) bool { return sum.Days[i].Date > sum.Days[j].Date })
+	current, err := ul.day(today())
+	if err != nil {
+		return nil, err
+	}
+	sum.Today = *current
+	return sum, nil
+}
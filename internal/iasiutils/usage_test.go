@@ -0,0 +1,119 @@
+package iasiutils
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPricingCost(t *testing.T) {
+	p := Pricing{InputPerMillion: 1, OutputPerMillion: 4}
+	if got := p.Cost(TokenUsage{PromptTokens: 2000000, OutputTokens: 500000, TotalTokens: 2500000}); got != 4 {
+		t.Errorf("Cost() = %g, want 4", got)
+	}
+	if got := (Pricing{}).Cost(TokenUsage{PromptTokens: 10, OutputTokens: 10}); got != 0 {
+		t.Errorf("Cost() without prices = %g, want 0", got)
+	}
+}
+
+func TestUsageLedgerRecord(t *testing.T) {
+	ul := &UsageLedger{Dir: t.TempDir(), Pricing: Pricing{InputPerMillion: 1e6, OutputPerMillion: 2e6}}
+	for _, r := range []struct {
+		problem string
+		calls   int
+		usage   TokenUsage
+	}{
+		{"adunare", 1, TokenUsage{PromptTokens: 10, OutputTokens: 5, TotalTokens: 15}},
+		{"adunare", 2, TokenUsage{PromptTokens: 20, OutputTokens: 5, TotalTokens: 25}},
+		{"ciur", 1, TokenUsage{PromptTokens: 1, OutputTokens: 1, TotalTokens: 2}},
+		{"ciur", 0, TokenUsage{}}, // Nothing to record.
+	} {
+		if err := ul.Record(r.problem, r.calls, r.usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day, err := ul.day(today())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Calls != 4 || day.TotalTokens != 42 || day.CostUSD != 31+2*11 {
+		t.Errorf("day = %+v, want 4 calls, 42 tokens costing $53", day.UsageRecord)
+	}
+	if a := day.Problems["adunare"]; a == nil || a.Calls != 3 || a.PromptTokens != 30 || a.OutputTokens != 10 || a.CostUSD != 30+2*10 {
+		t.Errorf("adunare = %+v, want 3 calls, 30+10 tokens costing $50", a)
+	}
+	if files, _ := filepath.Glob(filepath.Join(ul.Dir, "*")); len(files) != 1 {
+		t.Errorf("files %v, want one per day", files)
+	}
+}
+
+func TestUsageLedgerCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits UsageLimits
+		limit  bool
+	}{
+		{"no limits", UsageLimits{}, false},
+		{"under the token limit", UsageLimits{DailyTokens: 101}, false},
+		{"token limit reached", UsageLimits{DailyTokens: 100}, true},
+		{"under the cost limit", UsageLimits{DailyCostUSD: 0.5}, false},
+		{"cost limit reached", UsageLimits{DailyCostUSD: 0.0001}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ul := &UsageLedger{Dir: t.TempDir(), Pricing: Pricing{InputPerMillion: 1, OutputPerMillion: 1}, Limits: tt.limits}
+			if err := ul.Check(); err != nil {
+				t.Fatalf("Check() before any usage = %v", err)
+			}
+			if err := ul.Record("adunare", 1, TokenUsage{PromptTokens: 60, OutputTokens: 40, TotalTokens: 100}); err != nil {
+				t.Fatal(err)
+			}
+			if err := ul.Check(); errors.Is(err, ErrUsageLimit) != tt.limit {
+				t.Errorf("Check() = %v, want a limit error: %v", err, tt.limit)
+			}
+		})
+	}
+}
+
+func TestUsageLedgerSummary(t *testing.T) {
+	dir := t.TempDir()
+	old := `{"date":"2024-03-01","calls":2,"prompt_tokens":8,"output_tokens":2,"total_tokens":10,"cost_usd":0.5,` +
+		`"problems":{"adunare":{"calls":2,"prompt_tokens":8,"output_tokens":2,"total_tokens":10,"cost_usd":0.5}}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "2024-03-01.json"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2024-02-29.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ul := &UsageLedger{Dir: dir, Limits: UsageLimits{DailyTokens: 1000}}
+	if err := ul.Record("adunare", 1, TokenUsage{PromptTokens: 3, OutputTokens: 2, TotalTokens: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ul.Record("ciur", 1, TokenUsage{PromptTokens: 1, TotalTokens: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := ul.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The unreadable day is skipped.
+	if len(sum.Days) != 2 || sum.Days[0].Date != today() || sum.Days[1].Date != "2024-03-01" || sum.Days[1].Problems != nil {
+		t.Errorf("days = %+v, want today then 2024-03-01 without the per-problem breakdown", sum.Days)
+	}
+	if sum.Total.Calls != 4 || sum.Total.TotalTokens != 16 || sum.Total.CostUSD != 0.5 {
+		t.Errorf("total = %+v", sum.Total)
+	}
+	if a := sum.Problems["adunare"]; a == nil || a.Calls != 3 || a.TotalTokens != 15 {
+		t.Errorf("adunare over all days = %+v", a)
+	}
+	if sum.Today.TotalTokens != 6 || len(sum.Today.Problems) != 2 || sum.Limits.DailyTokens != 1000 {
+		t.Errorf("today = %+v, limits %+v", sum.Today, sum.Limits)
+	}
+
+	empty, err := (&UsageLedger{Dir: filepath.Join(dir, "none")}).Summary()
+	if err != nil || len(empty.Days) != 0 || empty.Total != (UsageRecord{}) {
+		t.Errorf("summary without usage = %+v, %v", empty, err)
+	}
+}
@@ -1,5 +1,14 @@
 package iasiutils
 
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
 // Add any shared utility functions here, e.g. truncateString, error helpers, etc.
 
 // truncateString returns the first n characters of s, appending ... if truncated
@@ -9,3 +18,86 @@ func TruncateString(s string, n int) string {
 	}
 	return s[:n] + "..."
 }
+
+// EnvString returns the value of the environment variable key, or def when unset
+func EnvString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvInt returns the environment variable key parsed as an int, or def when unset or invalid
+func EnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("[WARN] Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return n
+}
+
+// EnvFloat returns the environment variable key parsed as a float, or def when unset or invalid
+func EnvFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("[WARN] Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return f
+}
+
+// EnvDuration returns the environment variable key parsed as a duration (e.g. "30s"), or def when unset or invalid
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[WARN] Ignoring invalid %s=%q: %v", key, v, err)
+		return def
+	}
+	return d
+}
+
+// writeJSONFile writes v as indented JSON to path through a temporary file in the same directory, so
+// readers never see a partial write. Each call has its own temporary file, so concurrent writers of
+// the same path do not interfere; the last rename wins.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -26,13 +26,24 @@ const App: React.FC = () => {
         setProblems(data.problems);
         setUsername(data.username);
         const saved = localStorage.getItem(GLOBAL_SOLVED_KEY);
-        if (saved) setSolved(JSON.parse(saved));
+        if (saved) {
+          // Solved state used to be keyed by display name; move it to the problem slug.
+          const parsed: Record<string, boolean> = JSON.parse(saved);
+          for (const p of data.problems) {
+            if (p.name in parsed && !(p.id in parsed)) {
+              parsed[p.id] = parsed[p.name];
+              delete parsed[p.name];
+            }
+          }
+          localStorage.setItem(GLOBAL_SOLVED_KEY, JSON.stringify(parsed));
+          setSolved(parsed);
+        }
       });
   }, []);
 
-  const handleToggle = (name: string) => {
+  const handleToggle = (id: string) => {
     setSolved(prev => {
-      const next = { ...prev, [name]: !prev[name] };
+      const next = { ...prev, [id]: !prev[id] };
       localStorage.setItem(GLOBAL_SOLVED_KEY, JSON.stringify(next));
       return next;
     });
@@ -44,10 +55,10 @@ const App: React.FC = () => {
     let arr = [...problems];
     switch (sortOption) {
       case 'solved':
-        arr.sort((a, b) => Number(!solved[a.name]) - Number(!solved[b.name]));
+        arr.sort((a, b) => Number(!solved[a.id]) - Number(!solved[b.id]));
         break;
       case 'unsolved':
-        arr.sort((a, b) => Number(!solved[b.name]) - Number(!solved[a.name]));
+        arr.sort((a, b) => Number(!solved[b.id]) - Number(!solved[a.id]));
         break;
       case 'az':
         arr.sort((a, b) => a.name.localeCompare(b.name));
@@ -66,7 +77,7 @@ const App: React.FC = () => {
     return arr;
   }, [problems, solved, sortOption]);
 
-  const solvedCount = problems.filter(p => solved[p.name]).length;
+  const solvedCount = problems.filter(p => solved[p.id]).length;
 
   return (
     <Routes>
@@ -0,0 +1,110 @@
+import React, { useEffect, useState } from 'react';
+import MarkdownView from './MarkdownView';
+
+interface ChatMessage {
+  role: 'user' | 'assistant';
+  content: string;
+  leak?: { score: number; flagged?: boolean };
+}
+
+interface ProblemChatProps {
+  id: string;
+  lang: string;
+  recipe: string;
+}
+
+// Reads the server-sent events of a streamed reply, calling onEvent with each event name and data.
+const readEvents = async (res: Response, onEvent: (event: string, data: any) => void) => {
+  const reader = res.body!.getReader();
+  const decoder = new TextDecoder();
+  let buffer = '';
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    buffer += decoder.decode(value, { stream: true });
+    let end;
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, end);
+      buffer = buffer.slice(end + 2);
+      const event = block.match(/^event: (.*)$/m)?.[1];
+      const data = block.match(/^data: (.*)$/m)?.[1];
+      if (event && data) onEvent(event, JSON.parse(data));
+    }
+  }
+};
+
+// Conversation with the tutor about a problem, kept on the server per problem and language.
+const ProblemChat: React.FC<ProblemChatProps> = ({ id, lang, recipe }) => {
+  const [messages, setMessages] = useState<ChatMessage[]>([]);
+  const [input, setInput] = useState('');
+  const [sending, setSending] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    fetch(`/problems/${id}/chat?lang=${lang}`)
+      .then(r => r.json())
+      .then(history => setMessages(history.messages || []))
+      .catch(() => setMessages([]));
+  }, [id, lang]);
+
+  const send = async (e: React.FormEvent) => {
+    e.preventDefault();
+    const message = input.trim();
+    if (!message) return;
+    setSending(true);
+    setError(null);
+    setInput('');
+    setMessages(prev => [...prev, { role: 'user', content: message }, { role: 'assistant', content: '' }]);
+    const setReply = (update: (reply: ChatMessage) => ChatMessage) =>
+      setMessages(prev => [...prev.slice(0, -1), update(prev[prev.length - 1])]);
+    try {
+      const res = await fetch(`/problems/${id}/chat/stream`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ message, lang, recipe }),
+      });
+      if (!res.ok) throw new Error((await res.json().catch(() => null))?.error || 'Failed to send');
+      await readEvents(res, (event, data) => {
+        if (event === 'token') setReply(reply => ({ ...reply, content: reply.content + data.text }));
+        if (event === 'result') setReply(() => data);
+        if (event === 'failure') throw new Error(data.error || 'Failed to answer');
+      });
+    } catch (err: any) {
+      setError(err.message);
+      setMessages(prev => prev.slice(0, -2));
+      setInput(message);
+    } finally {
+      setSending(false);
+    }
+  };
+
+  const clear = async () => {
+    if (!window.confirm('Forget this conversation?')) return;
+    await fetch(`/problems/${id}/chat?lang=${lang}`, { method: 'DELETE' });
+    setMessages([]);
+  };
+
+  return (
+    <div className="problem-chat">
+      {messages.map((m, i) => (
+        <div key={i} className={`problem-chat-message problem-chat-${m.role}`}>
+          <MarkdownView>{m.content || '...'}</MarkdownView>
+          {m.leak?.flagged && <div className="problem-details-leak">May contain spoilers</div>}
+        </div>
+      ))}
+      <form onSubmit={send} className="problem-chat-form">
+        <input
+          value={input}
+          onChange={e => setInput(e.target.value)}
+          placeholder="Ask the tutor, e.g. why does greedy fail here?"
+          disabled={sending}
+        />
+        <button type="submit" disabled={sending || !input.trim()}>{sending ? 'Thinking...' : 'Ask'}</button>
+        {messages.length > 0 && <button type="button" onClick={clear} disabled={sending}>Clear</button>}
+      </form>
+      {error && <div style={{ color: 'red', marginTop: 8 }}>{error}</div>}
+    </div>
+  );
+};
+
+export default ProblemChat;
@@ -1,5 +1,6 @@
-import React, { useEffect, useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import AccordionBox from './AccordionBox';
+import ProblemChat from './ProblemChat';
 import MarkdownView from './MarkdownView';
 import { useParams, Link } from 'react-router-dom';
 import type { Problem } from './types';
@@ -7,17 +8,62 @@ import type { Problem } from './types';
 interface EditorialData {
   hints: string[];
   editorial: string;
+  complexity?: string;
+  // Provenance of the cached revision
+  revision?: number;
+  provider?: string;
+  model?: string;
+  recipe?: string;
+  created_at?: string;
+  // How much of the solution the output gives away
+  leak?: { score: number; flagged?: boolean; problems?: string[] };
 }
 
 const LOCKS_KEY = 'iasi_tracker_problem_locks';
+const JOBS_KEY = 'iasi_tracker_generation_jobs';
+const LANG_KEY = 'iasi_tracker_lang';
+const LANGUAGES: Record<string, string> = { en: 'English', ro: 'Română' };
+
+// Hints revealed one at a time by /problems/{id}/hints/next, ending with the editorial.
+interface HintChain {
+  hints: string[];
+  steps: number;
+  editorial?: string;
+  done: boolean;
+}
+
+interface GenerationJob {
+  id: string;
+  problem: string;
+  state: 'queued' | 'running' | 'done' | 'failed';
+  phase?: string;
+  error?: string;
+}
 type LocksState = Record<string, { hints: boolean[]; editorial: boolean }>;
 
+// Reads the message of a failed request from its JSON {"error": ...} body or its plain text.
+const errorMessage = async (res: Response, fallback: string) => {
+  const text = (await res.text()).trim();
+  try {
+    return JSON.parse(text).error || fallback;
+  } catch {
+    return text || fallback;
+  }
+};
+
 const ProblemDetails: React.FC = () => {
   const { id } = useParams<{ id: string }>();
   const [problem, setProblem] = useState<Problem | null>(null);
   const [editorial, setEditorial] = useState<EditorialData | null>(null);
+  const [statement, setStatement] = useState<string | null>(null);
   const [loading, setLoading] = useState(false);
+  const [phase, setPhase] = useState<string | null>(null);
+  const [recipes, setRecipes] = useState<string[]>([]);
+  const [recipe, setRecipe] = useState('default');
+  const [lang, setLang] = useState(() => localStorage.getItem(LANG_KEY) || 'en');
+  const pollTimer = useRef<number | undefined>(undefined);
   const [error, setError] = useState<string | null>(null);
+  const [hintChain, setHintChain] = useState<HintChain | null>(null);
   const [tab, setTab] = useState<'hints' | 'editorial'>('hints');
   const [locks, setLocks] = useState<{ hints: boolean[]; editorial: boolean }>({ hints: [], editorial: false });
 
@@ -27,14 +73,26 @@ const ProblemDetails: React.FC = () => {
       .then(data => {
         const found = data.problems.find((p: Problem) => p.id === id);
         setProblem(found || null);
+        // Locks used to be keyed by job id; move them to the problem slug.
+        const allLocks: LocksState = JSON.parse(localStorage.getItem(LOCKS_KEY) || '{}');
+        if (found && id && !allLocks[id] && allLocks[found.job_id]) {
+          allLocks[id] = allLocks[found.job_id];
+          delete allLocks[found.job_id];
+          localStorage.setItem(LOCKS_KEY, JSON.stringify(allLocks));
+          setLocks(allLocks[id]);
+        }
       });
-    fetch(`/problems/${id}/editorial`)
+    fetch(`/problems/${id}/statement`)
       .then(r => {
-        if (!r.ok) throw new Error('Not generated');
-        return r.json();
+        if (!r.ok) throw new Error('Statement unavailable');
+        return r.text();
       })
-      .then(setEditorial)
-      .catch(() => setEditorial(null));
+      .then(setStatement)
+      .catch(() => setStatement(null));
+    fetch('/recipes')
+      .then(r => r.json())
+      .then(setRecipes)
+      .catch(() => setRecipes([]));
     // Load locks from localStorage
     const allLocks: LocksState = JSON.parse(localStorage.getItem(LOCKS_KEY) || '{}');
     if (id && allLocks[id]) {
@@ -42,6 +100,34 @@ const ProblemDetails: React.FC = () => {
     }
   }, [id]);
 
+  // Each language has its own editorial and its own generation job.
+  useEffect(() => {
+    localStorage.setItem(LANG_KEY, lang);
+    setError(null);
+    setLoading(false);
+    setPhase(null);
+    fetch(`/problems/${id}/editorial?lang=${lang}`)
+      .then(r => {
+        if (!r.ok) throw new Error('Not generated');
+        return r.json();
+      })
+      .then(setEditorial)
+      .catch(() => setEditorial(null));
+    fetch(`/problems/${id}/hints?lang=${lang}`)
+      .then(r => {
+        if (!r.ok) throw new Error('No hints revealed');
+        return r.json();
+      })
+      .then(setHintChain)
+      .catch(() => setHintChain(null));
+    // Resume polling a generation started before a reload
+    const jobs: Record<string, string> = JSON.parse(localStorage.getItem(JOBS_KEY) || '{}');
+    if (id && jobs[`${id}:${lang}`]) {
+      pollJob(jobs[`${id}:${lang}`]);
+    }
+    return () => window.clearTimeout(pollTimer.current);
+  }, [id, lang]);
+
   // When editorial loads, initialize locks if not present
   useEffect(() => {
     if (!editorial || !id) return;
@@ -81,14 +167,75 @@ const ProblemDetails: React.FC = () => {
     });
   };
 
-  const handleGenerate = async () => {
+  // Polls a generation job until it finishes; the job id is kept so a reload resumes polling.
+  const pollJob = (jobId: string) => {
+    setLoading(true);
+    setError(null);
+    const jobKey = `${id}:${lang}`;
+    const jobs: Record<string, string> = JSON.parse(localStorage.getItem(JOBS_KEY) || '{}');
+    jobs[jobKey] = jobId;
+    localStorage.setItem(JOBS_KEY, JSON.stringify(jobs));
+    const finish = () => {
+      const jobs: Record<string, string> = JSON.parse(localStorage.getItem(JOBS_KEY) || '{}');
+      delete jobs[jobKey];
+      localStorage.setItem(JOBS_KEY, JSON.stringify(jobs));
+      setLoading(false);
+      setPhase(null);
+    };
+    const poll = async () => {
+      try {
+        const res = await fetch(`/jobs/${jobId}`);
+        if (!res.ok) throw new Error('Generation job was lost, please try again');
+        const job: GenerationJob = await res.json();
+        if (job.state === 'done') {
+          const ed = await fetch(`/problems/${id}/editorial?lang=${lang}`);
+          if (!ed.ok) throw new Error('Failed to load the generated editorial');
+          setEditorial(await ed.json());
+          finish();
+        } else if (job.state === 'failed') {
+          throw new Error(job.error || 'Failed to generate');
+        } else {
+          setPhase(job.phase || job.state);
+          pollTimer.current = window.setTimeout(poll, 1000);
+        }
+      } catch (e: any) {
+        setError(e.message);
+        finish();
+      }
+    };
+    poll();
+  };
+
+  // Queues a generation job on the server and polls it; jobs for the same editorial are shared.
+  const submitJob = async (options: { recipe: string; regenerate?: boolean }, failure: string) => {
     setLoading(true);
     setError(null);
     try {
-      const res = await fetch(`/problems/${id}/generate`, { method: 'POST' });
-      if (!res.ok) throw new Error('Failed to generate');
-      const data = await res.json();
-      setEditorial(data);
+      const res = await fetch('/jobs', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ problem: id, lang, ...options }),
+      });
+      if (!res.ok) throw new Error(await errorMessage(res, failure));
+      const job: GenerationJob = await res.json();
+      pollJob(job.id);
+    } catch (e: any) {
+      setError(e.message);
+      setLoading(false);
+    }
+  };
+
+  const handleGenerate = () => submitJob({ recipe }, 'Failed to generate');
+
+  // Reveals the next step of the hint chain, so only the help actually needed is generated.
+  const handleNextHint = async () => {
+    setLoading(true);
+    setError(null);
+    try {
+      const params = new URLSearchParams({ lang, recipe });
+      const res = await fetch(`/problems/${id}/hints/next?${params}`, { method: 'POST' });
+      if (!res.ok) throw new Error(await errorMessage(res, 'Failed to get a hint'));
+      setHintChain(await res.json());
     } catch (e: any) {
       setError(e.message);
     } finally {
@@ -96,6 +243,12 @@ const ProblemDetails: React.FC = () => {
     }
   };
 
+  // Generates a new revision of the editorial; the previous one stays available on the server.
+  const handleRegenerate = () => {
+    if (!window.confirm('Generate a new version of the hints and editorial?')) return;
+    submitJob({ recipe: editorial?.recipe || recipe, regenerate: true }, 'Failed to regenerate');
+  };
+
   if (!problem) return <div>Problem not found. <Link to="/">Back</Link></div>;
 
   return (
@@ -113,14 +266,37 @@ const ProblemDetails: React.FC = () => {
           <span className="icon" role="img" aria-label="Infoarena">🌐</span> Infoarena
         </a>
         <a
-          href={`https://www.infoarena.ro/job_detail/${problem.id}?action=view-source`}
+          href={`https://www.infoarena.ro/job_detail/${problem.job_id}?action=view-source`}
           target="_blank"
           rel="noopener noreferrer"
           className="infoarena-btn"
         >
           <span className="icon" role="img" aria-label="Code">📝</span> Code
         </a>
+        <a
+          href={`/problems/${problem.id}/source?download=1`}
+          className="infoarena-btn"
+        >
+          <span className="icon" role="img" aria-label="Download">⬇️</span> Download
+        </a>
       </div>
+      {statement && (
+        <div className="problem-details-accordion">
+          <AccordionBox title="Statement">
+            <MarkdownView>{statement}</MarkdownView>
+          </AccordionBox>
+        </div>
+      )}
+      <select
+        value={lang}
+        onChange={e => setLang(e.target.value)}
+        className="sort-dropdown problem-details-lang"
+        title="Language"
+      >
+        {Object.entries(LANGUAGES).map(([code, name]) => (
+          <option key={code} value={code}>{name}</option>
+        ))}
+      </select>
       {editorial ? (
         <>
           <div className="problem-details-tabs" style={{position: 'relative'}}>
@@ -154,18 +330,73 @@ const ProblemDetails: React.FC = () => {
                 onUnlock={unlockEditorial}
               >
                 <MarkdownView>{editorial.editorial}</MarkdownView>
+                {editorial.complexity && (
+                  <MarkdownView>{`**Complexity:** ${editorial.complexity}`}</MarkdownView>
+                )}
               </AccordionBox>
             </div>
           )}
+          <div className="problem-details-provenance">
+            {editorial.revision && <span>Revision {editorial.revision}</span>}
+            {editorial.provider && <span>{editorial.provider}/{editorial.model}</span>}
+            {editorial.recipe && <span>recipe {editorial.recipe}</span>}
+            {editorial.created_at && <span>{new Date(editorial.created_at).toLocaleString()}</span>}
+            {editorial.leak?.flagged && (
+              <span className="problem-details-leak" title={editorial.leak.problems?.join('\n')}>
+                May contain spoilers (leak score {editorial.leak.score.toFixed(2)})
+              </span>
+            )}
+            <button onClick={handleRegenerate} disabled={loading}>
+              {loading ? 'Regenerating...' : 'Regenerate'}
+            </button>
+          </div>
+          {error && <div style={{ color: 'red', marginTop: 8 }}>{error}</div>}
         </>
       ) : (
         <>
+          {recipes.length > 1 && (
+            <select
+              value={recipe}
+              onChange={e => setRecipe(e.target.value)}
+              className="sort-dropdown problem-details-recipe"
+              disabled={loading}
+              title="Recipe"
+            >
+              {recipes.map(name => (
+                <option key={name} value={name}>{name}</option>
+              ))}
+            </select>
+          )}
+          {hintChain && (
+            <div className="problem-details-accordion">
+              {hintChain.hints.map((hint, i) => (
+                <AccordionBox key={i} title={`Hint ${i + 1} of ${hintChain.steps}`} defaultOpen>
+                  <MarkdownView>{hint}</MarkdownView>
+                </AccordionBox>
+              ))}
+              {hintChain.editorial && (
+                <AccordionBox title="Editorial">
+                  <MarkdownView>{hintChain.editorial}</MarkdownView>
+                </AccordionBox>
+              )}
+            </div>
+          )}
           <button className="problem-details-generate-btn" onClick={handleGenerate} disabled={loading}>
-            {loading ? 'Generating...' : 'Generate Hints/Editorial'}
+            {loading ? `Generating${phase ? ` (${phase})` : ''}...` : 'Generate Hints/Editorial'}
           </button>
+          {!hintChain?.done && (
+            <button className="problem-details-generate-btn" onClick={handleNextHint} disabled={loading}>
+              {!hintChain ? 'Get a Hint' : hintChain.hints.length < hintChain.steps ? 'Next Hint' : 'Show Editorial'}
+            </button>
+          )}
           {error && <div style={{ color: 'red', marginTop: 8 }}>{error}</div>}
         </>
       )}
+      <div className="problem-details-accordion">
+        <AccordionBox title="Ask the Tutor">
+          <ProblemChat id={problem.id} lang={lang} recipe={editorial?.recipe || recipe} />
+        </AccordionBox>
+      </div>
       <div className="problem-details-back">
         <Link to="/">Back to list</Link>
       </div>
@@ -6,12 +6,14 @@ export interface ProblemItemProps {
   url: string;
   time: string;
   id: string;
+  best_score: number;
+  attempts: number;
   solved: boolean;
   onToggle: () => void;
 }
 
 
-const ProblemItem: React.FC<ProblemItemProps> = ({ name, url, time, id, solved, onToggle }) => (
+const ProblemItem: React.FC<ProblemItemProps> = ({ name, url, time, id, best_score, attempts, solved, onToggle }) => (
   <li className="problem-item">
     {/* Left: Custom Checkbox */}
     <div style={{ flex: '0 0 auto', marginRight: 18, display: 'flex', alignItems: 'center' }}>
@@ -41,6 +43,9 @@ const ProblemItem: React.FC<ProblemItemProps> = ({ name, url, time, id, solved,
         lineHeight: 1.2,
       }}>{name}</span>
       <span style={{ color: '#7abaff', fontSize: 13, fontWeight: 500, marginTop: 1 }}>Added: {time}</span>
+      <span style={{ color: '#9a9a9a', fontSize: 12, marginTop: 1 }}>
+        Mentor: {best_score >= 0 ? `${best_score}p` : 'no score'} in {attempts} {attempts === 1 ? 'attempt' : 'attempts'}
+      </span>
     </div>
   </li>
 );
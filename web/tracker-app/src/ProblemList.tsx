@@ -5,7 +5,7 @@ import type { Problem } from './types';
 type ProblemListProps = {
   problems: Problem[];
   solved: Record<string, boolean>;
-  onToggle: (name: string) => void;
+  onToggle: (id: string) => void;
   filter: string;
 };
 
@@ -16,10 +16,10 @@ const ProblemList: React.FC<ProblemListProps> = ({ problems, solved, onToggle, f
         .filter(p => p.name.toLowerCase().includes(filter.toLowerCase()))
         .map(p => (
           <ProblemItem
-            key={p.name}
+            key={p.id}
             {...p}
-            solved={!!solved[p.name]}
-            onToggle={() => onToggle(p.name)}
+            solved={!!solved[p.id]}
+            onToggle={() => onToggle(p.id)}
           />
         ))}
     </ul>
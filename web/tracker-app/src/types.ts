@@ -1,8 +1,33 @@
+export interface ProblemMeta {
+  slug: string;
+  title: string;
+  author: string;
+  source: string;
+  time_limit_seconds: number;
+  memory_limit_kb: number;
+  difficulty: number; // stars, 0 if unrated
+}
+
+export interface ProblemSubmission {
+  job_id: string;
+  time: string;
+  state: string;
+  score: number;
+}
+
 export interface Problem {
   name: string;
   url: string;
   time: string;
-  id: string;
+  id: string; // problem slug, the canonical key
+  job_id: string; // representative submission
+  submissions: ProblemSubmission[];
+  solved: boolean; // mentor reached 100 points
+  best_score: number;
+  attempts: number;
+  first_attempt: string;
+  time_to_first_ac_seconds?: number;
+  meta?: ProblemMeta;
   locks?: {
     hints?: boolean[]; // true = locked, false = unlocked
     editorial?: boolean; // true = locked, false = unlocked
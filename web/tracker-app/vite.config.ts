@@ -7,6 +7,9 @@ export default defineConfig({
   server: {
     proxy: {
       '/problems': 'http://localhost:8080',
+      '/jobs': 'http://localhost:8080',
+      '/recipes': 'http://localhost:8080',
+      '/usage': 'http://localhost:8080',
     },
   },
 })